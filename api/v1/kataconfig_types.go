@@ -40,10 +40,389 @@ type KataConfigSpec struct {
 	LogLevel string `json:"logLevel,omitempty"`
 
 	// EnablePeerPods is used to transparently create pods on a remote system.
+	// It can be switched off on an installed KataConfig once no pods use the
+	// kata-remote RuntimeClass anymore.
 	// For more information on how this works, please refer to the sandboxed containers documentation - https://docs.redhat.com/en/documentation/openshift_sandboxed_containers/1.6/html/user_guide/deploying-public-cloud#deploying-public-cloud
 	// +optional
 	// +kubebuilder:default:=false
 	EnablePeerPods bool `json:"enablePeerPods"`
+
+	// PodVMImage configures how the operator handles the pod VM image used
+	// by peer pods.  Only relevant if EnablePeerPods is true.
+	// +optional
+	PodVMImage *PodVMImageSpec `json:"podVMImage,omitempty"`
+
+	// KataRemoteConfig holds the settings the operator renders into
+	// /opt/kata/configuration-remote.toml, the configuration of the
+	// kata-remote runtime used by peer pods.  Changes are rolled out by
+	// updating the 40-worker-kata-remote-config MachineConfig, which
+	// reboots the nodes.  Only relevant if EnablePeerPods is true.
+	// +optional
+	KataRemoteConfig *KataRemoteConfigSpec `json:"kataRemoteConfig,omitempty"`
+
+	// PeerPodsLimitPerNode is the maximum number of peer pods a node can
+	// run, which caps the number of pod VMs started from a node.  Changes
+	// are applied to the existing PeerPodConfig.  Only relevant if
+	// EnablePeerPods is true.
+	// +optional
+	// +kubebuilder:default:=10
+	// +kubebuilder:validation:Minimum=1
+	PeerPodsLimitPerNode *int32 `json:"peerPodsLimitPerNode,omitempty"`
+
+	// PeerPodsNamespaceQuota limits the number of peer pods per namespace.
+	// Every peer pod starts a billable cloud VM.  Only relevant if
+	// EnablePeerPods is true.
+	// +optional
+	PeerPodsNamespaceQuota *PeerPodsNamespaceQuotaSpec `json:"peerPodsNamespaceQuota,omitempty"`
+
+	// PeerPodsWebhook configures the peer pods mutating webhook, which pods
+	// it applies to and what happens if it's unavailable.  Only relevant if
+	// EnablePeerPods is true.
+	// +optional
+	PeerPodsWebhook *PeerPodsWebhookSpec `json:"peerPodsWebhook,omitempty"`
+
+	// Workloads overrides the placement, resources and flags of the
+	// workloads the operator runs, per component
+	// +optional
+	Workloads *WorkloadsSpec `json:"workloads,omitempty"`
+
+	// Monitoring configures kata-monitor, its scraping by the cluster
+	// monitoring and the alerts based on its metrics
+	// +optional
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+}
+
+// MonitoringSpec configures the kata-monitor DaemonSet and the Service,
+// ServiceMonitor and PrometheusRule the operator creates for it
+type MonitoringSpec struct {
+	// Enabled runs kata-monitor on the kata nodes and has its metrics
+	// and the operator ones scraped.  Switching it off removes kata-monitor,
+	// the ServiceMonitors and the alerts.
+	// +optional
+	// +kubebuilder:default:=true
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// LogLevel of kata-monitor
+	// +optional
+	// +kubebuilder:validation:Enum=trace;debug;info;warn;error
+	// +kubebuilder:default:="info"
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Alerts tunes the thresholds of the kata-monitor alerts
+	// +optional
+	Alerts *MonitoringAlertsSpec `json:"alerts,omitempty"`
+}
+
+// MonitoringAlertsSpec holds the thresholds of the kata-monitor alerts
+type MonitoringAlertsSpec struct {
+	// Minutes a kata-monitor target has to be down for KataMonitorDown to
+	// fire
+	// +optional
+	// +kubebuilder:default:=10
+	// +kubebuilder:validation:Minimum=1
+	KataMonitorDownMinutes *int32 `json:"kataMonitorDownMinutes,omitempty"`
+
+	// Number of failed scrapes of the sandboxes metrics by a kata-monitor
+	// over 10 minutes for KataMonitorScrapeFailures to fire
+	// +optional
+	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum=1
+	ScrapeFailures *int32 `json:"scrapeFailures,omitempty"`
+
+	// Minutes an installation or an uninstallation can go without
+	// progress, or the operator can wait for the MCO to start updating
+	// the nodes, before KataConfigInstallStuck or
+	// KataConfigWaitingForMcoToStart fire
+	// +optional
+	// +kubebuilder:default:=60
+	// +kubebuilder:validation:Minimum=1
+	InstallStuckMinutes *int32 `json:"installStuckMinutes,omitempty"`
+
+	// Percentage of failed kata-remote pods above which
+	// PeerPodsFailureRatioHigh fires
+	// +optional
+	// +kubebuilder:default:=20
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	PeerPodFailurePercent *int32 `json:"peerPodFailurePercent,omitempty"`
+}
+
+// WorkloadsSpec holds the overrides of the operator managed workloads
+type WorkloadsSpec struct {
+	// KataMonitor applies to the kata-monitor DaemonSet
+	// +optional
+	KataMonitor *WorkloadOverrides `json:"kataMonitor,omitempty"`
+
+	// PeerPodsWebhook applies to the peer-pods-webhook Deployment
+	// +optional
+	PeerPodsWebhook *WorkloadOverrides `json:"peerPodsWebhook,omitempty"`
+
+	// PodVMImageJobs applies to the Jobs creating, deleting and
+	// replicating pod VM images
+	// +optional
+	PodVMImageJobs *WorkloadOverrides `json:"podVMImageJobs,omitempty"`
+}
+
+// WorkloadOverrides are applied to the pod template the operator generates
+// for a workload.  Unset fields keep the operator's defaults.
+type WorkloadOverrides struct {
+	// Resources replaces the compute resources of the workload's main
+	// container
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector is added to the node selector of the workload.  The
+	// kata-monitor DaemonSet keeps running on the kata nodes only.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations replace the tolerations of the workload
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// PriorityClassName sets the priority class of the workload's pods
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// ImagePullPolicy replaces the image pull policy of the workload's
+	// containers
+	// +optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ExtraArgs are appended to the command line of the workload's main
+	// container
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+}
+
+// PeerPodsWebhookNamespaceLabelMode tells how the namespace label of the
+// peer pods webhook is interpreted
+// +kubebuilder:validation:Enum=OptOut;OptIn
+type PeerPodsWebhookNamespaceLabelMode string
+
+const (
+	// The webhook applies to all namespaces except the ones whose
+	// namespace label is set to "disabled"
+	PeerPodsWebhookOptOut PeerPodsWebhookNamespaceLabelMode = "OptOut"
+	// The webhook only applies to namespaces whose namespace label is set
+	// to "enabled"
+	PeerPodsWebhookOptIn PeerPodsWebhookNamespaceLabelMode = "OptIn"
+)
+
+// PeerPodsWebhookSpec configures the mutating webhook that replaces the
+// container resources of peer pods with the kata.peerpods.io/vm extended
+// resource.  The API server only calls the webhook for pods using the
+// kata-remote RuntimeClass.
+type PeerPodsWebhookSpec struct {
+	// NamespaceLabel is the label key scoping the webhook to namespaces,
+	// see NamespaceLabelMode.  Pods with the label set to "disabled" are
+	// skipped as well.
+	// +optional
+	// +kubebuilder:default:="kataconfiguration.openshift.io/peer-pods-webhook"
+	NamespaceLabel string `json:"namespaceLabel,omitempty"`
+
+	// NamespaceLabelMode is OptOut to apply the webhook to all namespaces
+	// except the ones with NamespaceLabel set to "disabled", or OptIn to
+	// only apply it to the namespaces with NamespaceLabel set to "enabled".
+	// Peer pods the webhook skips are neither counted against the per-node
+	// limit nor against the namespace quota.
+	// +optional
+	// +kubebuilder:default:="OptOut"
+	NamespaceLabelMode PeerPodsWebhookNamespaceLabelMode `json:"namespaceLabelMode,omitempty"`
+
+	// FailurePolicy tells the API server what to do with a peer pod if the
+	// webhook can't be reached.  Fail rejects the pod, Ignore admits it
+	// without the pod VM extended resource, so that it's neither counted
+	// against the per-node limit nor against the namespace quota.
+	// +optional
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	// +kubebuilder:default:="Fail"
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+}
+
+// PeerPodsNamespaceQuotaSpec limits the number of peer pods per namespace.
+// The operator creates a peer-pods-quota ResourceQuota on the
+// kata.peerpods.io/vm extended resource, which the peer-pods webhook adds to
+// every peer pod, in each selected namespace.  Pods exceeding the quota are
+// rejected by the ResourceQuota admission plugin.  The quota of a single
+// namespace can be changed with the
+// kataconfiguration.openshift.io/peer-pods-quota annotation on the
+// namespace.
+type PeerPodsNamespaceQuotaSpec struct {
+	// Maximum number of peer pods in a namespace
+	// +kubebuilder:validation:Minimum=0
+	MaxPeerPods int32 `json:"maxPeerPods"`
+
+	// Selects the namespaces the quota applies to.  If not specified, all
+	// namespaces except default and the kube-* and openshift-* ones
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// KataRemoteConfigSpec configures the kata-remote runtime of peer pods
+type KataRemoteConfigSpec struct {
+	// Seconds the runtime waits for the cloud-api-adaptor to create the
+	// pod VM
+	// +optional
+	// +kubebuilder:default:=900
+	// +kubebuilder:validation:Minimum=1
+	RemoteHypervisorTimeout *int32 `json:"remoteHypervisorTimeout,omitempty"`
+
+	// Seconds the runtime keeps trying to connect to the agent in the
+	// pod VM
+	// +optional
+	// +kubebuilder:default:=30
+	// +kubebuilder:validation:Minimum=1
+	AgentDialTimeout *int32 `json:"agentDialTimeout,omitempty"`
+
+	// Seconds a CreateContainer request, which includes pulling the
+	// container image inside the pod VM, may take.  The lower
+	// runtime-request-timeout of the kubelet still applies.
+	// +optional
+	// +kubebuilder:default:=900
+	// +kubebuilder:validation:Minimum=1
+	CreateContainerTimeout *int32 `json:"createContainerTimeout,omitempty"`
+
+	// Number of vCPUs of a pod VM whose pod doesn't set the
+	// io.katacontainers.config.hypervisor.default_vcpus annotation.  The
+	// cloud-api-adaptor picks the instance type from the vCPUs and memory.
+	// +optional
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum=1
+	DefaultVCPUs *int32 `json:"defaultVCPUs,omitempty"`
+
+	// Memory in MiB of a pod VM whose pod doesn't set the
+	// io.katacontainers.config.hypervisor.default_memory annotation
+	// +optional
+	// +kubebuilder:default:=2048
+	// +kubebuilder:validation:Minimum=256
+	DefaultMemoryMiB *int32 `json:"defaultMemoryMiB,omitempty"`
+
+	// Hypervisor annotations pods are allowed to set.  Each entry is a
+	// regular expression matched against the base name of the
+	// io.katacontainers.config.hypervisor.<name> annotations.  Defaults
+	// to default_vcpus, default_memory and machine_type.
+	// +optional
+	EnableAnnotations []string `json:"enableAnnotations,omitempty"`
+}
+
+// PodVMImageSpec configures pod VM image handling for peer pods
+type PodVMImageSpec struct {
+	// RetryPolicy controls how failed pod VM image creation jobs are retried
+	// +optional
+	RetryPolicy *PodVMImageRetryPolicy `json:"retryPolicy,omitempty"`
+
+	// Import makes the operator use an existing pod VM image instead of
+	// building one in-cluster.  The image is validated with the cloud
+	// provider and recorded in peer-pods-cm.  An imported image is never
+	// deleted by the operator.
+	// +optional
+	Import *PodVMImageImportSpec `json:"import,omitempty"`
+
+	// OCIArtifact makes the operator create the pod VM image from a disk
+	// image packaged as an OCI artifact instead of building it.  The
+	// artifact is pulled with the cluster pull secret and uploaded to the
+	// cloud provider.  Ignored if Import is set.
+	// +optional
+	OCIArtifact *PodVMImageOCIArtifact `json:"ociArtifact,omitempty"`
+
+	// Regions the pod VM image is replicated to in addition to the region
+	// it's created in (AWS_REGION or AZURE_REGION in peer-pods-cm).  AMIs
+	// are copied on AWS, the gallery image version gets additional
+	// replication targets on Azure.  Imported images aren't replicated.
+	// +optional
+	Regions []string `json:"regions,omitempty"`
+
+	// GarbageCollection controls the removal of pod VM images which were
+	// created for this cluster but aren't in use anymore
+	// +optional
+	GarbageCollection *PodVMImageGCSpec `json:"garbageCollection,omitempty"`
+
+	// DeleteOnDisable makes the operator delete the pod VM image when peer
+	// pods are disabled by setting EnablePeerPods to false.  Otherwise the
+	// image is kept and used again when peer pods are re-enabled.  Imported
+	// images are never deleted.
+	// +optional
+	DeleteOnDisable bool `json:"deleteOnDisable,omitempty"`
+}
+
+// PodVMImageGCSpec configures the garbage collection of orphaned pod VM
+// images.  A collection run can also be requested by setting the
+// kataconfiguration.openshift.io/podvm-image-gc annotation of the KataConfig
+// to a new value.
+type PodVMImageGCSpec struct {
+	// Interval in hours between scheduled collection runs, 0 disables them
+	// +optional
+	// +kubebuilder:default:=24
+	// +kubebuilder:validation:Minimum=0
+	IntervalHours *int `json:"intervalHours,omitempty"`
+}
+
+// PodVMImageOCIArtifact references a pod VM disk image packaged as an OCI
+// artifact
+type PodVMImageOCIArtifact struct {
+	// Reference of the artifact, eg. quay.io/example/podvm:1.0.  The
+	// "latest" tag is used if no tag is given.
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Path of the disk image inside the artifact.  The image can be in
+	// qcow2, raw or vhd format.
+	// +optional
+	// +kubebuilder:default:="/image/podvm.qcow2"
+	DiskPath string `json:"diskPath,omitempty"`
+}
+
+// PodVMImageImportSpec references a pre-built pod VM image.  Exactly one of
+// the fields must be set and it must match the cloud provider in use.
+// +kubebuilder:validation:MinProperties=1
+// +kubebuilder:validation:MaxProperties=1
+type PodVMImageImportSpec struct {
+	// ID of an AMI to use on AWS, eg. ami-0123456789abcdef0
+	// +optional
+	// +kubebuilder:validation:Pattern=`^ami-[0-9a-f]+$`
+	AMIID string `json:"amiID,omitempty"`
+
+	// Resource ID of an Azure compute gallery image version, eg.
+	// /subscriptions/<subscription>/resourceGroups/<group>/providers/Microsoft.Compute/galleries/<gallery>/images/<image>/versions/<version>
+	// +optional
+	AzureImageID string `json:"azureImageID,omitempty"`
+
+	// HTTP(S) URL of a qcow2 disk image to upload to the libvirt volume
+	// +optional
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url,omitempty"`
+
+	// Reference of an OCI artifact containing the disk image to upload to
+	// the libvirt volume, eg. quay.io/example/podvm:latest.  The image is
+	// expected at /image/podvm.qcow2 inside the artifact.
+	// +optional
+	OCIArtifact string `json:"ociArtifact,omitempty"`
+}
+
+// PodVMImageRetryPolicy defines the retry budget and the exponential backoff
+// applied between failed pod VM image creation attempts
+type PodVMImageRetryPolicy struct {
+	// Maximum number of failed image creation attempts after which the
+	// operator gives up and sets the PodVMImageBuildFailed condition
+	// +optional
+	// +kubebuilder:default:=3
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+
+	// Delay in seconds before the first retry.  The delay doubles after
+	// every subsequent failure.
+	// +optional
+	// +kubebuilder:default:=60
+	// +kubebuilder:validation:Minimum=1
+	InitialBackoffSeconds int `json:"initialBackoffSeconds,omitempty"`
+
+	// Upper bound in seconds for the delay between retries
+	// +optional
+	// +kubebuilder:default:=3600
+	// +kubebuilder:validation:Minimum=1
+	MaxBackoffSeconds int `json:"maxBackoffSeconds,omitempty"`
 }
 
 // KataConfigStatus defines the observed state of KataConfig
@@ -62,6 +441,105 @@ type KataConfigStatus struct {
 	// +optional
 	// +kubebuilder:default:=false
 	WaitingForMcoToStart bool `json:"waitingForMcoToStart,omitempty"`
+
+	// +optional
+	PodVMImage PodVMImageStatus `json:"podVMImage,omitempty"`
+}
+
+// PodVMImageStatus records pod VM image creation attempts
+type PodVMImageStatus struct {
+	// Number of failed pod VM image creation attempts
+	// +optional
+	FailedAttempts int `json:"failedAttempts,omitempty"`
+
+	// Reason of the last failed attempt as reported by the termination
+	// message of the image creation job's pod
+	// +optional
+	LastFailureReason string `json:"lastFailureReason,omitempty"`
+
+	// Time of the last failed attempt
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
+	// Name of the ConfigMap in the operator namespace holding the tail of
+	// the image builder log of the last failed attempt
+	// +optional
+	LastFailureLogsConfigMap string `json:"lastFailureLogsConfigMap,omitempty"`
+
+	// Reference of the imported pod VM image in use, empty if the image was
+	// built by the operator
+	// +optional
+	ImportedImage string `json:"importedImage,omitempty"`
+
+	// Replication state of the pod VM image per target region
+	// +optional
+	Regions []PodVMImageRegionStatus `json:"regions,omitempty"`
+
+	// Time the last pod VM image replication attempt finished
+	// +optional
+	LastReplicationTime *metav1.Time `json:"lastReplicationTime,omitempty"`
+
+	// State of the garbage collection of orphaned pod VM images
+	// +optional
+	GarbageCollection PodVMImageGCStatus `json:"garbageCollection,omitempty"`
+}
+
+// PodVMImageReference identifies a pod VM image with the cloud provider
+type PodVMImageReference struct {
+	// AMI ID on AWS, image version resource ID on Azure
+	ID string `json:"id"`
+
+	// Region of the image, empty for the region of the cluster
+	// +optional
+	Region string `json:"region,omitempty"`
+}
+
+// PodVMImageGCStatus reports the garbage collection of orphaned pod VM images
+type PodVMImageGCStatus struct {
+	// Time the last collection run listed the images of the cluster
+	// +optional
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+
+	// Value of the kataconfiguration.openshift.io/podvm-image-gc annotation
+	// handled by the last collection run
+	// +optional
+	LastRequest string `json:"lastRequest,omitempty"`
+
+	// Orphaned images still to be deleted by the current collection run
+	// +optional
+	PendingImages []PodVMImageReference `json:"pendingImages,omitempty"`
+
+	// Images deleted by the last collection run
+	// +optional
+	DeletedImages []PodVMImageReference `json:"deletedImages,omitempty"`
+
+	// Error of the last collection run, if any
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Replication states of the pod VM image in a region
+const (
+	PodVMImageRegionPending = "Pending"
+	PodVMImageRegionReady   = "Ready"
+	PodVMImageRegionFailed  = "Failed"
+)
+
+// PodVMImageRegionStatus reports the replication of the pod VM image to a
+// region
+type PodVMImageRegionStatus struct {
+	Region string `json:"region"`
+
+	// ID of the pod VM image in the region
+	// +optional
+	ImageID string `json:"imageID,omitempty"`
+
+	// One of Pending, Ready or Failed
+	State string `json:"state"`
+
+	// Error message if the replication to the region failed
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +genclient
@@ -132,6 +610,9 @@ type KataConfigConditionType string
 
 const (
 	KataConfigInProgress KataConfigConditionType = "InProgress"
+
+	// Set once the pod VM image creation retry budget is spent
+	KataConfigPodVMImageBuildFailed KataConfigConditionType = "PodVMImageBuildFailed"
 )
 
 type KataConfigCondition struct {
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	nodev1listers "k8s.io/client-go/listers/node/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// Pod index on spec.runtimeClassName
+	podRuntimeClassIndex = "runtimeClassName"
+
+	kataRuntimeClassName = "kata"
+	// The RuntimeClass of peer pods
+	runtimeClassName = "kata-remote"
+)
+
+// RuntimeClasses the operator may create.  They are always reported, the
+// ones the KataConfig creates beyond these are reported once they exist.
+var defaultRuntimeClassNames = []string{kataRuntimeClassName, runtimeClassName}
+
+var (
+	runtimeClassAvailable = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_remote_runtimeclass_available",
+		Help: "Indicates if the " + runtimeClassName + " RuntimeClass is available (1) or not (0).",
+	})
+
+	kataRemoteWorkloadFailureRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_remote_workload_failure_ratio",
+		Help: "Percentage of " + runtimeClassName + " workloads that have failed.",
+	})
+
+	totalKataRemotePods = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_total_remote_pods",
+		Help: "Total number of " + runtimeClassName + " pods across all namespaces, regardless of their status.",
+	})
+
+	failedKataRemotePods = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_failed_remote_pods",
+		Help: "Total number of " + runtimeClassName + " pods across all namespaces that have failed or are in CrashLoopBackOff.",
+	})
+
+	kataRuntimeClassAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_runtimeclass_available",
+		Help: "Indicates if the RuntimeClass of the operator is available (1) or not (0).",
+	}, []string{"runtime_class"})
+
+	kataPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_pods",
+		Help: "Number of pods using the RuntimeClass across all namespaces, regardless of their status.",
+	}, []string{"runtime_class"})
+
+	kataFailedPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_failed_pods",
+		Help: "Number of pods using the RuntimeClass across all namespaces that have failed or are in CrashLoopBackOff.",
+	}, []string{"runtime_class"})
+
+	kataWorkloadFailureRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_workload_failure_ratio",
+		Help: "Percentage of the workloads using the RuntimeClass that have failed.",
+	}, []string{"runtime_class"})
+)
+
+// kataCollector computes the metrics from informer caches when Prometheus
+// scrapes them, so that scrapes don't hit the API server.  The PeerPod and
+// KataConfig informers are started without waiting for them, the PeerPod
+// CRD only exists once peer pods are enabled.  Their metrics are left out
+// until they have synced.
+type kataCollector struct {
+	// Serializes scrapes, the metrics are reset and recomputed by each
+	mu sync.Mutex
+
+	pods           cache.Indexer
+	runtimeClasses nodev1listers.RuntimeClassLister
+	nodes          corev1listers.NodeLister
+	configMaps     corev1listers.ConfigMapNamespaceLister
+
+	kataConfigs       cache.GenericLister
+	kataConfigsSynced cache.InformerSynced
+	peerPods          cache.GenericLister
+	peerPodsSynced    cache.InformerSynced
+
+	// When the workload handlers were registered, the pods and events
+	// seen before were accounted for by the previous run of the exporter
+	startTime time.Time
+	// Pods whose time to running has been observed
+	runningPods map[types.UID]bool
+	// Counts of the sandbox failure events seen so far
+	sandboxFailureEvents map[types.UID]int32
+
+	// Status summary of the KataConfig at the last scrape and when it last
+	// changed, to tell stuck rollouts apart
+	progressState string
+	lastProgress  time.Time
+
+	metrics []prometheus.Collector
+}
+
+// Indexes pods by their RuntimeClass, pods without one aren't indexed
+func podRuntimeClassIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T", obj)
+	}
+	if pod.Spec.RuntimeClassName == nil {
+		return nil, nil
+	}
+	return []string{*pod.Spec.RuntimeClassName}, nil
+}
+
+// The managed fields are of no use to the exporter and take up a good part
+// of the cached objects
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// How long to wait for the informer caches to sync.  A list or watch the
+// exporter isn't allowed to do never syncs and would block it forever.
+var cacheSyncTimeout = 2 * time.Minute
+
+// Creates the collector and starts its informers, returns once the caches
+// of the core resources and events have synced.  The caches of the
+// KataConfigs and peer pods may not sync, their CRDs are optional, their
+// metrics are only collected once they have.
+func newKataCollector(clientset kubernetes.Interface, dynamicClient dynamic.Interface, stopCh <-chan struct{}) (*kataCollector, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithTransform(stripManagedFields))
+	nsFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithNamespace(operatorNamespace), informers.WithTransform(stripManagedFields))
+	eventFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = sandboxFailureEventSelector
+		}), informers.WithTransform(stripManagedFields))
+	dynamicFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+
+	podInformer := factory.Core().V1().Pods().Informer()
+	if err := podInformer.AddIndexers(cache.Indexers{podRuntimeClassIndex: podRuntimeClassIndexFunc}); err != nil {
+		return nil, err
+	}
+	kataConfigInformer := dynamicFactory.ForResource(kataConfigGVR)
+	peerPodInformer := dynamicFactory.ForResource(peerPodGVR)
+
+	c := &kataCollector{
+		pods:              podInformer.GetIndexer(),
+		runtimeClasses:    factory.Node().V1().RuntimeClasses().Lister(),
+		nodes:             factory.Core().V1().Nodes().Lister(),
+		configMaps:        nsFactory.Core().V1().ConfigMaps().Lister().ConfigMaps(operatorNamespace),
+		kataConfigs:       kataConfigInformer.Lister(),
+		kataConfigsSynced: kataConfigInformer.Informer().HasSynced,
+		peerPods:          peerPodInformer.Lister(),
+		peerPodsSynced:    peerPodInformer.Informer().HasSynced,
+		metrics: []prometheus.Collector{
+			runtimeClassAvailable,
+			kataRemoteWorkloadFailureRatio,
+			totalKataRemotePods,
+			failedKataRemotePods,
+			kataRuntimeClassAvailable,
+			kataPods,
+			kataFailedPods,
+			kataWorkloadFailureRatio,
+			kataNamespacePods,
+			kataPodTimeToRunning,
+			kataSandboxCreationFailures,
+			kataConfigInstallationSuccess,
+			kataConfigNodes,
+			kataConfigReadyNodes,
+			kataConfigCondition,
+			kataConfigWaitingForMcoToStart,
+			kataConfigLastProgressTime,
+			kataConfigPodVMImageFailedAttempts,
+			peerPodVMs,
+			orphanedPeerPodVMs,
+			peerPodVMsHourlyCost,
+		},
+	}
+
+	if err := c.addWorkloadEventHandlers(podInformer, eventFactory.Core().V1().Events().Informer()); err != nil {
+		return nil, err
+	}
+
+	factory.Start(stopCh)
+	nsFactory.Start(stopCh)
+	eventFactory.Start(stopCh)
+	dynamicFactory.Start(stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for _, f := range []informers.SharedInformerFactory{factory, nsFactory, eventFactory} {
+		for informerType, ok := range f.WaitForCacheSync(ctx.Done()) {
+			if !ok {
+				return nil, fmt.Errorf("failed to sync the %v informer, check the exporter can list and watch it", informerType)
+			}
+		}
+	}
+	for gvr, ok := range dynamicFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			log.Printf("The %v informer hasn't synced, not reporting its metrics until it does", gvr)
+		}
+	}
+	return c, nil
+}
+
+func (c *kataCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.metrics {
+		metric.Describe(ch)
+	}
+}
+
+func (c *kataCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resetMetrics()
+	c.collectRuntimeClassMetrics()
+	if c.kataConfigsSynced() {
+		c.collectKataConfigMetrics()
+	}
+
+	for _, metric := range c.metrics {
+		metric.Collect(ch)
+	}
+}
+
+func (c *kataCollector) resetMetrics() {
+	runtimeClassAvailable.Set(0)
+	kataRemoteWorkloadFailureRatio.Set(0)
+	totalKataRemotePods.Set(0)
+	failedKataRemotePods.Set(0)
+	kataRuntimeClassAvailable.Reset()
+	kataPods.Reset()
+	kataFailedPods.Reset()
+	kataWorkloadFailureRatio.Reset()
+	kataNamespacePods.Reset()
+	kataConfigInstallationSuccess.Set(0)
+	kataConfigNodes.Reset()
+	kataConfigReadyNodes.Set(0)
+	kataConfigCondition.Reset()
+	kataConfigWaitingForMcoToStart.Set(0)
+	kataConfigLastProgressTime.Set(0)
+	kataConfigPodVMImageFailedAttempts.Set(0)
+	peerPodVMs.Reset()
+	orphanedPeerPodVMs.Reset()
+	peerPodVMsHourlyCost.Reset()
+}
+
+// Returns the names of the RuntimeClasses of the operator: the default ones
+// and the ones a KataConfig controls
+func (c *kataCollector) getRuntimeClassNames() []string {
+	names := map[string]bool{}
+	for _, name := range defaultRuntimeClassNames {
+		names[name] = true
+	}
+
+	runtimeClasses, err := c.runtimeClasses.List(labels.Everything())
+	if err != nil {
+		log.Printf("Error listing RuntimeClasses: %v", err)
+	}
+	for _, rc := range runtimeClasses {
+		for _, owner := range rc.OwnerReferences {
+			if owner.Kind == "KataConfig" && owner.APIVersion == kataConfigGVR.GroupVersion().String() {
+				names[rc.Name] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Returns the cached pods using the RuntimeClass
+func (c *kataCollector) getPods(runtimeClass string) []*corev1.Pod {
+	objs, err := c.pods.ByIndex(podRuntimeClassIndex, runtimeClass)
+	if err != nil {
+		log.Printf("Error listing %s pods: %v", runtimeClass, err)
+		return nil
+	}
+	pods := make([]*corev1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+func (c *kataCollector) collectRuntimeClassMetrics() {
+	for _, name := range c.getRuntimeClassNames() {
+		if _, err := c.runtimeClasses.Get(name); err != nil {
+			kataRuntimeClassAvailable.WithLabelValues(name).Set(0)
+			continue
+		}
+		kataRuntimeClassAvailable.WithLabelValues(name).Set(1)
+
+		pods := c.getPods(name)
+		failedPods := 0
+		for _, pod := range pods {
+			state := getPodState(pod)
+			if state == "" {
+				continue
+			}
+			kataNamespacePods.WithLabelValues(name, pod.Namespace, state).Inc()
+			if state == podStateFailed || state == podStateCrashLoopBackOff {
+				failedPods++
+			}
+		}
+		failureRatio := 0.0
+		if len(pods) > 0 {
+			failureRatio = float64(failedPods) / float64(len(pods)) * 100
+		}
+
+		kataPods.WithLabelValues(name).Set(float64(len(pods)))
+		kataFailedPods.WithLabelValues(name).Set(float64(failedPods))
+		kataWorkloadFailureRatio.WithLabelValues(name).Set(failureRatio)
+
+		if name == runtimeClassName {
+			runtimeClassAvailable.Set(1)
+			totalKataRemotePods.Set(float64(len(pods)))
+			failedKataRemotePods.Set(float64(failedPods))
+			kataRemoteWorkloadFailureRatio.Set(failureRatio)
+			if c.peerPodsSynced() {
+				c.collectPeerPodsMetrics(pods)
+			}
+		}
+	}
+}
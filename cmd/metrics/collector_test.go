@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Returns a collector whose informers run against fake clients holding the
+// given objects, once all informers have synced
+func newTestCollector(t *testing.T, objs []runtime.Object, dynamicObjs ...runtime.Object) *kataCollector {
+	c, _ := newTestCollectorWithClientset(t, objs, dynamicObjs...)
+	return c
+}
+
+// Same as newTestCollector, also returns the fake clientset to change the
+// objects the informers watch
+func newTestCollectorWithClientset(t *testing.T, objs []runtime.Object, dynamicObjs ...runtime.Object) (*kataCollector, *fake.Clientset) {
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			kataConfigGVR: "KataConfigList",
+			peerPodGVR:    "PeerPodList",
+		},
+		dynamicObjs...,
+	)
+	clientset := fake.NewSimpleClientset(objs...)
+	c, err := newKataCollector(clientset, dynamicClient, stopCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cache.WaitForCacheSync(stopCh, c.kataConfigsSynced, c.peerPodsSynced) {
+		t.Fatal("informers didn't sync")
+	}
+	return c, clientset
+}
+
+func newRuntimeClass(name string, ownedByKataConfig bool) *nodev1.RuntimeClass {
+	rc := &nodev1.RuntimeClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Handler:    name,
+	}
+	if ownedByKataConfig {
+		rc.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: kataConfigGVR.GroupVersion().String(),
+			Kind:       "KataConfig",
+			Name:       "example-kataconfig",
+		}}
+	}
+	return rc
+}
+
+func newPod(namespace string, name string, runtimeClass string, phase corev1.PodPhase) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	if runtimeClass != "" {
+		pod.Spec.RuntimeClassName = &runtimeClass
+	}
+	return pod
+}
+
+func TestCollectRuntimeClassMetrics(t *testing.T) {
+	g := NewWithT(t)
+	c := newTestCollector(t, []runtime.Object{
+		newRuntimeClass(kataRuntimeClassName, true),
+		newRuntimeClass("kata-cc", true),
+		newRuntimeClass("runc", false),
+		newPod("team-a", "kata-0", kataRuntimeClassName, corev1.PodRunning),
+		newPod("team-a", "kata-1", kataRuntimeClassName, corev1.PodFailed),
+		newPod("team-b", "cc-0", "kata-cc", corev1.PodSucceeded),
+		newPod("team-b", "runc-0", "runc", corev1.PodFailed),
+		newPod("team-b", "default-0", "", corev1.PodFailed),
+	})
+
+	g.Expect(testutil.CollectAndCount(c)).To(BeNumerically(">", 0))
+
+	g.Expect(testutil.ToFloat64(kataRuntimeClassAvailable.WithLabelValues(kataRuntimeClassName))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(kataPods.WithLabelValues(kataRuntimeClassName))).To(Equal(2.0))
+	g.Expect(testutil.ToFloat64(kataFailedPods.WithLabelValues(kataRuntimeClassName))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(kataWorkloadFailureRatio.WithLabelValues(kataRuntimeClassName))).To(Equal(50.0))
+
+	// RuntimeClasses created by the KataConfig are picked up
+	g.Expect(testutil.ToFloat64(kataPods.WithLabelValues("kata-cc"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(kataFailedPods.WithLabelValues("kata-cc"))).To(Equal(0.0))
+
+	// The peer pods RuntimeClass isn't installed
+	g.Expect(testutil.ToFloat64(kataRuntimeClassAvailable.WithLabelValues(runtimeClassName))).To(Equal(0.0))
+	g.Expect(testutil.ToFloat64(runtimeClassAvailable)).To(Equal(0.0))
+
+	// Other RuntimeClasses aren't reported
+	g.Expect(testutil.CollectAndCount(kataRuntimeClassAvailable)).To(Equal(3))
+}
+
+func TestNewCollectorForbiddenList(t *testing.T) {
+	g := NewWithT(t)
+	defer func(timeout time.Duration) { cacheSyncTimeout = timeout }(cacheSyncTimeout)
+	cacheSyncTimeout = time.Second
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// Without the RBAC to list the nodes their cache never syncs
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8serrors.NewForbidden(corev1.Resource("nodes"), "", errors.New("forbidden"))
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			kataConfigGVR: "KataConfigList",
+			peerPodGVR:    "PeerPodList",
+		},
+	)
+
+	_, err := newKataCollector(clientset, dynamicClient, stopCh)
+	g.Expect(err).To(MatchError(ContainSubstring("failed to sync")))
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var kataConfigGVR = schema.GroupVersionResource{
+	Group:    kataconfigurationv1.GroupVersion.Group,
+	Version:  kataconfigurationv1.GroupVersion.Version,
+	Resource: "kataconfigs",
+}
+
+// Node states of KataNodesStatus, used as the state label of
+// kata_config_nodes
+const (
+	nodeStateInstalled          = "installed"
+	nodeStateInstalling         = "installing"
+	nodeStateWaiting            = "waiting"
+	nodeStateFailed             = "failed"
+	nodeStateUninstalling       = "uninstalling"
+	nodeStateWaitingToUninstall = "waiting_to_uninstall"
+	nodeStateFailedToUninstall  = "failed_to_uninstall"
+)
+
+// Conditions of the KataConfig that are always exported, whether the
+// operator has set them or not
+var kataConfigConditionTypes = []kataconfigurationv1.KataConfigConditionType{
+	kataconfigurationv1.KataConfigInProgress,
+	kataconfigurationv1.KataConfigPodVMImageBuildFailed,
+}
+
+var (
+	kataConfigInstallationSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_config_installation_success",
+		Help: "Indicates if KataConfig installation is successful (1) or not (0). It is successful once it's no longer in progress and all its nodes, at least one, are installed and ready.",
+	})
+
+	kataConfigNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_config_nodes",
+		Help: "Number of nodes of the KataConfig by installation state.",
+	}, []string{"state"})
+
+	kataConfigReadyNodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_config_ready_nodes",
+		Help: "Number of nodes that have kata installed and are ready to run kata workloads.",
+	})
+
+	kataConfigCondition = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_config_condition",
+		Help: "Condition of the KataConfig, 1 for the current status of each condition type and 0 for the others.",
+	}, []string{"condition", "status"})
+
+	kataConfigWaitingForMcoToStart = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_config_waiting_for_mco_to_start",
+		Help: "Indicates if the operator is waiting for the MCO to start updating the kata nodes (1) or not (0).",
+	})
+
+	kataConfigLastProgressTime = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_config_last_progress_time_seconds",
+		Help: "Last time the status of the KataConfig changed as seen by the exporter, as a Unix timestamp. It is the start time of the exporter until the first change.",
+	})
+
+	kataConfigPodVMImageFailedAttempts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kata_config_podvm_image_failed_attempts",
+		Help: "Number of failed pod VM image creation attempts since the last successful one.",
+	})
+)
+
+// Returns the KataConfig of the cluster, nil if there is none.  The
+// operator only allows a single KataConfig.
+func (c *kataCollector) getKataConfig() (*kataconfigurationv1.KataConfig, error) {
+	kataConfigs, err := c.kataConfigs.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	if len(kataConfigs) == 0 {
+		return nil, nil
+	}
+
+	obj, ok := kataConfigs[0].(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T", kataConfigs[0])
+	}
+	kataConfig := &kataconfigurationv1.KataConfig{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, kataConfig); err != nil {
+		return nil, err
+	}
+	return kataConfig, nil
+}
+
+// Returns the status of the condition, Unknown if the operator hasn't set it
+func getKataConfigConditionStatus(kataConfig *kataconfigurationv1.KataConfig, conditionType kataconfigurationv1.KataConfigConditionType) corev1.ConditionStatus {
+	for _, condition := range kataConfig.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
+
+// An installation is successful once the operator is done with it, kata is
+// installed on at least one node and all nodes it applies to are ready.  A
+// KataConfig the operator hasn't processed yet has an empty status and isn't
+// installed.
+func isKataConfigInstalled(kataConfig *kataconfigurationv1.KataConfig) bool {
+	kataNodes := kataConfig.Status.KataNodes
+	return getKataConfigConditionStatus(kataConfig, kataconfigurationv1.KataConfigInProgress) == corev1.ConditionFalse &&
+		len(kataNodes.Installed) > 0 &&
+		len(kataNodes.FailedToInstall) == 0 &&
+		kataNodes.ReadyNodeCount == kataNodes.NodeCount
+}
+
+// Returns a summary of the KataConfig status that changes whenever an
+// installation or an uninstallation makes progress.  Only the node lists and
+// the condition types, statuses and reasons count, the reconciles that don't
+// change anything can still rewrite the condition times and messages.
+func getKataConfigProgressState(kataConfig *kataconfigurationv1.KataConfig) string {
+	kataNodes := kataConfig.Status.KataNodes
+	state := fmt.Sprintf("%v %v %v %v %v %v %v",
+		kataNodes.Installed, kataNodes.Installing, kataNodes.WaitingToInstall, kataNodes.FailedToInstall,
+		kataNodes.Uninstalling, kataNodes.WaitingToUninstall, kataNodes.FailedToUninstall)
+	for _, condition := range kataConfig.Status.Conditions {
+		state += fmt.Sprintf(" %s=%s/%s", condition.Type, condition.Status, condition.Reason)
+	}
+	return state
+}
+
+// Exports the installation state of the KataConfig
+func (c *kataCollector) collectKataConfigMetrics() {
+	kataConfig, err := c.getKataConfig()
+	if err != nil {
+		log.Printf("Error getting the KataConfig: %v", err)
+		return
+	}
+	if kataConfig == nil {
+		return
+	}
+
+	if isKataConfigInstalled(kataConfig) {
+		kataConfigInstallationSuccess.Set(1)
+	}
+
+	kataNodes := kataConfig.Status.KataNodes
+	for state, nodes := range map[string][]string{
+		nodeStateInstalled:          kataNodes.Installed,
+		nodeStateInstalling:         kataNodes.Installing,
+		nodeStateWaiting:            kataNodes.WaitingToInstall,
+		nodeStateFailed:             kataNodes.FailedToInstall,
+		nodeStateUninstalling:       kataNodes.Uninstalling,
+		nodeStateWaitingToUninstall: kataNodes.WaitingToUninstall,
+		nodeStateFailedToUninstall:  kataNodes.FailedToUninstall,
+	} {
+		kataConfigNodes.WithLabelValues(state).Set(float64(len(nodes)))
+	}
+	kataConfigReadyNodes.Set(float64(kataNodes.ReadyNodeCount))
+
+	if kataConfig.Status.WaitingForMcoToStart {
+		kataConfigWaitingForMcoToStart.Set(1)
+	}
+	kataConfigPodVMImageFailedAttempts.Set(float64(kataConfig.Status.PodVMImage.FailedAttempts))
+
+	if state := getKataConfigProgressState(kataConfig); state != c.progressState || c.lastProgress.IsZero() {
+		c.progressState = state
+		c.lastProgress = time.Now()
+	}
+	kataConfigLastProgressTime.Set(float64(c.lastProgress.Unix()))
+
+	for _, conditionType := range kataConfigConditionTypes {
+		current := getKataConfigConditionStatus(kataConfig, conditionType)
+		for _, status := range []corev1.ConditionStatus{corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown} {
+			value := 0.0
+			if status == current {
+				value = 1
+			}
+			kataConfigCondition.WithLabelValues(string(conditionType), string(status)).Set(value)
+		}
+	}
+}
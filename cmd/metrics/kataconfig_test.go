@@ -0,0 +1,167 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newKataConfigCollector(t *testing.T, status kataconfigurationv1.KataConfigStatus) *kataCollector {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		TypeMeta:   metav1.TypeMeta{APIVersion: kataconfigurationv1.GroupVersion.String(), Kind: "KataConfig"},
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Status:     status,
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(kataConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestCollector(t, nil, &unstructured.Unstructured{Object: obj})
+}
+
+func TestCollectKataConfigMetricsInstalling(t *testing.T) {
+	g := NewWithT(t)
+	c := newKataConfigCollector(t, kataconfigurationv1.KataConfigStatus{
+		KataNodes: kataconfigurationv1.KataNodesStatus{
+			NodeCount:        4,
+			ReadyNodeCount:   1,
+			Installed:        []string{"worker-0"},
+			Installing:       []string{"worker-1"},
+			WaitingToInstall: []string{"worker-2"},
+			FailedToInstall:  []string{"worker-3"},
+		},
+		Conditions: []kataconfigurationv1.KataConfigCondition{
+			{Type: kataconfigurationv1.KataConfigInProgress, Status: corev1.ConditionTrue},
+		},
+	})
+
+	g.Expect(testutil.CollectAndCount(c)).To(BeNumerically(">", 0))
+
+	g.Expect(testutil.ToFloat64(kataConfigInstallationSuccess)).To(Equal(0.0))
+	g.Expect(testutil.ToFloat64(kataConfigReadyNodes)).To(Equal(1.0))
+	for _, state := range []string{nodeStateInstalled, nodeStateInstalling, nodeStateWaiting, nodeStateFailed} {
+		g.Expect(testutil.ToFloat64(kataConfigNodes.WithLabelValues(state))).To(Equal(1.0), state)
+	}
+	g.Expect(testutil.ToFloat64(kataConfigNodes.WithLabelValues(nodeStateUninstalling))).To(Equal(0.0))
+
+	g.Expect(testutil.ToFloat64(kataConfigCondition.WithLabelValues("InProgress", "True"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(kataConfigCondition.WithLabelValues("InProgress", "False"))).To(Equal(0.0))
+	// Conditions the operator hasn't set are unknown
+	g.Expect(testutil.ToFloat64(kataConfigCondition.WithLabelValues("PodVMImageBuildFailed", "Unknown"))).To(Equal(1.0))
+}
+
+func TestCollectKataConfigMetricsInstalled(t *testing.T) {
+	g := NewWithT(t)
+	c := newKataConfigCollector(t, kataconfigurationv1.KataConfigStatus{
+		KataNodes: kataconfigurationv1.KataNodesStatus{
+			NodeCount:      2,
+			ReadyNodeCount: 2,
+			Installed:      []string{"worker-0", "worker-1"},
+		},
+		Conditions: []kataconfigurationv1.KataConfigCondition{
+			{Type: kataconfigurationv1.KataConfigInProgress, Status: corev1.ConditionFalse},
+		},
+	})
+
+	g.Expect(testutil.CollectAndCount(c)).To(BeNumerically(">", 0))
+
+	g.Expect(testutil.ToFloat64(kataConfigInstallationSuccess)).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(kataConfigNodes.WithLabelValues(nodeStateInstalled))).To(Equal(2.0))
+	g.Expect(testutil.ToFloat64(kataConfigCondition.WithLabelValues("InProgress", "False"))).To(Equal(1.0))
+}
+
+func TestCollectKataConfigMetricsStuck(t *testing.T) {
+	g := NewWithT(t)
+	c := newKataConfigCollector(t, kataconfigurationv1.KataConfigStatus{
+		KataNodes: kataconfigurationv1.KataNodesStatus{
+			NodeCount:        1,
+			WaitingToInstall: []string{"worker-0"},
+		},
+		Conditions: []kataconfigurationv1.KataConfigCondition{
+			{Type: kataconfigurationv1.KataConfigInProgress, Status: corev1.ConditionTrue},
+		},
+		WaitingForMcoToStart: true,
+		PodVMImage:           kataconfigurationv1.PodVMImageStatus{FailedAttempts: 2},
+	})
+
+	g.Expect(testutil.CollectAndCount(c)).To(BeNumerically(">", 0))
+	g.Expect(testutil.ToFloat64(kataConfigWaitingForMcoToStart)).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(kataConfigPodVMImageFailedAttempts)).To(Equal(2.0))
+	lastProgress := testutil.ToFloat64(kataConfigLastProgressTime)
+	g.Expect(lastProgress).To(BeNumerically(">", 0))
+
+	// The progress time only moves when the status changes
+	c.lastProgress = c.lastProgress.Add(-time.Hour)
+	g.Expect(testutil.CollectAndCount(c)).To(BeNumerically(">", 0))
+	g.Expect(testutil.ToFloat64(kataConfigLastProgressTime)).To(Equal(lastProgress - 3600))
+}
+
+func TestIsKataConfigInstalled(t *testing.T) {
+	inProgress := func(status corev1.ConditionStatus) []kataconfigurationv1.KataConfigCondition {
+		return []kataconfigurationv1.KataConfigCondition{{Type: kataconfigurationv1.KataConfigInProgress, Status: status}}
+	}
+	installedNodes := kataconfigurationv1.KataNodesStatus{
+		NodeCount:      1,
+		ReadyNodeCount: 1,
+		Installed:      []string{"worker-0"},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		status    kataconfigurationv1.KataConfigStatus
+		installed bool
+	}{
+		{name: "not processed yet"},
+		{
+			name:      "installed",
+			status:    kataconfigurationv1.KataConfigStatus{KataNodes: installedNodes, Conditions: inProgress(corev1.ConditionFalse)},
+			installed: true,
+		},
+		{
+			name:   "no installed node",
+			status: kataconfigurationv1.KataConfigStatus{Conditions: inProgress(corev1.ConditionFalse)},
+		},
+		{
+			name:   "in progress condition not set",
+			status: kataconfigurationv1.KataConfigStatus{KataNodes: installedNodes},
+		},
+	} {
+		g := NewWithT(t)
+		kataConfig := &kataconfigurationv1.KataConfig{Status: tc.status}
+		g.Expect(isKataConfigInstalled(kataConfig)).To(Equal(tc.installed), tc.name)
+	}
+}
+
+func TestKataConfigProgressState(t *testing.T) {
+	g := NewWithT(t)
+	newKataConfig := func(reason string, transitionTime time.Time, message string) *kataconfigurationv1.KataConfig {
+		kataConfig := &kataconfigurationv1.KataConfig{}
+		kataConfig.Status.KataNodes.WaitingToInstall = []string{"worker-0"}
+		kataConfig.Status.Conditions = []kataconfigurationv1.KataConfigCondition{
+			{
+				Type:               kataconfigurationv1.KataConfigInProgress,
+				Status:             corev1.ConditionTrue,
+				Reason:             reason,
+				Message:            message,
+				LastTransitionTime: metav1.NewTime(transitionTime),
+			},
+		}
+		return kataConfig
+	}
+	state := getKataConfigProgressState(newKataConfig("Installing", time.Now(), "Performing initial installation"))
+
+	// Reconciles that don't change anything are no progress
+	g.Expect(getKataConfigProgressState(newKataConfig("Installing", time.Now().Add(time.Minute), "Still installing"))).To(Equal(state))
+	g.Expect(getKataConfigProgressState(newKataConfig("PodVMImageJobRunning", time.Now(), ""))).NotTo(Equal(state))
+
+	kataConfig := newKataConfig("Installing", time.Now(), "")
+	kataConfig.Status.KataNodes = kataconfigurationv1.KataNodesStatus{Installing: []string{"worker-0"}}
+	g.Expect(getKataConfigProgressState(kataConfig)).NotTo(Equal(state))
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ghodss/yaml"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	operatorNamespace = "openshift-sandboxed-containers-operator"
+	peerPodsCMName    = "peer-pods-cm"
+
+	// Optional ConfigMap with the hourly prices of the pod VM instance
+	// types, see priceTable
+	priceTableCMName = "peer-pods-price-table"
+	priceTableKey    = "prices.yaml"
+
+	// Pod annotation selecting the instance type of the pod VM
+	machineTypeAnnotation = "io.katacontainers.config.hypervisor.machine_type"
+	regionNodeLabel       = "topology.kubernetes.io/region"
+	unknownLabelValue     = "unknown"
+)
+
+var peerPodGVR = schema.GroupVersionResource{
+	Group:    "confidentialcontainers.org",
+	Version:  "v1alpha1",
+	Resource: "peerpods",
+}
+
+var (
+	peerPodVMs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_remote_pod_vms",
+		Help: "Number of pod VMs of " + runtimeClassName + " pods by namespace, instance type and region.",
+	}, []string{"namespace", "instance_type", "region", "cloud_provider"})
+
+	orphanedPeerPodVMs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_remote_orphaned_pod_vms",
+		Help: "Number of PeerPods whose pod no longer exists. Their pod VMs may still be billed until they are cleaned up.",
+	}, []string{"namespace", "cloud_provider"})
+
+	peerPodVMsHourlyCost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_remote_pod_vms_estimated_hourly_cost",
+		Help: "Estimated hourly cost of the pod VMs by namespace, instance type and region, based on the " + priceTableCMName + " ConfigMap.",
+	}, []string{"namespace", "instance_type", "region", "currency"})
+)
+
+// priceTable is read from the prices.yaml key of the peer-pods-price-table
+// ConfigMap in the operator namespace, for example
+//
+//	currency: USD
+//	default:
+//	  m5.xlarge: 0.192
+//	regions:
+//	  eu-west-1:
+//	    m5.xlarge: 0.214
+//
+// Region specific prices take precedence over the default ones.  Instance
+// types without a price aren't included in the cost estimate.
+type priceTable struct {
+	Currency string                        `json:"currency"`
+	Default  map[string]float64            `json:"default"`
+	Regions  map[string]map[string]float64 `json:"regions"`
+}
+
+// Returns the hourly price of the instance type in the region
+func (pt *priceTable) hourlyPrice(region string, instanceType string) (float64, bool) {
+	if price, ok := pt.Regions[region][instanceType]; ok {
+		return price, true
+	}
+	price, ok := pt.Default[instanceType]
+	return price, ok
+}
+
+// Returns the price table, nil if the ConfigMap doesn't exist
+func (c *kataCollector) getPriceTable() (*priceTable, error) {
+	cm, err := c.configMaps.Get(priceTableCMName)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	pt := &priceTable{}
+	if err := yaml.Unmarshal([]byte(cm.Data[priceTableKey]), pt); err != nil {
+		return nil, fmt.Errorf("invalid %s in ConfigMap %s: %v", priceTableKey, priceTableCMName, err)
+	}
+	if pt.Currency == "" {
+		pt.Currency = "USD"
+	}
+	return pt, nil
+}
+
+// Pod VM settings of peer-pods-cm that apply unless a pod overrides them
+type peerPodsDefaults struct {
+	instanceType string
+	region       string
+}
+
+func (c *kataCollector) getPeerPodsDefaults() peerPodsDefaults {
+	defaults := peerPodsDefaults{}
+
+	cm, err := c.configMaps.Get(peerPodsCMName)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			log.Printf("Error getting %s: %v", peerPodsCMName, err)
+		}
+		return defaults
+	}
+
+	for _, key := range []string{"PODVM_INSTANCE_TYPE", "AZURE_INSTANCE_SIZE"} {
+		if value := cm.Data[key]; value != "" {
+			defaults.instanceType = value
+			break
+		}
+	}
+	for _, key := range []string{"AWS_REGION", "AZURE_REGION"} {
+		if value := cm.Data[key]; value != "" {
+			defaults.region = value
+			break
+		}
+	}
+	return defaults
+}
+
+// Returns the pod a PeerPod belongs to.  The cloud-api-adaptor makes the pod
+// the owner of the PeerPod and gives it the name of the pod.
+func findPeerPodPod(peerPod *unstructured.Unstructured, pods map[types.UID]*corev1.Pod, podsByName map[types.NamespacedName]*corev1.Pod) *corev1.Pod {
+	for _, owner := range peerPod.GetOwnerReferences() {
+		if owner.Kind == "Pod" {
+			if pod, ok := pods[owner.UID]; ok {
+				return pod
+			}
+		}
+	}
+	return podsByName[types.NamespacedName{Namespace: peerPod.GetNamespace(), Name: peerPod.GetName()}]
+}
+
+// Returns the region of the node from its topology label
+func (c *kataCollector) getNodeRegion(nodeName string) string {
+	if nodeName == "" {
+		return ""
+	}
+	node, err := c.nodes.Get(nodeName)
+	if err != nil {
+		return ""
+	}
+	return node.Labels[regionNodeLabel]
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return unknownLabelValue
+	}
+	return value
+}
+
+// Exports the pod VM counts and their estimated cost.  Every PeerPod stands
+// for a cloud instance, it's joined with its pod for the instance type and
+// with the pod's node for the region if peer-pods-cm doesn't set them.
+func (c *kataCollector) collectPeerPodsMetrics(pods []*corev1.Pod) {
+	peerPods, err := c.peerPods.List(labels.Everything())
+	if err != nil {
+		log.Printf("Error listing PeerPods: %v", err)
+		return
+	}
+	if len(peerPods) == 0 {
+		return
+	}
+
+	podsByUID := map[types.UID]*corev1.Pod{}
+	podsByName := map[types.NamespacedName]*corev1.Pod{}
+	for _, pod := range pods {
+		podsByUID[pod.UID] = pod
+		podsByName[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}] = pod
+	}
+
+	defaults := c.getPeerPodsDefaults()
+
+	prices, err := c.getPriceTable()
+	if err != nil {
+		log.Printf("Error reading the price table: %v", err)
+	}
+
+	for _, obj := range peerPods {
+		peerPod, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		namespace := peerPod.GetNamespace()
+		cloudProvider, _, _ := unstructured.NestedString(peerPod.Object, "spec", "cloudProvider")
+
+		pod := findPeerPodPod(peerPod, podsByUID, podsByName)
+		if pod == nil {
+			orphanedPeerPodVMs.WithLabelValues(namespace, valueOrUnknown(cloudProvider)).Inc()
+			continue
+		}
+
+		instanceType := defaults.instanceType
+		if machineType := pod.Annotations[machineTypeAnnotation]; machineType != "" {
+			instanceType = machineType
+		}
+		region := defaults.region
+		if region == "" {
+			region = c.getNodeRegion(pod.Spec.NodeName)
+		}
+		instanceType = valueOrUnknown(instanceType)
+		region = valueOrUnknown(region)
+
+		peerPodVMs.WithLabelValues(namespace, instanceType, region, valueOrUnknown(cloudProvider)).Inc()
+
+		if prices == nil {
+			continue
+		}
+		if price, ok := prices.hourlyPrice(region, instanceType); ok {
+			peerPodVMsHourlyCost.WithLabelValues(namespace, instanceType, region, prices.Currency).Add(price)
+		}
+	}
+}
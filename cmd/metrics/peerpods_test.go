@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newPeerPod(namespace string, name string, podUID types.UID) *unstructured.Unstructured {
+	peerPod := &unstructured.Unstructured{}
+	peerPod.SetAPIVersion("confidentialcontainers.org/v1alpha1")
+	peerPod.SetKind("PeerPod")
+	peerPod.SetNamespace(namespace)
+	peerPod.SetName(name)
+	peerPod.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: name, UID: podUID}})
+	_ = unstructured.SetNestedField(peerPod.Object, "aws", "spec", "cloudProvider")
+	return peerPod
+}
+
+func newPeerPodsPod(namespace string, name string, uid types.UID, machineType string) *corev1.Pod {
+	runtimeClass := runtimeClassName
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: uid},
+		Spec:       corev1.PodSpec{RuntimeClassName: &runtimeClass, NodeName: "worker-0"},
+	}
+	if machineType != "" {
+		pod.Annotations = map[string]string{machineTypeAnnotation: machineType}
+	}
+	return pod
+}
+
+func TestCollectPeerPodsMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	c := newTestCollector(t, []runtime.Object{
+		newRuntimeClass(runtimeClassName, true),
+		newPeerPodsPod("team-a", "web-0", "uid-0", ""),
+		newPeerPodsPod("team-a", "web-1", "uid-1", ""),
+		newPeerPodsPod("team-b", "db-0", "uid-2", "m5.xlarge"),
+		newPeerPodsPod("team-b", "cache-0", "uid-3", "c5.large"),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: map[string]string{regionNodeLabel: "us-east-1"}}},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: operatorNamespace, Name: peerPodsCMName},
+			Data:       map[string]string{"PODVM_INSTANCE_TYPE": "t3.medium"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: operatorNamespace, Name: priceTableCMName},
+			Data: map[string]string{priceTableKey: `
+default:
+  t3.medium: 0.05
+regions:
+  us-east-1:
+    m5.xlarge: 0.2
+`},
+		},
+	},
+		newPeerPod("team-a", "web-0", "uid-0"),
+		newPeerPod("team-a", "web-1", "uid-1"),
+		newPeerPod("team-b", "db-0", "uid-2"),
+		newPeerPod("team-b", "cache-0", "uid-3"),
+		// The pod is gone but the VM hasn't been cleaned up yet
+		newPeerPod("team-b", "deleted-0", "uid-4"),
+	)
+
+	g.Expect(testutil.CollectAndCount(c)).To(BeNumerically(">", 0))
+
+	g.Expect(testutil.ToFloat64(totalKataRemotePods)).To(Equal(4.0))
+
+	g.Expect(testutil.ToFloat64(peerPodVMs.WithLabelValues("team-a", "t3.medium", "us-east-1", "aws"))).To(Equal(2.0))
+	g.Expect(testutil.ToFloat64(peerPodVMs.WithLabelValues("team-b", "m5.xlarge", "us-east-1", "aws"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(peerPodVMs.WithLabelValues("team-b", "c5.large", "us-east-1", "aws"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(orphanedPeerPodVMs.WithLabelValues("team-b", "aws"))).To(Equal(1.0))
+
+	g.Expect(testutil.ToFloat64(peerPodVMsHourlyCost.WithLabelValues("team-a", "t3.medium", "us-east-1", "USD"))).To(BeNumerically("~", 0.1))
+	g.Expect(testutil.ToFloat64(peerPodVMsHourlyCost.WithLabelValues("team-b", "m5.xlarge", "us-east-1", "USD"))).To(BeNumerically("~", 0.2))
+	// Instance types without a price aren't estimated
+	g.Expect(testutil.CollectAndCount(peerPodVMsHourlyCost)).To(Equal(2))
+}
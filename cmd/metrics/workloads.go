@@ -0,0 +1,227 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// States of the pods reported by kata_namespace_pods.  A pod with a
+// container in CrashLoopBackOff is reported as such whatever its phase.
+const (
+	podStatePending          = "pending"
+	podStateRunning          = "running"
+	podStateFailed           = "failed"
+	podStateCrashLoopBackOff = "crash_loop_back_off"
+)
+
+// Reasons of the kubelet events on pods whose sandbox, that is the kata VM,
+// couldn't be created or queried
+var sandboxFailureReasons = map[string]bool{
+	"FailedCreatePodSandBox": true,
+	"FailedPodSandBoxStatus": true,
+}
+
+// Field selector of the event informer, the reasons are filtered by the
+// event handler
+const sandboxFailureEventSelector = "involvedObject.kind=Pod,type=" + corev1.EventTypeWarning
+
+var (
+	kataNamespacePods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kata_namespace_pods",
+		Help: "Number of pods using the RuntimeClass by namespace and state (pending, running, failed or crash_loop_back_off).",
+	}, []string{"runtime_class", "namespace", "state"})
+
+	kataPodTimeToRunning = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kata_pod_time_to_running_seconds",
+		Help: "Time from the creation of a pod using the RuntimeClass until its sandbox was ready to start containers.",
+		// A kata-remote pod waits for a cloud VM to boot
+		Buckets: []float64{1, 2, 5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600},
+	}, []string{"runtime_class"})
+
+	kataSandboxCreationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kata_sandbox_creation_failures_total",
+		Help: "Number of sandbox failures reported by the kubelet in pod events, by RuntimeClass, namespace and event reason.",
+	}, []string{"runtime_class", "namespace", "reason"})
+)
+
+// Returns the state of the pod as reported by kata_namespace_pods, empty
+// for pods that are done or whose state is unknown
+func getPodState(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return podStateCrashLoopBackOff
+		}
+	}
+	switch pod.Status.Phase {
+	case corev1.PodPending:
+		return podStatePending
+	case corev1.PodRunning:
+		return podStateRunning
+	case corev1.PodFailed:
+		return podStateFailed
+	}
+	return ""
+}
+
+func getPodCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// Returns the condition telling the pod got running, nil if it hasn't.  The
+// kubelet sets the PodReadyToStartContainers condition once the sandbox is
+// up, the Ready condition is used on clusters without it.
+func getPodRunningCondition(pod *corev1.Pod) *corev1.PodCondition {
+	condition := getPodCondition(pod, corev1.PodReadyToStartContainers)
+	if condition == nil {
+		condition = getPodCondition(pod, corev1.PodReady)
+	}
+	if condition == nil || condition.Status != corev1.ConditionTrue {
+		return nil
+	}
+	return condition
+}
+
+// Returns when the event was last seen
+func getEventLastSeen(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// Returns the RuntimeClass of the pod if it's one of the operator's
+func (c *kataCollector) getOperatorRuntimeClass(pod *corev1.Pod) (string, bool) {
+	if pod.Spec.RuntimeClassName == nil {
+		return "", false
+	}
+	name := *pod.Spec.RuntimeClassName
+	for _, operatorName := range c.getRuntimeClassNames() {
+		if name == operatorName {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Observes the time to running of each pod once.  The informer calls its
+// handlers from a single goroutine, runningPods needs no locking.
+func (c *kataCollector) observePodTimeToRunning(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || c.runningPods[pod.UID] {
+		return
+	}
+	runtimeClass, ok := c.getOperatorRuntimeClass(pod)
+	if !ok {
+		return
+	}
+	condition := getPodRunningCondition(pod)
+	if condition == nil {
+		return
+	}
+	c.runningPods[pod.UID] = true
+	// Pods that got running before the exporter started were observed by
+	// its previous run
+	if condition.LastTransitionTime.Time.Before(c.startTime) {
+		return
+	}
+	duration := condition.LastTransitionTime.Sub(pod.CreationTimestamp.Time)
+	kataPodTimeToRunning.WithLabelValues(runtimeClass).Observe(duration.Seconds())
+}
+
+func (c *kataCollector) forgetPod(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if pod, ok := obj.(*corev1.Pod); ok {
+		delete(c.runningPods, pod.UID)
+	}
+}
+
+// Counts the sandbox failures of the operator's pods.  The kubelet updates
+// the count of an event when the failure repeats, only the increase since
+// the event was last seen is counted.
+func (c *kataCollector) countSandboxFailures(obj interface{}) {
+	event, ok := obj.(*corev1.Event)
+	if !ok || !sandboxFailureReasons[event.Reason] || event.InvolvedObject.Kind != "Pod" {
+		return
+	}
+
+	count := event.Count
+	if event.Series != nil && event.Series.Count > count {
+		count = event.Series.Count
+	}
+	if count < 1 {
+		count = 1
+	}
+	// The failures of events last seen before the exporter started were
+	// counted by its previous run, only later repeats are
+	if _, seen := c.sandboxFailureEvents[event.UID]; !seen && getEventLastSeen(event).Before(c.startTime) {
+		c.sandboxFailureEvents[event.UID] = count
+		return
+	}
+	increase := count - c.sandboxFailureEvents[event.UID]
+	if increase <= 0 {
+		return
+	}
+	c.sandboxFailureEvents[event.UID] = count
+
+	key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+	item, exists, err := c.pods.GetByKey(key)
+	if err != nil || !exists {
+		return
+	}
+	pod, ok := item.(*corev1.Pod)
+	if !ok || pod.UID != event.InvolvedObject.UID {
+		return
+	}
+	runtimeClass, ok := c.getOperatorRuntimeClass(pod)
+	if !ok {
+		return
+	}
+	kataSandboxCreationFailures.WithLabelValues(runtimeClass, pod.Namespace, event.Reason).Add(float64(increase))
+}
+
+func (c *kataCollector) forgetEvent(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if event, ok := obj.(*corev1.Event); ok {
+		delete(c.sandboxFailureEvents, event.UID)
+	}
+}
+
+// Registers the handlers feeding the workload histograms and counters,
+// which unlike the gauges can't be computed at scrape time
+func (c *kataCollector) addWorkloadEventHandlers(podInformer cache.SharedIndexInformer, eventInformer cache.SharedIndexInformer) error {
+	c.startTime = time.Now()
+	c.runningPods = map[types.UID]bool{}
+	c.sandboxFailureEvents = map[types.UID]int32{}
+
+	if _, err := podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.observePodTimeToRunning,
+		UpdateFunc: func(_, obj interface{}) { c.observePodTimeToRunning(obj) },
+		DeleteFunc: c.forgetPod,
+	}); err != nil {
+		return err
+	}
+	_, err := eventInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.countSandboxFailures,
+		UpdateFunc: func(_, obj interface{}) { c.countSandboxFailures(obj) },
+		DeleteFunc: c.forgetEvent,
+	})
+	return err
+}
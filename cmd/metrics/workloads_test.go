@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCollectWorkloadMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	created := metav1.NewTime(time.Now().Add(-time.Hour))
+	running := newPod("team-w", "running-0", runtimeClassName, corev1.PodRunning)
+	running.UID = "uid-running"
+	running.CreationTimestamp = created
+	running.Status.Conditions = []corev1.PodCondition{{
+		Type:               corev1.PodReadyToStartContainers,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(created.Add(40 * time.Second)),
+	}}
+
+	crashing := newPod("team-w", "crashing-0", runtimeClassName, corev1.PodRunning)
+	crashing.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+
+	pending := newPod("team-w", "pending-0", runtimeClassName, corev1.PodPending)
+	pending.UID = "uid-pending"
+
+	sandboxFailure := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-w", Name: "pending-0.1", UID: "uid-event"},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: "team-w",
+			Name:      "pending-0",
+			UID:       "uid-pending",
+		},
+		Reason:        "FailedCreatePodSandBox",
+		Type:          corev1.EventTypeWarning,
+		Count:         3,
+		LastTimestamp: metav1.NewTime(time.Now().Add(-time.Minute)),
+	}
+	otherEvent := sandboxFailure.DeepCopy()
+	otherEvent.Name = "pending-0.2"
+	otherEvent.UID = "uid-other-event"
+	otherEvent.Reason = "FailedScheduling"
+
+	c, clientset := newTestCollectorWithClientset(t, []runtime.Object{
+		newRuntimeClass(runtimeClassName, true),
+		running,
+		crashing,
+		pending,
+		newPod("team-w", "succeeded-0", runtimeClassName, corev1.PodSucceeded),
+		sandboxFailure,
+		otherEvent,
+	})
+
+	g.Expect(testutil.CollectAndCount(c)).To(BeNumerically(">", 0))
+
+	for state, count := range map[string]float64{
+		podStateRunning:          1,
+		podStateCrashLoopBackOff: 1,
+		podStatePending:          1,
+		podStateFailed:           0,
+	} {
+		g.Expect(testutil.ToFloat64(kataNamespacePods.WithLabelValues(runtimeClassName, "team-w", state))).To(Equal(count), state)
+	}
+	// Pending pods aren't failures, crash looping ones are
+	g.Expect(testutil.ToFloat64(kataFailedPods.WithLabelValues(runtimeClassName))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(kataWorkloadFailureRatio.WithLabelValues(runtimeClassName))).To(Equal(25.0))
+
+	// The pod that got running and the failures seen before the exporter
+	// started were accounted for by its previous run, only the pods getting
+	// running and the failures repeating since are
+	started := newPod("team-w", "started-0", runtimeClassName, corev1.PodRunning)
+	started.UID = "uid-started"
+	started.CreationTimestamp = metav1.NewTime(time.Now().Add(-30 * time.Second))
+	started.Status.Conditions = []corev1.PodCondition{{
+		Type:               corev1.PodReadyToStartContainers,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+	}}
+	_, err := clientset.CoreV1().Pods("team-w").Create(context.TODO(), started, metav1.CreateOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	sandboxFailure.Count = 5
+	sandboxFailure.LastTimestamp = metav1.Now()
+	_, err = clientset.CoreV1().Events("team-w").Update(context.TODO(), sandboxFailure, metav1.UpdateOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The informer handlers run asynchronously
+	g.Eventually(func() uint64 {
+		return getHistogramCount(g, runtimeClassName)
+	}).Should(Equal(uint64(1)))
+	g.Consistently(func() uint64 {
+		return getHistogramCount(g, runtimeClassName)
+	}, 200*time.Millisecond).Should(Equal(uint64(1)))
+	g.Eventually(func() float64 {
+		return testutil.ToFloat64(kataSandboxCreationFailures.WithLabelValues(runtimeClassName, "team-w", "FailedCreatePodSandBox"))
+	}).Should(Equal(2.0))
+	g.Expect(testutil.CollectAndCount(kataSandboxCreationFailures)).To(Equal(1))
+}
+
+// Returns the number of observations of the time to running histogram
+func getHistogramCount(g *WithT, runtimeClass string) uint64 {
+	histogram, err := kataPodTimeToRunning.GetMetricWithLabelValues(runtimeClass)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	metric := &dto.Metric{}
+	g.Expect(histogram.(prometheus.Metric).Write(metric)).To(Succeed())
+	return metric.GetHistogram().GetSampleCount()
+}
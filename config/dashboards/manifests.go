@@ -0,0 +1,11 @@
+// Package dashboards embeds the Grafana dashboards the operator adds to the
+// OpenShift console, so that they always match the operator version.
+package dashboards
+
+import "embed"
+
+// Dashboards holds the dashboards as Grafana JSON models, one file per
+// dashboard
+//
+//go:embed *.json
+var Dashboards embed.FS
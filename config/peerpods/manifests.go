@@ -0,0 +1,13 @@
+// Package peerpods embeds the peer-pods manifests into the operator binary
+// so that a broken operator image layout can't make them go missing at
+// runtime.
+package peerpods
+
+import "embed"
+
+// Manifests holds the peer-pods MachineConfigs, the kata-remote
+// configuration template, the CredentialsRequests and the pod VM image job
+// and ConfigMap manifests under their paths relative to this directory
+//
+//go:embed mc-*.yaml configuration-remote.toml.tmpl credentials-requests/*.yaml podvm/*.yaml
+var Manifests embed.FS
@@ -7,6 +7,9 @@
 # The script will be called with one of the following options:
 # Create image (-c)
 # Delete image (-C)
+# Verify image to import (-V)
+# Copy image to region (-r <region>)
+# List images of the cluster (-L)
 
 [[ "$DEBUG" == "true" ]] && set -x
 
@@ -279,6 +282,14 @@ function delete_ami_id_annotation_from_peer_pods_cm() {
         return
     fi
 
+    # Leave the annotation alone if it refers to another ami
+    latest_ami_id=$(kubectl get configmap peer-pods-cm -n openshift-sandboxed-containers-operator \
+        -o jsonpath='{.metadata.annotations.LATEST_AMI_ID}')
+    if [[ -n "${latest_ami_id}" && "${latest_ami_id}" != "${AMI_ID}" ]]; then
+        echo "LATEST_AMI_ID annotation refers to another ami. Skipping deleting the ami id"
+        return
+    fi
+
     # Delete the ami id annotation from peer-pods-cm configmap
     kubectl annotate configmap peer-pods-cm -n openshift-sandboxed-containers-operator \
         "LATEST_AMI_ID-" ||
@@ -308,6 +319,26 @@ function create_ami() {
         install_binary_packages
     fi
 
+    # Based on the value of `IMAGE_TYPE` the image is either build from scratch or using the prebuilt artifact.
+    if [[ "${IMAGE_TYPE}" == "operator-built" ]]; then
+        create_ami_from_scratch
+    elif [[ "${IMAGE_TYPE}" == "pre-built" ]]; then
+        create_ami_from_prebuilt_artifact
+    fi
+
+    # Tag the ami so that it's known to belong to the cluster
+    tag_ami_with_cluster_id
+
+    # Add the ami id as annotation to peer-pods-cm configmap
+    add_ami_id_annotation_to_peer_pods_cm
+
+}
+
+# Function to create the ami from scratch using packer
+
+function create_ami_from_scratch() {
+    echo "Creating AWS AMI from scratch"
+
     if [[ "${DOWNLOAD_SOURCES}" == "yes" ]]; then
         # Download source code from GitHub
         download_source_code
@@ -325,10 +356,160 @@ function create_ami() {
     # Get the ami id of the newly created image
     # This will set the AMI_ID environment variable
     get_ami_id
+}
 
-    # Add the ami id as annotation to peer-pods-cm configmap
-    add_ami_id_annotation_to_peer_pods_cm
+# Function to create the ami from a prebuilt artifact
+# The disk image is extracted from the OCI artifact, converted to raw, uploaded
+# to the PODVM_IMAGE_S3_BUCKET S3 bucket and imported as an EBS snapshot which
+# is registered as the ami.  EC2 import requires the vmimport service role.
+
+function create_ami_from_prebuilt_artifact() {
+    echo "Creating AWS AMI from prebuilt artifact"
+
+    [[ -z "${PODVM_IMAGE_S3_BUCKET}" ]] && error_exit "PODVM_IMAGE_S3_BUCKET is not set"
+    [[ "${PODVM_IMAGE_S3_BUCKET}" != osc-podvm-image-* ]] &&
+        error_exit "PODVM_IMAGE_S3_BUCKET must start with osc-podvm-image-"
+
+    # Set the AMI version and name
+    # It should follow the Major(int).Minor(int).Patch(int)
+    AMI_VERSION="${AMI_VERSION_MAJ_MIN}.$(date +'%Y%m%d%S')"
+    export AMI_VERSION
+    AMI_NAME="${AMI_BASE_NAME}-${AMI_VERSION}"
+    export AMI_NAME
+
+    echo "Pulling the podvm image from the provided path"
+    image_src="/tmp/image"
+    extraction_destination_path="/image"
+    image_repo_auth_file="/tmp/regauth/auth.json"
+
+    # Get the PODVM_IMAGE_TYPE, PODVM_IMAGE_TAG and PODVM_IMAGE_SRC_PATH
+    get_image_type_url_and_path
+
+    case "${PODVM_IMAGE_TYPE}" in
+    oci)
+        echo "Extracting the AWS image from the given path."
+
+        mkdir -p "${extraction_destination_path}" ||
+            error_exit "Failed to create the image directory"
+
+        extract_container_image "${PODVM_IMAGE_URL}" \
+            "${PODVM_IMAGE_TAG}" \
+            "${image_src}" \
+            "${extraction_destination_path}" \
+            "${image_repo_auth_file}"
+
+        # Form the path of the podvm image.
+        podvm_image_path="${extraction_destination_path}/rootfs/${PODVM_IMAGE_SRC_PATH}"
+
+        # Check whether the podvm image is a valid qcow2, raw or vhd.
+        validate_podvm_image "${podvm_image_path}"
+
+        # EC2 imports raw, vhd and vmdk disk images
+        raw_image_path="/tmp/${AMI_NAME}.raw"
+        qemu-img convert -O raw "${podvm_image_path}" "${raw_image_path}" ||
+            error_exit "Failed to convert the podvm image to raw"
+
+        s3_key="${AMI_NAME}.raw"
+        aws s3 cp --region "${AWS_REGION}" "${raw_image_path}" "s3://${PODVM_IMAGE_S3_BUCKET}/${s3_key}" ||
+            error_exit "Failed to upload the podvm image to S3"
+
+        # Import the disk image as a snapshot
+        import_snapshot "${s3_key}"
+
+        # Register the ami backed by the imported snapshot
+        # This will set the AMI_ID environment variable
+        register_ami_from_snapshot "${SNAPSHOT_ID}"
+
+        # Clean up
+        rm -f "${podvm_image_path}" "${raw_image_path}"
+        aws s3 rm --region "${AWS_REGION}" "s3://${PODVM_IMAGE_S3_BUCKET}/${s3_key}" ||
+            error_exit "Failed to delete the podvm image from S3"
+        ;;
+    *)
+        error_exit "Currently only OCI image unpacking is supported, exiting."
+        ;;
+    esac
+
+    echo "AWS AMI created successfully from prebuilt artifact"
+}
+
+# Function to import a raw disk image from the PODVM_IMAGE_S3_BUCKET S3 bucket
+# as an EBS snapshot
+# Gives up after PODVM_IMAGE_IMPORT_TIMEOUT_MINUTES (default 60)
+# Input: S3 key of the disk image
+# Output: SNAPSHOT_ID environment variable
+
+function import_snapshot() {
+    local s3_key="${1}"
+    echo "Importing snapshot from s3://${PODVM_IMAGE_S3_BUCKET}/${s3_key}"
+
+    import_task_id=$(aws ec2 import-snapshot --region "${AWS_REGION}" \
+        --description "${AMI_NAME}" \
+        --disk-container "Format=RAW,UserBucket={S3Bucket=${PODVM_IMAGE_S3_BUCKET},S3Key=${s3_key}}" \
+        --query "ImportTaskId" --output text) ||
+        error_exit "Failed to start the snapshot import"
+
+    # Wait for the import to finish
+    local timeout_minutes="${PODVM_IMAGE_IMPORT_TIMEOUT_MINUTES:-60}"
+    local deadline=$(($(date +%s) + timeout_minutes * 60))
+    while true; do
+        if [[ $(date +%s) -ge ${deadline} ]]; then
+            aws ec2 cancel-import-task --region "${AWS_REGION}" \
+                --import-task-id "${import_task_id}" || true
+            error_exit "Snapshot import ${import_task_id} did not finish within ${timeout_minutes} minutes"
+        fi
+
+        task_status=$(aws ec2 describe-import-snapshot-tasks --region "${AWS_REGION}" \
+            --import-task-ids "${import_task_id}" \
+            --query "ImportSnapshotTasks[0].SnapshotTaskDetail.Status" --output text) ||
+            error_exit "Failed to get the status of the snapshot import"
+
+        case "${task_status}" in
+        completed)
+            break
+            ;;
+        deleting | deleted)
+            error_exit "Snapshot import ${import_task_id} failed"
+            ;;
+        *)
+            echo "Snapshot import ${import_task_id} is ${task_status}"
+            sleep 30
+            ;;
+        esac
+    done
 
+    SNAPSHOT_ID=$(aws ec2 describe-import-snapshot-tasks --region "${AWS_REGION}" \
+        --import-task-ids "${import_task_id}" \
+        --query "ImportSnapshotTasks[0].SnapshotTaskDetail.SnapshotId" --output text) ||
+        error_exit "Failed to get the id of the imported snapshot"
+    export SNAPSHOT_ID
+
+    echo "ID of the imported snapshot: ${SNAPSHOT_ID}"
+}
+
+# Function to register an ami backed by an EBS snapshot
+# Input: snapshot id
+# Output: AMI_ID environment variable
+
+function register_ami_from_snapshot() {
+    local snapshot_id="${1}"
+    echo "Registering ami ${AMI_NAME} from snapshot ${snapshot_id}"
+
+    arch=$(uname -m)
+    [[ "${arch}" == "aarch64" ]] && arch="arm64"
+
+    AMI_ID=$(aws ec2 register-image --region "${AWS_REGION}" \
+        --name "${AMI_NAME}" \
+        --architecture "${arch}" \
+        --virtualization-type hvm \
+        --ena-support \
+        --root-device-name /dev/xvda \
+        --block-device-mappings "DeviceName=/dev/xvda,Ebs={SnapshotId=${snapshot_id},DeleteOnTermination=true}" \
+        --query "ImageId" --output text) ||
+        error_exit "Failed to register the ami"
+    export AMI_ID
+
+    echo "ID of the newly created ami: ${AMI_ID}"
 }
 
 # function to delete the ami
@@ -352,14 +533,127 @@ function delete_ami_using_id() {
 
 }
 
+# function to tag the ami with the cluster id so that orphaned amis of the
+# cluster can be found later on
+# AMI_ID and CLUSTER_ID must be set as environment variables
+
+function tag_ami_with_cluster_id() {
+    if [[ -z "${CLUSTER_ID}" ]]; then
+        echo "CLUSTER_ID is not set. Skipping tagging the ami"
+        return
+    fi
+
+    echo "Tagging AWS AMI ${AMI_ID} with cluster id ${CLUSTER_ID}"
+
+    aws ec2 create-tags --region "${AWS_REGION}" --resources "${AMI_ID}" \
+        --tags "Key=${PODVM_IMAGE_CLUSTER_TAG},Value=${CLUSTER_ID}" ||
+        error_exit "Failed to tag the ami ${AMI_ID}"
+}
+
+# function to list the amis tagged with the cluster id
+# The amis are looked up in AWS_REGION and in the regions from TARGET_REGIONS
+# The list is written to LISTED_IMAGES_FILE in the form [{"id":"<ami id>","region":"<region>"}]
+
+function list_amis_by_cluster_id() {
+    echo "Listing AWS AMIs of cluster ${CLUSTER_ID}"
+
+    [[ -z "${CLUSTER_ID}" ]] && error_exit "CLUSTER_ID is empty"
+
+    local images="[]"
+    IFS=',' read -ra regions <<<"${TARGET_REGIONS}"
+    for region in "${AWS_REGION}" "${regions[@]}"; do
+        [[ -z "${region}" ]] && continue
+
+        ami_ids=$(aws ec2 describe-images --region "${region}" --owners self \
+            --filters "Name=tag:${PODVM_IMAGE_CLUSTER_TAG},Values=${CLUSTER_ID}" \
+            --query "Images[].ImageId" --output json) ||
+            error_exit "Failed to list the amis in ${region}"
+
+        # The images in the cluster's region are reported without region
+        [[ "${region}" == "${AWS_REGION}" ]] && region=""
+        images=$(echo "${images}" | jq -c --argjson ids "${ami_ids}" --arg r "${region}" \
+            '. + [$ids[] | {id: .} + (if $r == "" then {} else {region: $r} end)]')
+    done
+
+    echo "${images}" >"${LISTED_IMAGES_FILE}"
+    echo "AWS AMIs of the cluster: ${images}"
+}
+
+# function to verify that the ami to import exists and is available
+# AMI_ID must be set as an environment variable
+
+function verify_ami_using_id() {
+    echo "Verifying AWS AMI"
+
+    # AMI_ID shouldn't be empty
+    [[ -z "${AMI_ID}" ]] && error_exit "AMI_ID is empty"
+
+    ami_state=$(aws ec2 describe-images --region "${AWS_REGION}" --image-ids "${AMI_ID}" \
+        --query "Images[0].State" --output text) ||
+        error_exit "Failed to get the ami ${AMI_ID}"
+
+    [[ "${ami_state}" != "available" ]] &&
+        error_exit "The ami ${AMI_ID} is not available, state: ${ami_state}"
+
+    echo "AMI ${AMI_ID} is available"
+}
+
+# function to copy the ami to another region
+# AMI_ID must be set as an environment variable
+# Input: target region
+# The id of the copy is written to REPLICATED_IMAGE_ID_FILE
+
+function copy_ami_to_region() {
+    local region="${1}"
+    echo "Copying AWS AMI ${AMI_ID} to ${region}"
+
+    [[ -z "${AMI_ID}" ]] && error_exit "AMI_ID is empty"
+    [[ -z "${region}" ]] && error_exit "Target region is empty"
+
+    ami_name=$(aws ec2 describe-images --region "${AWS_REGION}" --image-ids "${AMI_ID}" \
+        --query "Images[0].Name" --output text) ||
+        error_exit "Failed to get the name of the ami ${AMI_ID}"
+
+    copied_ami_id=$(aws ec2 copy-image --source-region "${AWS_REGION}" --source-image-id "${AMI_ID}" \
+        --region "${region}" --name "${ami_name}" --copy-image-tags \
+        --query "ImageId" --output text) ||
+        error_exit "Failed to copy the ami ${AMI_ID} to ${region}"
+
+    # Wait for the copy to become available
+    while true; do
+        ami_state=$(aws ec2 describe-images --region "${region}" --image-ids "${copied_ami_id}" \
+            --query "Images[0].State" --output text) ||
+            error_exit "Failed to get the state of the ami ${copied_ami_id} in ${region}"
+
+        case "${ami_state}" in
+        available)
+            break
+            ;;
+        failed | error | invalid | deregistered)
+            error_exit "Copying the ami to ${region} failed, state: ${ami_state}"
+            ;;
+        *)
+            echo "AMI ${copied_ami_id} in ${region} is ${ami_state}"
+            sleep 30
+            ;;
+        esac
+    done
+
+    echo "${copied_ami_id}" >"${REPLICATED_IMAGE_ID_FILE}"
+    echo "AMI copied to ${region}: ${copied_ami_id}"
+}
+
 # display help message
 
 function display_help() {
     echo "This script is used to create AWS ami for podvm"
-    echo "Usage: $0 [-c|-C] [-- install_binaries|install_rpms|install_cli]"
+    echo "Usage: $0 [-c|-C|-V|-r <region>|-L] [-- install_binaries|install_rpms|install_cli]"
     echo "Options:"
     echo "-c  Create image"
     echo "-C  Delete image"
+    echo "-V  Verify image to import"
+    echo "-r  Copy image to region"
+    echo "-L  List images of the cluster"
     echo "-R Recreate podvm-images configMap"
 }
 
@@ -390,7 +684,7 @@ if [ "$1" = "--" ]; then
         ;;
     esac
 else
-    while getopts "cCRh" opt; do
+    while getopts "cCVRr:Lh" opt; do
         verify_vars
         case ${opt} in
         c)
@@ -401,6 +695,18 @@ else
             # Delete the ami
             delete_ami_using_id
 
+            ;;
+        V)
+            # Verify the ami to import
+            verify_ami_using_id
+            ;;
+        r)
+            # Copy the ami to another region
+            copy_ami_to_region "${OPTARG}"
+            ;;
+        L)
+            # List the amis of the cluster
+            list_amis_by_cluster_id
             ;;
         R)
             # Recreate the podvm-images configmap
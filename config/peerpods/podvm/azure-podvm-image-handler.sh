@@ -18,6 +18,8 @@
 # Delete image definition (-D)
 # Create image version (-i)
 # Delete image version (-I)
+# Replicate image to region (-r <region>)
+# List images of the cluster (-L)
 
 [[ "$DEBUG" == "true" ]] && set -x
 
@@ -427,6 +429,14 @@ function delete_image_id_annotation_from_peer_pods_cm() {
         return
     fi
 
+    # Leave the annotation alone if it refers to another image
+    latest_image_id=$(kubectl get configmap peer-pods-cm -n openshift-sandboxed-containers-operator \
+        -o jsonpath='{.metadata.annotations.LATEST_IMAGE_ID}')
+    if [[ -n "${latest_image_id}" && "${latest_image_id}" != "${IMAGE_ID}" ]]; then
+        echo "LATEST_IMAGE_ID annotation refers to another image. Skipping deleting the image id"
+        return
+    fi
+
     # Delete the image id annotation from peer-pods-cm configmap
     kubectl annotate configmap peer-pods-cm -n openshift-sandboxed-containers-operator \
         "LATEST_IMAGE_ID-" ||
@@ -505,6 +515,9 @@ function create_image() {
     # This will set the IMAGE_ID variable
     get_image_id
 
+    # Tag the image so that it's known to belong to the cluster
+    tag_image_with_cluster_id
+
     # Add the image id as annotation to peer-pods-cm configmap
     add_image_id_annotation_to_peer_pods_cm
 
@@ -780,8 +793,11 @@ function delete_image_gallery() {
         --gallery-name "${IMAGE_GALLERY_NAME}" ||
         error_exit "Failed to delete the image gallery"
 
-    # Remove the image gallery annotation from peer-pods-cm configmap
-    delete_image_gallery_annotation_from_peer_pods_cm
+    # Remove the image gallery annotation from peer-pods-cm configmap unless
+    # an orphaned gallery is deleted
+    if [[ "${UPDATE_PEERPODS_CM}" != "no" ]]; then
+        delete_image_gallery_annotation_from_peer_pods_cm
+    fi
 
     echo "Azure image gallery deleted successfully"
 }
@@ -807,11 +823,107 @@ function delete_image_using_id() {
     echo "Azure image deleted successfully"
 }
 
+# Function to verify that the image version to import exists and has been
+# provisioned successfully
+# Input is of the form
+# /subscriptions/<subscription-id>/resourceGroups/<resource-group>/providers/Microsoft.Compute/galleries/<gallery-name>/images/<image-name>/versions/<image-version>
+
+function verify_image_using_id() {
+    echo "Verifying Azure image"
+
+    # IMAGE_ID shouldn't be empty
+    [[ -z "${IMAGE_ID}" ]] && error_exit "IMAGE_ID is empty"
+
+    image_state=$(az sig image-version show --ids "${IMAGE_ID}" \
+        --query "provisioningState" --output tsv) ||
+        error_exit "Failed to get the image ${IMAGE_ID}"
+
+    [[ "${image_state}" != "Succeeded" ]] &&
+        error_exit "The image ${IMAGE_ID} is not usable, provisioning state: ${image_state}"
+
+    echo "Azure image ${IMAGE_ID} is available"
+}
+
+# Function to tag the image version with the cluster id so that orphaned
+# images of the cluster can be found later on
+# IMAGE_ID and CLUSTER_ID must be set as environment variables
+
+function tag_image_with_cluster_id() {
+    if [[ -z "${CLUSTER_ID}" ]]; then
+        echo "CLUSTER_ID is not set. Skipping tagging the image"
+        return
+    fi
+
+    echo "Tagging Azure image ${IMAGE_ID} with cluster id ${CLUSTER_ID}"
+
+    az resource tag --ids "${IMAGE_ID}" --is-incremental \
+        --tags "${PODVM_IMAGE_CLUSTER_TAG}=${CLUSTER_ID}" ||
+        error_exit "Failed to tag the image ${IMAGE_ID}"
+}
+
+# Function to list the image versions tagged with the cluster id in AZURE_RESOURCE_GROUP
+# along with the gallery the operator creates for the cluster, PodVMGallery_<cluster id>
+# The list is written to LISTED_IMAGES_FILE in the form [{"id":"<image or gallery id>"}]
+# Replicas in other regions share the id of the image version and aren't listed separately
+
+function list_images_by_cluster_id() {
+    echo "Listing Azure images of cluster ${CLUSTER_ID}"
+
+    [[ -z "${CLUSTER_ID}" ]] && error_exit "CLUSTER_ID is empty"
+
+    image_ids=$(az resource list --resource-group "${AZURE_RESOURCE_GROUP}" \
+        --resource-type "Microsoft.Compute/galleries/images/versions" \
+        --tag "${PODVM_IMAGE_CLUSTER_TAG}=${CLUSTER_ID}" \
+        --query "[].id" --output json) ||
+        error_exit "Failed to list the images"
+
+    gallery_ids=$(az sig list --resource-group "${AZURE_RESOURCE_GROUP}" \
+        --query "[?name=='PodVMGallery_${CLUSTER_ID}'].id" --output json) ||
+        error_exit "Failed to list the galleries"
+
+    images=$(jq -cn --argjson images "${image_ids}" --argjson galleries "${gallery_ids}" \
+        '$images + $galleries | map({id: .})')
+    echo "${images}" >"${LISTED_IMAGES_FILE}"
+    echo "Azure images of the cluster: ${images}"
+}
+
+# Function to add a region to the replication targets of the image version
+# IMAGE_ID must be set as an environment variable
+# Input: target region
+# The image id is the same in all regions, it's written to REPLICATED_IMAGE_ID_FILE
+
+function replicate_image_to_region() {
+    local region="${1}"
+    echo "Replicating Azure image ${IMAGE_ID} to ${region}"
+
+    [[ -z "${IMAGE_ID}" ]] && error_exit "IMAGE_ID is empty"
+    [[ -z "${region}" ]] && error_exit "Target region is empty"
+
+    # Region names are reported as display names (eg. "West Europe")
+    target_regions=$(az sig image-version show --ids "${IMAGE_ID}" \
+        --query "publishingProfile.targetRegions[].name" --output json) ||
+        error_exit "Failed to get the replication targets of the image ${IMAGE_ID}"
+
+    normalized_region=$(echo "${region}" | tr -d ' ' | tr '[:upper:]' '[:lower:]')
+    if echo "${target_regions}" | jq -e --arg r "${normalized_region}" \
+        'map(gsub(" "; "") | ascii_downcase) | index($r)' >/dev/null; then
+        echo "Image is already replicated to ${region}"
+    else
+        # This waits for the replication to finish
+        az sig image-version update --ids "${IMAGE_ID}" \
+            --add publishingProfile.targetRegions name="${region}" ||
+            error_exit "Failed to replicate the image ${IMAGE_ID} to ${region}"
+    fi
+
+    echo "${IMAGE_ID}" >"${REPLICATED_IMAGE_ID_FILE}"
+    echo "Azure image replicated to ${region}"
+}
+
 # display help message
 
 function display_help() {
     echo "This script is used to create Azure image for podvm"
-    echo "Usage: $0 [-c] [-C] [-g] [-G] [-d] [-D] [-i] [-I] [-h] [-- install_binaries|install_rpms|install_cli]"
+    echo "Usage: $0 [-c] [-C] [-g] [-G] [-d] [-D] [-i] [-I] [-V] [-r <region>] [-L] [-h] [-- install_binaries|install_rpms|install_cli]"
     echo "Options:"
     echo "-c Create image"
     echo "-C Delete image"
@@ -822,6 +934,9 @@ function display_help() {
     echo "-i Create image version"
     echo "-I Delete image version"
     echo "-R Recreate podvm-images configMap"
+    echo "-V Verify image to import"
+    echo "-r Replicate image to region"
+    echo "-L List images of the cluster"
     echo "-h Display help"
 }
 
@@ -851,7 +966,7 @@ if [ "$1" = "--" ]; then
         ;;
     esac
 else
-    while getopts ":cCgGdDiIRh" opt; do
+    while getopts ":cCgGdDiIRVr:Lh" opt; do
         verify_vars
         login_to_azure
         case ${opt} in
@@ -897,6 +1012,18 @@ else
             # Recreate the podvm-images configmap
             recreate_image_configmap
             ;;
+        V)
+            # Verify the image to import
+            verify_image_using_id
+            ;;
+        r)
+            # Replicate the image to another region
+            replicate_image_to_region "${OPTARG}"
+            ;;
+        L)
+            # List the images of the cluster
+            list_images_by_cluster_id
+            ;;
         h)
             display_help
             exit 0
@@ -9,6 +9,9 @@ PAUSE_IMAGE_REPO_DEFAULT="quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256"
 PAUSE_IMAGE_VERSION_DEFAULT="7f3cb6f9d265291b47a7491c2ba4f4dd0752a18b661eee40584f9a5dbcbe13bb"
 PAUSE_IMAGE_REPO_AUTH_FILE="/tmp/regauth/auth.json"
 
+# Tag holding the id of the cluster a pod VM image was created for
+PODVM_IMAGE_CLUSTER_TAG="osc-podvm-cluster-id"
+
 # function to trap errors and exit
 function error_exit() {
     echo "$1" 1>&2
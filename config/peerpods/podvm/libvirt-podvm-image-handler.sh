@@ -7,6 +7,7 @@
 # The script will be called with one of the following options:
 # Create image (-c)
 # Delete image (-C)
+# Import image (-i)
 
 # include common functions from lib.sh
 # shellcheck source=/dev/null
@@ -84,6 +85,32 @@ function create_libvirt_image_from_prebuilt_artifact() {
     esac
 }
 
+# Function to import a pre-built qcow2 image into the libvirt volume.
+# Either IMPORT_IMAGE_URL (URL of a qcow2 image) or PODVM_IMAGE_URI (OCI artifact)
+# must be set.
+function import_libvirt_image() {
+    if [[ -n "${IMPORT_IMAGE_URL}" ]]; then
+        echo "Downloading the podvm image from ${IMPORT_IMAGE_URL}"
+        PODVM_IMAGE_PATH="/tmp/podvm.qcow2"
+
+        curl -fsSL "${IMPORT_IMAGE_URL}" -o "${PODVM_IMAGE_PATH}" ||
+            error_exit "Failed to download the podvm image"
+
+        # Check whether the podvm image is a valid qcow2 or not.
+        validate_podvm_image "${PODVM_IMAGE_PATH}"
+
+        # Upload the downloaded qcow2 to the volume
+        upload_libvirt_image "${PODVM_IMAGE_PATH}"
+
+        # Add the libvirt_volume_name to peer-pods-cm configmap
+        add_libvirt_vol_to_peer_pods_cm
+    elif [[ -n "${PODVM_IMAGE_URI}" ]]; then
+        create_libvirt_image_from_prebuilt_artifact
+    else
+        error_exit "Neither IMPORT_IMAGE_URL nor PODVM_IMAGE_URI is set, exiting."
+    fi
+}
+
 # Function to create the libvirt image from scratch.
 function create_libvirt_image_from_scratch() {
     echo "Creating qcow2 image for libvirt provider from scratch"
@@ -241,10 +268,11 @@ function delete_libvirt_vol_from_peer_pods_cm() {
 
 function display_help() {
     echo "This script is used to create libvirt qcow2 for podvm"
-    echo "Usage: $0 [-c|-C] [-- install_binaries]"
+    echo "Usage: $0 [-c|-C|-i] [-- install_binaries]"
     echo "Options:"
     echo "-c  Create image"
     echo "-C  Delete image"
+    echo "-i  Import image"
 }
 
 function install_packages() {
@@ -324,7 +352,7 @@ if [ "$1" = "--" ]; then
         ;;
     esac
 else
-    while getopts "cCh" opt; do
+    while getopts "cCih" opt; do
         verify_vars
         case ${opt} in
         c)
@@ -335,6 +363,10 @@ else
             # Delete the libvirt image
             delete_libvirt_image
             ;;
+        i)
+            # Import a pre-built libvirt image
+            import_libvirt_image
+            ;;
         h)
             # Display help
             display_help
@@ -1,6 +1,11 @@
 #!/bin/bash
 #
 
+# File the provider scripts write the ID of a replicated image to
+export REPLICATED_IMAGE_ID_FILE="/tmp/replicated_image_id"
+# File the provider scripts write the list of images of the cluster to
+export LISTED_IMAGES_FILE="/tmp/listed_images"
+
 # Function to install Azure deps
 function install_azure_deps() {
   echo "Installing Azure deps"
@@ -38,7 +43,8 @@ function set_podvm_image_type() {
     echo "Checking if PODVM_IMAGE_URI is set"
 
     # If the value of the PODVM_IMAGE_URI is empty or not set, then build the image from scratch else use the prebuilt artifact.
-    if [[ -z "${PODVM_IMAGE_URI}" ]]; then
+    # An imported image is never built.
+    if [[ -z "${PODVM_IMAGE_URI}" && -z "${IMPORT_IMAGE_REF}" ]]; then
       IMAGE_TYPE="operator-built"
       echo "Initiating the operator to build the podvm image"
     else
@@ -50,8 +56,18 @@ function set_podvm_image_type() {
 
 }
 
+# Function to remove the IMPORTED_PODVM_IMAGE annotation from peer-pods-cm configmap
+# The annotation marks the image in use as imported and is removed when a new image is built
+function delete_imported_image_annotation() {
+  if check_peer_pods_cm_exists; then
+    kubectl annotate configmap peer-pods-cm -n openshift-sandboxed-containers-operator "IMPORTED_PODVM_IMAGE-"
+  fi
+}
+
 # Function to create podvm image
 function create_podvm_image() {
+  delete_imported_image_annotation
+
   case "${CLOUD_PROVIDER}" in
   azure)
     echo "Creating Azure image"
@@ -118,6 +134,179 @@ function create_podvm_image() {
   esac
 }
 
+# Function to import a pre-built podvm image
+# The image is validated with the cloud provider (and uploaded for libvirt) and
+# recorded in the peer-pods-cm configmap, it's not built.
+# IMPORT_IMAGE_REF is the reference of the image as given in the kataConfig and
+# is recorded in the IMPORTED_PODVM_IMAGE annotation of peer-pods-cm. The
+# operator never deletes an image carrying this annotation.
+# AMI_ID (aws), IMAGE_ID (azure) and IMPORT_IMAGE_URL or PODVM_IMAGE_URI
+# (libvirt) are the provider specific inputs
+
+function import_podvm_image() {
+  if [ -z "${IMPORT_IMAGE_REF}" ]; then
+    echo "IMPORT_IMAGE_REF is not set. Skipping image import"
+    exit 1
+  fi
+
+  if ! check_peer_pods_cm_exists; then
+    echo "peer-pods-cm configmap does not exist. Skipping image import"
+    exit 1
+  fi
+
+  case "${CLOUD_PROVIDER}" in
+  azure)
+    echo "Importing Azure image ${IMAGE_ID}"
+    /scripts/azure-podvm-image-handler.sh -V || exit 1
+
+    echo "Updating peer-pods-cm configmap with IMAGE_ID=${IMAGE_ID}"
+    kubectl patch configmap peer-pods-cm -n openshift-sandboxed-containers-operator --type merge -p "{\"data\":{\"AZURE_IMAGE_ID\":\"${IMAGE_ID}\"}}" || exit 1
+    ;;
+  aws)
+    echo "Importing AWS AMI ${AMI_ID}"
+    /scripts/aws-podvm-image-handler.sh -V || exit 1
+
+    echo "Updating peer-pods-cm configmap with AMI_ID=${AMI_ID}"
+    kubectl patch configmap peer-pods-cm -n openshift-sandboxed-containers-operator --type merge -p "{\"data\":{\"PODVM_AMI_ID\":\"${AMI_ID}\"}}" || exit 1
+    ;;
+  libvirt)
+    echo "Importing Libvirt qcow2 ${IMPORT_IMAGE_REF}"
+    # The handler updates peer-pods-cm with the libvirt volume name
+    /scripts/libvirt-podvm-image-handler.sh -i || exit 1
+    ;;
+  *)
+    echo "CLOUD_PROVIDER is not set to azure or aws or libvirt"
+    exit 1
+    ;;
+  esac
+
+  kubectl annotate --overwrite configmap peer-pods-cm -n openshift-sandboxed-containers-operator \
+    "IMPORTED_PODVM_IMAGE=${IMPORT_IMAGE_REF}" || exit 1
+}
+
+# Function to replicate the podvm image in peer-pods-cm to other regions
+# TARGET_REGIONS is a comma separated list of regions to replicate the image to
+# The result is merged into the PODVM_IMAGE_REGIONS key of peer-pods-cm in the form
+# {"sourceImageID":"<image id>","regions":{"<region>":{"imageID":"<image id>"},"<region>":{"error":"<message>"}}}
+# A failure in one region doesn't stop the replication to the other regions and
+# doesn't fail the function
+
+function replicate_podvm_image() {
+  if [ -z "${TARGET_REGIONS}" ]; then
+    echo "TARGET_REGIONS is not set. Skipping image replication"
+    exit 1
+  fi
+
+  if ! check_peer_pods_cm_exists; then
+    echo "peer-pods-cm configmap does not exist. Skipping image replication"
+    exit 1
+  fi
+
+  case "${CLOUD_PROVIDER}" in
+  azure)
+    source_image_id=$(kubectl get configmap peer-pods-cm -n openshift-sandboxed-containers-operator -o jsonpath='{.data.AZURE_IMAGE_ID}')
+    export IMAGE_ID="${source_image_id}"
+    ;;
+  aws)
+    source_image_id=$(kubectl get configmap peer-pods-cm -n openshift-sandboxed-containers-operator -o jsonpath='{.data.PODVM_AMI_ID}')
+    export AMI_ID="${source_image_id}"
+    ;;
+  *)
+    echo "Image replication is only supported for azure and aws"
+    exit 1
+    ;;
+  esac
+
+  if [ -z "${source_image_id}" ]; then
+    echo "Image id is not set in peer-pods-cm. Skipping image replication"
+    exit 1
+  fi
+
+  # Start over if the recorded replicas belong to another image
+  image_regions=$(kubectl get configmap peer-pods-cm -n openshift-sandboxed-containers-operator -o jsonpath='{.data.PODVM_IMAGE_REGIONS}')
+  if [ -z "${image_regions}" ] || [ "$(echo "${image_regions}" | jq -r '.sourceImageID')" != "${source_image_id}" ]; then
+    image_regions=$(jq -cn --arg id "${source_image_id}" '{sourceImageID: $id, regions: {}}')
+  fi
+
+  err_file=$(mktemp)
+  IFS=',' read -ra regions <<<"${TARGET_REGIONS}"
+  for region in "${regions[@]}"; do
+    echo "Replicating image ${source_image_id} to ${region}"
+    rm -f "${REPLICATED_IMAGE_ID_FILE}"
+
+    if "/scripts/${CLOUD_PROVIDER}-podvm-image-handler.sh" -r "${region}" 2>"${err_file}"; then
+      image_id=$(cat "${REPLICATED_IMAGE_ID_FILE}")
+      echo "Image replicated to ${region}: ${image_id}"
+      image_regions=$(echo "${image_regions}" | jq -c --arg r "${region}" --arg id "${image_id}" '.regions[$r] = {imageID: $id}')
+    else
+      message=$(tail -n 1 "${err_file}")
+      echo "Failed to replicate image to ${region}: ${message}"
+      image_regions=$(echo "${image_regions}" | jq -c --arg r "${region}" --arg msg "${message}" '.regions[$r] = {error: $msg}')
+    fi
+    cat "${err_file}" >&2
+  done
+  rm -f "${err_file}"
+
+  echo "Updating peer-pods-cm configmap with PODVM_IMAGE_REGIONS=${image_regions}"
+  patch=$(jq -cn --arg v "${image_regions}" '{data: {PODVM_IMAGE_REGIONS: $v}}')
+  kubectl patch configmap peer-pods-cm -n openshift-sandboxed-containers-operator --type merge -p "${patch}" || exit 1
+}
+
+# Function to list the podvm images tagged with the cluster id
+# CLUSTER_ID is the id of the cluster, TARGET_REGIONS optionally lists
+# additional regions to look for images in
+# The list is recorded in the PODVM_CLUSTER_IMAGES annotation of peer-pods-cm in the form
+# [{"id":"<image id>","region":"<region>"}], region is omitted for the region of the cluster
+
+function list_podvm_images() {
+  if [ -z "${CLUSTER_ID}" ]; then
+    echo "CLUSTER_ID is not set. Skipping image listing"
+    exit 1
+  fi
+
+  if ! check_peer_pods_cm_exists; then
+    echo "peer-pods-cm configmap does not exist. Skipping image listing"
+    exit 1
+  fi
+
+  case "${CLOUD_PROVIDER}" in
+  azure | aws)
+    rm -f "${LISTED_IMAGES_FILE}"
+    "/scripts/${CLOUD_PROVIDER}-podvm-image-handler.sh" -L || exit 1
+    ;;
+  *)
+    echo "Image listing is only supported for azure and aws"
+    exit 1
+    ;;
+  esac
+
+  images=$(cat "${LISTED_IMAGES_FILE}")
+  echo "Updating peer-pods-cm configmap with PODVM_CLUSTER_IMAGES=${images}"
+  kubectl annotate --overwrite configmap peer-pods-cm -n openshift-sandboxed-containers-operator \
+    "PODVM_CLUSTER_IMAGES=${images}" || exit 1
+}
+
+# Function to delete the copies of the AMI_ID ami in other regions which are
+# recorded in the PODVM_IMAGE_REGIONS key of peer-pods-cm
+
+function delete_replicated_amis() {
+  image_regions=$(kubectl get configmap peer-pods-cm -n openshift-sandboxed-containers-operator -o jsonpath='{.data.PODVM_IMAGE_REGIONS}')
+  if [ -z "${image_regions}" ]; then
+    return
+  fi
+
+  if [ "$(echo "${image_regions}" | jq -r '.sourceImageID')" != "${AMI_ID}" ]; then
+    echo "PODVM_IMAGE_REGIONS in peer-pods-cm doesn't belong to ${AMI_ID}. Skipping the deletion of AMI copies"
+    return
+  fi
+
+  echo "${image_regions}" | jq -r '.regions | to_entries[] | select(.value.imageID) | "\(.key) \(.value.imageID)"' |
+    while read -r region image_id; do
+      echo "Deleting AWS AMI ${image_id} in ${region}"
+      AWS_REGION="${region}" AMI_ID="${image_id}" /scripts/aws-podvm-image-handler.sh -C
+    done
+}
+
 # Function to delete podvm image
 # IMAGE_ID or AMI_ID is the input and expected to be set
 # These are checked in individual cloud provider scripts and if not set, the script will exit
@@ -173,8 +362,9 @@ function delete_podvm_image() {
     /scripts/azure-podvm-image-handler.sh -C
 
     # Update the peer-pods-cm configmap and remove the AZURE_IMAGE_ID value
+    # The replicas in other regions are deleted along with the image version
     if [ "${UPDATE_PEERPODS_CM}" == "yes" ]; then
-      kubectl patch configmap peer-pods-cm -n openshift-sandboxed-containers-operator --type merge -p "{\"data\":{\"AZURE_IMAGE_ID\":\"\"}}"
+      kubectl patch configmap peer-pods-cm -n openshift-sandboxed-containers-operator --type merge -p "{\"data\":{\"AZURE_IMAGE_ID\":\"\",\"PODVM_IMAGE_REGIONS\":\"\"}}"
     fi
 
     # If delete_gallery is set, then delete the image gallery
@@ -211,9 +401,12 @@ function delete_podvm_image() {
     echo "Deleting AWS AMI"
     /scripts/aws-podvm-image-handler.sh -C
 
+    # Delete the copies of the AMI in other regions
+    delete_replicated_amis
+
     # Update the peer-pods-cm configmap and remove the PODVM_AMI_ID value
     if [ "${UPDATE_PEERPODS_CM}" == "yes" ]; then
-      kubectl patch configmap peer-pods-cm -n openshift-sandboxed-containers-operator --type merge -p "{\"data\":{\"PODVM_AMI_ID\":\"\"}}"
+      kubectl patch configmap peer-pods-cm -n openshift-sandboxed-containers-operator --type merge -p "{\"data\":{\"PODVM_AMI_ID\":\"\",\"PODVM_IMAGE_REGIONS\":\"\"}}"
     fi
 
     ;;
@@ -260,6 +453,8 @@ function delete_podvm_image() {
 # Delete the podvm image gallery in Azure
 # It accepts an optional argument
 # -f : force delete the image gallery
+# The gallery recorded in peer-pods-cm is deleted unless GC_GALLERY_NAME names
+# an orphaned gallery, in which case peer-pods-cm is left alone
 
 function delete_podvm_image_gallery() {
   echo "Deleting Azure image gallery"
@@ -269,6 +464,14 @@ function delete_podvm_image_gallery() {
     return
   fi
 
+  if [ -n "${GC_GALLERY_NAME}" ]; then
+    echo "Deleting orphaned Azure image gallery ${GC_GALLERY_NAME}"
+    export IMAGE_GALLERY_NAME="${GC_GALLERY_NAME}"
+    export UPDATE_PEERPODS_CM="no"
+    /scripts/azure-podvm-image-handler.sh -G force
+    return
+  fi
+
   # Check if peer-pods-cm configmap exists
   if ! check_peer_pods_cm_exists; then
     echo "peer-pods-cm configmap does not exist. Skipping image gallery deletion"
@@ -292,7 +495,7 @@ function delete_podvm_image_gallery() {
 }
 
 function display_usage() {
-  echo "Usage: $0 {create|delete [-f] [-g]|delete-gallery [-f]}"
+  echo "Usage: $0 {create|import|replicate|list|delete [-f] [-g]|delete-gallery [-f]}"
 }
 
 # Set the PodVM image type based on the `PODVM_IMAGE_URI`
@@ -322,6 +525,15 @@ case "$1" in
 create)
   create_podvm_image
   ;;
+import)
+  import_podvm_image
+  ;;
+replicate)
+  replicate_podvm_image
+  ;;
+list)
+  list_podvm_images
+  ;;
 delete)
   # Pass the arguments to delete_podvm_image function except the first argument
   shift
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	operatorRulesName = "sandboxed-containers-operator-rules"
+	operatorRuleGroup = "sandboxed_containers_operator_rules"
+
+	// The runbook of an alert is docs/runbooks/<alert>.md
+	runbookBaseURL = "https://github.com/openshift/sandboxed-containers-operator/blob/main/docs/runbooks/"
+
+	defaultInstallStuckMinutes   = 60
+	defaultPeerPodFailurePercent = 20
+)
+
+// Returns the thresholds of the KataConfigInstallStuck,
+// KataConfigWaitingForMcoToStart and PeerPodsFailureRatioHigh alerts
+func (r *KataConfigOpenShiftReconciler) getOperatorAlertThresholds() (int32, int32) {
+	stuckMinutes, failurePercent := int32(defaultInstallStuckMinutes), int32(defaultPeerPodFailurePercent)
+	if r.kataConfig.Spec.Monitoring == nil || r.kataConfig.Spec.Monitoring.Alerts == nil {
+		return stuckMinutes, failurePercent
+	}
+	alerts := r.kataConfig.Spec.Monitoring.Alerts
+	if alerts.InstallStuckMinutes != nil {
+		stuckMinutes = *alerts.InstallStuckMinutes
+	}
+	if alerts.PeerPodFailurePercent != nil {
+		failurePercent = *alerts.PeerPodFailurePercent
+	}
+	return stuckMinutes, failurePercent
+}
+
+func newAlertRule(alert string, expr string, forMinutes int32, severity string, summary string, description string) monitoringv1.Rule {
+	rule := monitoringv1.Rule{
+		Alert: alert,
+		Expr:  intstr.FromString(expr),
+		Labels: map[string]string{
+			"severity": severity,
+		},
+		Annotations: map[string]string{
+			"summary":     summary,
+			"description": description,
+			"runbook_url": runbookBaseURL + alert + ".md",
+		},
+	}
+	if forMinutes > 0 {
+		duration := monitoringv1.Duration(fmt.Sprintf("%dm", forMinutes))
+		rule.For = &duration
+	}
+	return rule
+}
+
+// The alerts on the KataConfig rollouts and the peer pods, based on the
+// metrics of the exporter
+func (r *KataConfigOpenShiftReconciler) getOperatorRuleGroups() []monitoringv1.RuleGroup {
+	stuckMinutes, failurePercent := r.getOperatorAlertThresholds()
+
+	return []monitoringv1.RuleGroup{
+		{
+			Name: operatorRuleGroup,
+			Rules: []monitoringv1.Rule{
+				newAlertRule("KataConfigNodesFailed",
+					`sum(kata_config_nodes{state=~"failed|failed_to_uninstall"}) > 0`,
+					5, "critical",
+					"Nodes failed to install or uninstall kata",
+					"{{ $value }} node(s) failed to install or uninstall kata, they are listed in the status.kataNodes of the KataConfig."),
+				newAlertRule("KataConfigInstallStuck",
+					fmt.Sprintf(`(time() - kata_config_last_progress_time_seconds) > %d and on() kata_config_condition{condition="InProgress",status="True"} == 1`, stuckMinutes*60),
+					0, "warning",
+					"The KataConfig rollout makes no progress",
+					fmt.Sprintf("The KataConfig installation, update or uninstallation has been in progress for over %d minutes without any change to its status.", stuckMinutes)),
+				newAlertRule("KataConfigWaitingForMcoToStart",
+					"kata_config_waiting_for_mco_to_start == 1",
+					stuckMinutes, "warning",
+					"The MCO doesn't start updating the kata nodes",
+					fmt.Sprintf("The operator has been waiting for %d minutes for the MCO to start rolling out the kata MachineConfigs.", stuckMinutes)),
+				// Failed attempts are retried, only alert once the retry
+				// budget is spent
+				newAlertRule("PodVMImageCreationFailed",
+					`kata_config_condition{condition="PodVMImageBuildFailed",status="True"} == 1`,
+					5, "warning",
+					"Pod VM image creation failed",
+					"All pod VM image creation attempts failed, the last failure is recorded in the status.podVMImage of the KataConfig. Peer pods can't start until an image is created."),
+				newAlertRule("PeerPodsFailureRatioHigh",
+					fmt.Sprintf("kata_remote_workload_failure_ratio > %d", failurePercent),
+					15, "warning",
+					"Many peer pods are failing",
+					fmt.Sprintf("{{ $value | humanize }}%% of the kata-remote pods have failed or are crash looping, above the %d%% threshold.", failurePercent)),
+			},
+		},
+	}
+}
+
+// Creates or updates the PrometheusRule of the operator alerts, or removes
+// it if monitoring is disabled.  It's in place for the whole lifetime of the
+// KataConfig, the alerts cover failed and stuck installations and
+// uninstallations.
+func (r *KataConfigOpenShiftReconciler) reconcileOperatorAlertRules() error {
+	if !r.isKataMonitorEnabled() {
+		return r.deleteOperatorAlertRules()
+	}
+
+	rule := &monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{Name: operatorRulesName, Namespace: OperatorNamespace}}
+	err := r.createOrUpdateOwnedObject(rule, func() {
+		rule.Spec.Groups = r.getOperatorRuleGroups()
+	})
+	if meta.IsNoMatchError(err) {
+		r.Log.Info("monitoring.coreos.com API unavailable, not creating the operator alerts")
+		return nil
+	} else if err != nil {
+		r.Log.Info("error creating or updating the operator PrometheusRule", "err", err)
+		return err
+	}
+	return nil
+}
+
+func (r *KataConfigOpenShiftReconciler) deleteOperatorAlertRules() error {
+	rule := &monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{Name: operatorRulesName, Namespace: OperatorNamespace}}
+	err := r.Client.Delete(context.TODO(), rule)
+	if err != nil && !k8serrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		r.Log.Info("error deleting the operator PrometheusRule", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOperatorAlertRules(t *testing.T) {
+	g := NewWithT(t)
+	stuckMinutes := int32(90)
+	kataConfig := &kataconfigurationv1.KataConfig{}
+	kataConfig.Spec.Monitoring = &kataconfigurationv1.MonitoringSpec{
+		Alerts: &kataconfigurationv1.MonitoringAlertsSpec{InstallStuckMinutes: &stuckMinutes},
+	}
+	r := newTestReconciler(t, kataConfig, monitoringTestSchemes)
+
+	g.Expect(r.reconcileOperatorAlertRules()).To(Succeed())
+	rule := &monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{Name: operatorRulesName, Namespace: OperatorNamespace}}
+	expectObjectExists(g, r.Client, rule, true)
+	g.Expect(metav1.IsControlledBy(rule, r.kataConfig)).To(BeTrue())
+
+	alerts := map[string]monitoringv1.Rule{}
+	for _, promRule := range rule.Spec.Groups[0].Rules {
+		alerts[promRule.Alert] = promRule
+		// Every alert links to its runbook
+		g.Expect(promRule.Annotations["runbook_url"]).To(HaveSuffix("/docs/runbooks/" + promRule.Alert + ".md"))
+	}
+	g.Expect(alerts).To(HaveKey("KataConfigNodesFailed"))
+	g.Expect(alerts["PodVMImageCreationFailed"].Expr.StrVal).To(ContainSubstring(`condition="PodVMImageBuildFailed"`))
+	g.Expect(string(*alerts["PodVMImageCreationFailed"].For)).To(Equal("5m"))
+	g.Expect(alerts["KataConfigInstallStuck"].Expr.StrVal).To(ContainSubstring("> 5400 "))
+	g.Expect(string(*alerts["KataConfigWaitingForMcoToStart"].For)).To(Equal("90m"))
+	g.Expect(alerts["PeerPodsFailureRatioHigh"].Expr.StrVal).To(HaveSuffix("> 20"))
+
+	// Disabling monitoring removes the alerts
+	enabled := false
+	r.kataConfig.Spec.Monitoring.Enabled = &enabled
+	g.Expect(r.reconcileOperatorAlertRules()).To(Succeed())
+	expectObjectExists(g, r.Client, rule, false)
+}
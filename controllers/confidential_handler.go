@@ -20,10 +20,10 @@ func (r *KataConfigOpenShiftReconciler) handleFeatureConfidential(state FeatureG
 
 	// ImageConfigMap
 
-	if err := InitializeImageGenerator(r.Client); err != nil {
+	ig, err := r.getImageGenerator()
+	if err != nil {
 		return err
 	}
-	ig := GetImageGenerator()
 
 	if ig.provider == unsupportedCloudProvider {
 		r.Log.Info("unsupported cloud provider, skipping confidential image configuration")
@@ -18,9 +18,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
 
 	"github.com/go-logr/logr"
 	v1 "github.com/openshift/cloud-credential-operator/pkg/apis/cloudcredential/v1"
@@ -45,12 +46,15 @@ type SecretReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// Peer-pods manifests, defaults to the ones embedded in the operator
+	// binary
+	Manifests fs.FS
 }
 
 const (
-	credentialsRequestSecretRefName         = "cco-secret"
-	peerpodsCredentialsRequestsPathLocation = "/config/peerpods/credentials-requests"
-	peerpodsCredentialsRequestFileFormat    = "credentials_request_%s.yaml"
+	credentialsRequestSecretRefName      = "cco-secret"
+	peerpodsCredentialsRequestFileFormat = "credentials_request_%s.yaml"
 
 	// labelCredentialsRequest is to mark Secrets as created using cloud-credentials-operator
 	labelCredentialsRequest      = "kataconfiguration.openshift.io/credentials-request-based"
@@ -215,10 +219,16 @@ func (kh *KataConfigHandler) Create(ctx context.Context, event event.CreateEvent
 // kataConfig updated, create/delete credentialRequest if peerPods enabled/disabled
 func (kh *KataConfigHandler) Update(ctx context.Context, event event.UpdateEvent, queue workqueue.RateLimitingInterface) {
 	kh.reconciler.Log.Info("KataConfig Update event")
-	if event.ObjectNew.(*kataconfigurationv1.KataConfig).Spec.EnablePeerPods {
+	kataConfig := event.ObjectNew.(*kataconfigurationv1.KataConfig)
+	if kataConfig.Spec.EnablePeerPods {
 		if err := kh.createCredentialsRequests(); err != nil {
 			kh.reconciler.Log.Info("error in creating credentialsRequests", "err", err)
 		}
+	} else if contains(kataConfig.Status.RuntimeClasses, peerpodsRuntimeClassName) {
+		// The pod VM image deletion job run while disabling peer pods
+		// needs the credentials.  The status update at the end of the
+		// teardown brings us back here.
+		kh.reconciler.Log.Info("peer pods are being disabled, keeping credentialsRequests")
 	} else {
 		if err := kh.deleteCredentialsRequests(); err != nil {
 			kh.reconciler.Log.Info("error in deleting credentialsRequests", "err", err)
@@ -294,9 +304,9 @@ func (kh *KataConfigHandler) getCredentialsRequest() (*v1.CredentialsRequest, er
 	}
 
 	fileName := fmt.Sprintf(peerpodsCredentialsRequestFileFormat, provider)
-	credentialsRequestsYamlFile := filepath.Join(peerpodsCredentialsRequestsPathLocation, fileName)
-	yamlData, err := readYamlFile(credentialsRequestsYamlFile)
-	if os.IsNotExist(err) {
+	credentialsRequestsYamlFile := path.Join(peerpodsCredentialsRequestsManifestDir, fileName)
+	yamlData, err := fs.ReadFile(peerPodsManifestsOrDefault(kh.reconciler.Manifests), credentialsRequestsYamlFile)
+	if errors.Is(err, fs.ErrNotExist) {
 		kh.reconciler.Log.Info("no CredentialsRequestYAML for provider", "err", err, "provider", provider)
 		return nil, nil
 	} else if err != nil {
@@ -326,8 +336,8 @@ func (kh *KataConfigHandler) skipCredentialRequests() bool {
 	// check if CredentialsRequest implementation exists for the cloud provider
 	if provider, err := getCloudProviderFromInfra(kh.reconciler.Client); err == nil {
 		fileName := fmt.Sprintf(peerpodsCredentialsRequestFileFormat, provider)
-		credentialsRequestsYamlFile := filepath.Join(peerpodsCredentialsRequestsPathLocation, fileName)
-		if _, err := readYamlFile(credentialsRequestsYamlFile); os.IsNotExist(err) {
+		credentialsRequestsYamlFile := path.Join(peerpodsCredentialsRequestsManifestDir, fileName)
+		if _, err := fs.Stat(peerPodsManifestsOrDefault(kh.reconciler.Manifests), credentialsRequestsYamlFile); errors.Is(err, fs.ErrNotExist) {
 			kh.reconciler.Log.Info("no CredentialsRequest yaml file for provider, skipping", "provider", provider, "filename", fileName)
 			return true
 		}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"io/fs"
+	"os"
+
+	"github.com/openshift/sandboxed-containers-operator/config/dashboards"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// Environment variable holding the version of the operator, the
+	// dashboards are refreshed when it changes
+	OperatorVersionEnv = "OPERATOR_VERSION"
+
+	// Label having the OpenShift console pick up a dashboard
+	dashboardLabel = "console.openshift.io/dashboard"
+	// Annotation recording the version of the operator a dashboard was
+	// created or last updated by
+	dashboardVersionAnnotation = "kataconfiguration.openshift.io/operator-version"
+)
+
+// A Grafana dashboard the operator adds to the console
+type dashboard struct {
+	// Name of the ConfigMap in openshift-config-managed
+	configMapName string
+	// File of the dashboard in config/dashboards, also used as the key of
+	// the ConfigMap
+	file string
+	// Whether the dashboard is relevant to the KataConfig
+	isWanted func(r *KataConfigOpenShiftReconciler) bool
+}
+
+func isPeerPodsEnabled(r *KataConfigOpenShiftReconciler) bool {
+	return r.kataConfig.Spec.EnablePeerPods
+}
+
+var operatorDashboards = []dashboard{
+	{
+		configMapName: dashboard_configmap_name,
+		file:          "kata-resources.json",
+		isWanted:      (*KataConfigOpenShiftReconciler).isKataMonitorEnabled,
+	},
+	{
+		configMapName: dashboard_configmap_name + "-peer-pods",
+		file:          "peer-pods.json",
+		isWanted:      isPeerPodsEnabled,
+	},
+	{
+		configMapName: dashboard_configmap_name + "-podvm-image",
+		file:          "podvm-image.json",
+		isWanted:      isPeerPodsEnabled,
+	},
+}
+
+// Creates the dashboards relevant to the KataConfig, updates the ones
+// created by another operator version and removes the ones no longer
+// relevant
+func (r *KataConfigOpenShiftReconciler) reconcileDashboards() error {
+	version := os.Getenv(OperatorVersionEnv)
+
+	for _, d := range operatorDashboards {
+		if !d.isWanted(r) {
+			if err := r.deleteDashboard(d); err != nil {
+				return err
+			}
+			continue
+		}
+
+		cm := &corev1.ConfigMap{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: d.configMapName, Namespace: dashboard_configmap_namespace}, cm)
+		if err == nil && version != "" && cm.Annotations[dashboardVersionAnnotation] == version {
+			continue
+		} else if err != nil && !k8serrors.IsNotFound(err) {
+			r.Log.Info("could not get dashboard", "name", d.configMapName, "err", err)
+			return err
+		}
+
+		data, err := fs.ReadFile(dashboards.Dashboards, d.file)
+		if err != nil {
+			return err
+		}
+
+		r.Log.Info("Creating or updating dashboard in the OpenShift console", "name", d.configMapName, "version", version)
+		cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: d.configMapName, Namespace: dashboard_configmap_namespace}}
+		if err := r.createOrUpdateOwnedObject(cm, func() {
+			if cm.Labels == nil {
+				cm.Labels = map[string]string{}
+			}
+			cm.Labels[dashboardLabel] = "true"
+			if cm.Annotations == nil {
+				cm.Annotations = map[string]string{}
+			}
+			cm.Annotations[dashboardVersionAnnotation] = version
+			cm.Data = map[string]string{d.file: string(data)}
+		}); err != nil {
+			r.Log.Info("error creating or updating dashboard", "name", d.configMapName, "err", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *KataConfigOpenShiftReconciler) deleteDashboard(d dashboard) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: d.configMapName, Namespace: dashboard_configmap_namespace}}
+	err := r.Client.Delete(context.TODO(), cm)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		r.Log.Info("error deleting dashboard", "name", d.configMapName, "err", err)
+		return err
+	}
+	return nil
+}
+
+// Removes all the dashboards of the operator
+func (r *KataConfigOpenShiftReconciler) deleteDashboards() error {
+	for _, d := range operatorDashboards {
+		if err := r.deleteDashboard(d); err != nil {
+			return err
+		}
+	}
+	r.Log.Info("dashboards removed")
+	return nil
+}
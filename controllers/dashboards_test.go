@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDashboards(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+	t.Setenv(OperatorVersionEnv, "1.7.0")
+
+	getDashboard := func(name string) (*corev1.ConfigMap, error) {
+		cm := &corev1.ConfigMap{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: dashboard_configmap_namespace}, cm)
+		return cm, err
+	}
+	peerPodsDashboard := dashboard_configmap_name + "-peer-pods"
+
+	// Without peer pods only the kata dashboard is created
+	g.Expect(r.reconcileDashboards()).To(Succeed())
+	cm, err := getDashboard(dashboard_configmap_name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Labels).To(HaveKeyWithValue(dashboardLabel, "true"))
+	g.Expect(cm.Annotations).To(HaveKeyWithValue(dashboardVersionAnnotation, "1.7.0"))
+	g.Expect(cm.Data["kata-resources.json"]).To(ContainSubstring("kata_guest_load"))
+	_, err = getDashboard(peerPodsDashboard)
+	g.Expect(err).To(HaveOccurred())
+
+	// An up to date dashboard is left alone
+	cm.Data["kata-resources.json"] = "{}"
+	g.Expect(r.Client.Update(context.TODO(), cm)).To(Succeed())
+	r.kataConfig.Spec.EnablePeerPods = true
+	g.Expect(r.reconcileDashboards()).To(Succeed())
+	cm, _ = getDashboard(dashboard_configmap_name)
+	g.Expect(cm.Data["kata-resources.json"]).To(Equal("{}"))
+	cm, err = getDashboard(peerPodsDashboard)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data["peer-pods.json"]).To(ContainSubstring("kata_remote_pod_vms"))
+
+	// A new operator version updates the dashboards
+	t.Setenv(OperatorVersionEnv, "1.8.0")
+	g.Expect(r.reconcileDashboards()).To(Succeed())
+	cm, _ = getDashboard(dashboard_configmap_name)
+	g.Expect(cm.Annotations).To(HaveKeyWithValue(dashboardVersionAnnotation, "1.8.0"))
+	g.Expect(cm.Data["kata-resources.json"]).To(ContainSubstring("kata_guest_load"))
+
+	// Uninstalling removes them all
+	g.Expect(r.deleteDashboards()).To(Succeed())
+	for _, d := range operatorDashboards {
+		_, err = getDashboard(d.configMapName)
+		g.Expect(err).To(HaveOccurred())
+	}
+}
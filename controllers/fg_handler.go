@@ -9,14 +9,16 @@ import (
 )
 
 const (
-	FgConfigMapName         = "osc-feature-gates"
-	ConfidentialFeatureGate = "confidential"
-	LayeredImageDeployment  = "layeredImageDeployment"
+	FgConfigMapName          = "osc-feature-gates"
+	ConfidentialFeatureGate  = "confidential"
+	LayeredImageDeployment   = "layeredImageDeployment"
+	InProcessPeerPodsWebhook = "inProcessPeerPodsWebhook"
 )
 
 var DefaultFeatureGates = map[string]bool{
-	ConfidentialFeatureGate: false,
-	LayeredImageDeployment:  false,
+	ConfidentialFeatureGate:  false,
+	LayeredImageDeployment:   false,
+	InProcessPeerPodsWebhook: false,
 }
 
 type FeatureGateStatus struct {
@@ -78,6 +80,7 @@ func (r *KataConfigOpenShiftReconciler) processFeatureGates() error {
 		r.Log.Info("There were errors in getting feature gate status.", "err", err)
 		return err
 	}
+	recordFeatureGateMetrics(fgStatus)
 
 	// Check which feature gates are enabled in the FG ConfigMap and
 	// perform the necessary actions
@@ -97,6 +100,11 @@ func (r *KataConfigOpenShiftReconciler) processFeatureGates() error {
 		}
 	}
 
+	// Check the in-process peer pods webhook FG, enablePeerPodsMiscConfigs
+	// sets the webhook up accordingly
+	r.inProcessPeerPodsWebhook = IsEnabled(fgStatus, InProcessPeerPodsWebhook)
+	r.Log.Info("Feature gate state", "featuregate", InProcessPeerPodsWebhook, "enabled", r.inProcessPeerPodsWebhook)
+
 	// Check layered Image deployment FG
 	if IsEnabled(fgStatus, LayeredImageDeployment) {
 		r.Log.Info("Feature gate is enabled", "featuregate", LayeredImageDeployment)
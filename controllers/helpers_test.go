@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/peerpodconfig-ctrl/api/v1alpha1"
+	. "github.com/onsi/gomega"
+	mcfgapi "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// These helpers back the unit tests of the reconciler.  Unlike the Ginkgo
+// suite in suite_test.go they don't start an envtest API server: they run a
+// single reconcile step against the controller-runtime fake client, so they
+// need no kube-apiserver or etcd binaries and can inject API errors through
+// interceptors.  They are plain go tests using gomega and run with the suite.
+
+// The APIs the peer pods tests need on top of the core and KataConfig ones
+var peerPodsTestSchemes = []func(*runtime.Scheme) error{
+	mcfgapi.Install,
+	v1alpha1.AddToScheme,
+}
+
+// The APIs the monitoring tests need on top of the core and KataConfig ones
+var monitoringTestSchemes = []func(*runtime.Scheme) error{
+	monitoringv1.AddToScheme,
+}
+
+// Returns a scheme with the core and KataConfig APIs plus the given ones
+func newTestScheme(t *testing.T, addToSchemes ...func(*runtime.Scheme) error) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	addToSchemes = append([]func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		kataconfigurationv1.AddToScheme,
+	}, addToSchemes...)
+	for _, addToScheme := range addToSchemes {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return scheme
+}
+
+// Returns a reconciler for the given KataConfig whose fake client knows
+// about the given APIs on top of the core and KataConfig ones and holds the
+// given objects.  A KataConfig without a name gets the one the tests use for
+// owner references.
+func newTestReconciler(t *testing.T, kataConfig *kataconfigurationv1.KataConfig, addToSchemes []func(*runtime.Scheme) error, objs ...client.Object) *KataConfigOpenShiftReconciler {
+	t.Setenv("PEERPODS_NAMESPACE", OperatorNamespace)
+
+	if kataConfig == nil {
+		kataConfig = &kataconfigurationv1.KataConfig{}
+	}
+	if kataConfig.Name == "" {
+		kataConfig.Name = "example-kataconfig"
+		kataConfig.UID = "1234"
+	}
+
+	scheme := newTestScheme(t, addToSchemes...)
+	return &KataConfigOpenShiftReconciler{
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Scheme:     scheme,
+		Log:        ctrl.Log.WithName("test"),
+		kataConfig: kataConfig,
+	}
+}
+
+func expectObjectExists(g *WithT, c client.Client, obj client.Object, exists bool) {
+	err := c.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj)
+	if exists {
+		g.Expect(err).NotTo(HaveOccurred(), "%s should exist", obj.GetName())
+	} else {
+		g.Expect(err).To(HaveOccurred(), "%s should not exist", obj.GetName())
+	}
+}
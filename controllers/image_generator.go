@@ -17,48 +17,75 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 /*
 The image generator builds and deletes pod VM images for a cloud provider. It uses Kubernetes jobs to do this.
-1. All manifests and related deps are located under config/peerpods/podvm
-2. The job manifest uses the following format: osc-podvm-[create|delete]-job.yaml
+1. All manifests and related deps are located under config/peerpods/podvm, the manifests are embedded in the operator binary
+2. The job manifest uses the following format: osc-podvm-[create|delete|import|replicate]-job.yaml
 3. The configuration values are taken from provider specific configMap: [provider]-podvm-image-cm.yaml
 4. The created image details are updated in the peer-pods-cm configmap
 */
 
 const (
-	unsupportedCloudProvider      = "unsupported"
-	peerpodsCMName                = "peer-pods-cm"
-	peerpodsCMAWSImageKey         = "PODVM_AMI_ID"
-	peerpodsCMAzureImageKey       = "AZURE_IMAGE_ID"
-	peerpodsLibvirtImageKey       = "LIBVIRT_IMAGE_ID"
-	fipsCMKey                     = "BOOT_FIPS"
-	procFIPS                      = "/proc/sys/crypto/fips_enabled"
-	AWSProvider                   = "aws"
-	AzureProvider                 = "azure"
-	LibvirtProvider               = "libvirt"
-	peerpodsImageJobsPathLocation = "/config/peerpods/podvm"
-	azureImageGalleryPrefix       = "PodVMGallery"
+	unsupportedCloudProvider = "unsupported"
+	peerpodsCMName           = "peer-pods-cm"
+	peerpodsCMAWSImageKey    = "PODVM_AMI_ID"
+	peerpodsCMAzureImageKey  = "AZURE_IMAGE_ID"
+	peerpodsLibvirtImageKey  = "LIBVIRT_IMAGE_ID"
+	fipsCMKey                = "BOOT_FIPS"
+	procFIPS                 = "/proc/sys/crypto/fips_enabled"
+	AWSProvider              = "aws"
+	AzureProvider            = "azure"
+	LibvirtProvider          = "libvirt"
+	azureImageGalleryPrefix  = "PodVMGallery"
+	imageJobLogsCMSuffix     = "-logs"
+	imageJobLogTailLines     = 200
+	// Keep the saved log well below the 1MiB ConfigMap size limit
+	imageJobLogMaxBytes = 512 * 1024
+	// Label put on the image jobs so that the operator only needs to watch
+	// and cache its own jobs
+	PodVMImageJobLabel = "kataconfiguration.openshift.io/podvm-image-job"
+	// peer-pods-cm annotation holding the reference of an imported image,
+	// set by the image import job
+	peerpodsCMImportedImageAnnotation = "IMPORTED_PODVM_IMAGE"
+	// peer-pods-cm annotation holding the source the image in use was built
+	// from, set by the operator once the image creation job has completed
+	peerpodsCMImageSourceAnnotation = "PODVM_IMAGE_SOURCE"
+	// Source of the images built from scratch
+	podvmImageSourceBuild = "build"
+	// peer-pods-cm key holding the result of the image replication job
+	peerpodsCMImageRegionsKey = "PODVM_IMAGE_REGIONS"
+	// peer-pods-cm annotation holding the images tagged with the cluster id,
+	// set by the image list job
+	peerpodsCMClusterImagesAnnotation = "PODVM_CLUSTER_IMAGES"
+	// Name prefix of the jobs deleting orphaned images.  The jobs are named
+	// after the image so that the deletions of different images can't be
+	// mixed up.
+	orphanedImageDeleteJobPrefix = "osc-podvm-image-gc-deletion-"
+	podvmImageReplicationJobName = "osc-podvm-image-replication"
 )
 
 // Return values for ImageCreate and ImageDelete
@@ -72,6 +99,11 @@ const (
 	ImageCreationInProgress
 	ImageDeletionInProgress
 	UnsupportedPodVMImageProvider
+	ImageDeletionSkipped
+	ImageReplicatedSuccessfully
+	ImageReplicationInProgress
+	ImageReplicationCompleted
+	ImageReplicationFailed
 	ImageCreationFailed        = -1
 	ImageDeletionFailed        = -1
 	CheckingJobStatusFailed    = -1
@@ -89,6 +121,7 @@ var (
 	ErrCreatingImageJob           = errors.New("error creating image job from yaml file")
 	ErrCheckingJobStatus          = errors.New("error checking job status")
 	ErrDeletingJob                = errors.New("error deleting job")
+	ErrInvalidImageImport         = errors.New("invalid pod VM image import reference")
 )
 
 // Event Constants for the PodVM Image Job
@@ -100,47 +133,145 @@ const (
 	PodVMImageUnsupportedProvider = "PodVMImageUnsupportedProvider"
 )
 
+// ImageGeneratorOptions holds the dependencies of the ImageGenerator on the
+// environment the operator runs in.  Unset fields fall back to the defaults
+// used in the operator pod, tests replace them.
+type ImageGeneratorOptions struct {
+	// Reports whether the cluster runs in FIPS mode, defaults to reading
+	// /proc/sys/crypto/fips_enabled
+	FIPSDetector func() (bool, error)
+
+	// Returns the id of the cluster, defaults to getClusterID
+	ClusterIDLookup func(client.Client) (string, error)
+
+	// Holds the image job and image ConfigMap manifests, defaults to
+	// the podvm directory of the peer-pods manifests embedded in the
+	// operator binary
+	Manifests fs.FS
+}
+
+// Returns the options with the defaults filled in for unset fields
+func (o ImageGeneratorOptions) withDefaults() ImageGeneratorOptions {
+	if o.FIPSDetector == nil {
+		o.FIPSDetector = isFIPSEnabled
+	}
+	if o.ClusterIDLookup == nil {
+		o.ClusterIDLookup = getClusterID
+	}
+	if o.Manifests == nil {
+		o.Manifests = podVMManifests(NewPeerPodsManifests(""))
+	}
+	return o
+}
+
 type ImageGenerator struct {
-	client    client.Client         // controller-runtime client
-	clientset *kubernetes.Clientset // k8s clientset
+	client       client.Client           // controller-runtime client
+	podLogClient corev1client.PodsGetter // only used to read the logs of failed job pods
+	manifests    fs.FS                   // source of the job and ConfigMap manifests
 
 	provider     string
 	clusterId    string
 	CMimageIDKey string
 	fips         bool
+
+	// Most recent failure of each kind of image job
+	failures map[imageJobKind]imageJobFailure
+
+	// Controller of the ConfigMaps holding the logs of failed jobs, so that
+	// they are removed along with the KataConfig
+	owner client.Object
+
+	// KataConfig overrides applied to the jobs
+	jobOverrides *kataconfigurationv1.WorkloadOverrides
+
+	// Finished jobs whose result has been recorded in the metrics, by name
+	// and UID, shared by the ImageGenerators of the reconciler.  Entries
+	// are removed once the job is gone.
+	recordedJobs map[string]bool
 }
 
-var (
-	igOnce   sync.Once
-	ig       *ImageGenerator
-	igLogger logr.Logger = ctrl.Log.WithName("image-generator")
+// Kind of an image job.  The failures of the different kinds of jobs are
+// recorded separately so that eg. a failed image garbage collection doesn't
+// hide why the image creation failed.
+type imageJobKind string
+
+const (
+	// Image creation and image import jobs, both produce the image in use
+	imageJobCreate    imageJobKind = "create"
+	imageJobDelete    imageJobKind = "delete"
+	imageJobReplicate imageJobKind = "replicate"
+	imageJobList      imageJobKind = "list"
+	imageJobGC        imageJobKind = "gc"
 )
 
-func InitializeImageGenerator(client client.Client) error {
-	var err error
-	igOnce.Do(func() {
-		ig, err = newImageGenerator(client)
-	})
-	// Reset the sync.Once if there was an error
+// Failure of an image job
+type imageJobFailure struct {
+	// Reason of the failure, taken from the termination message of the
+	// job's pod
+	reason string
+	// Name of the ConfigMap holding the log of the failed job, empty if
+	// the log couldn't be saved
+	logsConfigMap string
+}
+
+var igLogger logr.Logger = ctrl.Log.WithName("image-generator")
+
+// Returns the ImageGenerator of the reconcile in progress, creating it for
+// the KataConfig on first use.  Creation is retried on the next call if it
+// fails.
+func (r *KataConfigOpenShiftReconciler) getImageGenerator() (*ImageGenerator, error) {
+	if r.imageGenerator != nil {
+		return r.imageGenerator, nil
+	}
+
+	opts := r.ImageGeneratorOptions
+	if opts.Manifests == nil && r.Manifests != nil {
+		opts.Manifests = podVMManifests(r.Manifests)
+	}
+	ig, err := newImageGenerator(r.Client, r.PodLogClient, opts)
 	if err != nil {
-		igOnce = sync.Once{}
+		return nil, err
+	}
+
+	if r.recordedImageJobs == nil {
+		r.recordedImageJobs = map[string]bool{}
 	}
-	return err
+	ig.recordedJobs = r.recordedImageJobs
+	ig.jobOverrides = r.getPodVMImageJobsOverrides()
+	ig.owner = r.kataConfig
+	r.imageGenerator = ig
+	return ig, nil
 }
 
-// GetImageGenerator returns the global ImageGenerator instance
-func GetImageGenerator() *ImageGenerator {
-	return ig
+// LastFailureReason returns the reason of the most recent failure of the
+// given kind of image job
+func (r *ImageGenerator) LastFailureReason(kind imageJobKind) string {
+	return r.failures[kind].reason
 }
 
-// ImageCreate creates a podvm image for a cloud provider if not present
-func ImageCreate(c client.Client) (int, error) {
-	if err := InitializeImageGenerator(c); err != nil {
+// LastFailureLogsConfigMap returns the name of the ConfigMap holding the log
+// of the most recent failed image job of the given kind
+func (r *ImageGenerator) LastFailureLogsConfigMap(kind imageJobKind) string {
+	return r.failures[kind].logsConfigMap
+}
+
+// Records the failure of an image job of the given kind, replacing the
+// previous failure of that kind
+func (r *ImageGenerator) setLastFailure(kind imageJobKind, failure imageJobFailure) {
+	if r.failures == nil {
+		r.failures = map[imageJobKind]imageJobFailure{}
+	}
+	r.failures[kind] = failure
+}
+
+// imageCreate creates a podvm image for a cloud provider if not present
+func (r *KataConfigOpenShiftReconciler) imageCreate() (int, error) {
+	ig, err := r.getImageGenerator()
+	if err != nil {
 		igLogger.Info("error initializing ImageGenerator instance", "err", err)
 		return ImageCreationFailed, ErrInitializingImageGenerator
 	}
 
-	ig := GetImageGenerator()
 	if ig.provider == unsupportedCloudProvider {
 		igLogger.Info("unsupported cloud provider, skipping image creation")
 		return UnsupportedPodVMImageProvider, ErrUnsupportedCloudProvider
@@ -163,29 +294,66 @@ func ImageCreate(c client.Client) (int, error) {
 		return ImageCreationFailed, ErrUpdatingImageConfigMap
 	}
 
-	status, err := ig.imageCreateJobRunner()
-	if err != nil {
-		igLogger.Info("error running image create job", "err", err)
-		return ImageCreationFailed, err
+	var status int
+	if importSpec := r.getPodVMImageImportSpec(); importSpec != nil {
+		status, err = ig.imageImportJobRunner(importSpec)
+		if err != nil {
+			igLogger.Info("error running image import job", "err", err)
+			return status, err
+		}
+	} else {
+		status, err = ig.imageCreateJobRunner(r.getPodVMImageOCIArtifact())
+		if err != nil {
+			igLogger.Info("error running image create job", "err", err)
+			return status, err
+		}
+	}
+
+	if status == ImageCreatedSuccessfully {
+		r.kataConfig.Status.PodVMImage.ImportedImage = ig.getImportedImage()
 	}
 
 	return status, nil
 
 }
 
-// ImageDelete deletes a podvm image for a cloud provider if present
-func ImageDelete(c client.Client) (int, error) {
-	if err := InitializeImageGenerator(c); err != nil {
+// Returns the OCI artifact to create the pod VM image from, nil if the image
+// is to be built from scratch
+func (r *KataConfigOpenShiftReconciler) getPodVMImageOCIArtifact() *kataconfigurationv1.PodVMImageOCIArtifact {
+	if r.kataConfig.Spec.PodVMImage == nil {
+		return nil
+	}
+	return r.kataConfig.Spec.PodVMImage.OCIArtifact
+}
+
+// Returns the pod VM image import settings of the KataConfig, nil if the
+// image is to be built by the operator
+func (r *KataConfigOpenShiftReconciler) getPodVMImageImportSpec() *kataconfigurationv1.PodVMImageImportSpec {
+	if r.kataConfig.Spec.PodVMImage == nil {
+		return nil
+	}
+	return r.kataConfig.Spec.PodVMImage.Import
+}
+
+// imageDelete deletes a podvm image for a cloud provider if present
+func (r *KataConfigOpenShiftReconciler) imageDelete() (int, error) {
+	ig, err := r.getImageGenerator()
+	if err != nil {
 		igLogger.Info("error initializing ImageGenerator instance", "err", err)
 		return ImageDeletionFailed, ErrInitializingImageGenerator
 	}
 
-	ig := GetImageGenerator()
 	if ig.provider == unsupportedCloudProvider {
 		igLogger.Info("unsupported cloud provider, skipping image deletion")
 		return UnsupportedPodVMImageProvider, ErrUnsupportedCloudProvider
 	}
 
+	// Imported images are owned by the user, never delete them
+	if importedImage := ig.getImportedImage(); importedImage != "" {
+		igLogger.Info("Image has been imported, skipping image deletion", "image", importedImage)
+		return ImageDeletionSkipped, nil
+	}
+
 	if err := ig.validatePeerPodsConfigs(); err != nil {
 		igLogger.Info("error validating peer-pods configs", "err", err)
 		return ImageDeletionFailed, ErrValidatingPeerPodsConfigs
@@ -194,38 +362,42 @@ func ImageDelete(c client.Client) (int, error) {
 	status, err := ig.imageDeleteJobRunner()
 	if err != nil {
 		igLogger.Info("error running image delete job", "err", err)
-		return ImageDeletionFailed, err
+		return status, err
 	}
 
 	return status, nil
 
 }
 
-func newImageGenerator(client client.Client) (*ImageGenerator, error) {
-	ig := &ImageGenerator{
-		client: client,
-	}
-
-	config, err := rest.InClusterConfig()
+// Reports whether the cluster runs in FIPS mode based on the kernel setting
+// of the node the operator runs on
+func isFIPSEnabled() (bool, error) {
+	content, err := os.ReadFile(procFIPS)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get in-cluster config: %v", err)
+		return false, fmt.Errorf("failed to read FIPS file: %v", err)
 	}
 
-	ig.clientset, err = kubernetes.NewForConfig(config)
+	fips, err := strconv.Atoi(strings.Trim(string(content), "\n\t "))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get k8s clientset: %v", err)
+		return false, fmt.Errorf("failed to convert FIPS file content to int: %v", err)
 	}
+	return fips == 1, nil
+}
 
-	content, err := os.ReadFile(procFIPS)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read FIPS file: %v", err)
+func newImageGenerator(client client.Client, podLogClient corev1client.PodsGetter, opts ImageGeneratorOptions) (*ImageGenerator, error) {
+	opts = opts.withDefaults()
+
+	ig := &ImageGenerator{
+		client:       client,
+		podLogClient: podLogClient,
+		manifests:    opts.Manifests,
 	}
 
-	fips, err := strconv.Atoi(strings.Trim(string(content), "\n\t "))
+	fips, err := opts.FIPSDetector()
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert FIPS file content to int: %v", err)
+		return nil, err
 	}
-	ig.fips = fips == 1
+	ig.fips = fips
 
 	// Use PROVIDER_OVERRIDE env var if set, otherwise get the cloud provider from the infra
 	// This is needed for the hybrid cloud or cloud-bursting scenario
@@ -258,7 +430,7 @@ func newImageGenerator(client client.Client) (*ImageGenerator, error) {
 		ig.provider = unsupportedCloudProvider
 	}
 
-	clusterID, err := getClusterID(client)
+	clusterID, err := opts.ClusterIDLookup(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster ID: %v", err)
 	}
@@ -272,9 +444,7 @@ func newImageGenerator(client client.Client) (*ImageGenerator, error) {
 func (r *ImageGenerator) createJobFromFile(jobFileName string) (*batchv1.Job, error) {
 	igLogger.Info("Create Job out of YAML file", "jobFileName", jobFileName)
 
-	jobYamlFile := filepath.Join(peerpodsImageJobsPathLocation, jobFileName)
-
-	yamlData, err := readYamlFile(jobYamlFile)
+	yamlData, err := fs.ReadFile(r.manifests, jobFileName)
 	if err != nil {
 		return nil, err
 	}
@@ -341,6 +511,20 @@ func (r *ImageGenerator) createJobFromFile(jobFileName string) (*batchv1.Job, er
 		}
 	}
 
+	// The jobs tag new images with the cluster id and look up the images of
+	// the cluster by it
+	setJobEnv(job, "CLUSTER_ID", r.clusterId)
+
+	// Make sure the job is seen by the operator's job watch
+	labels := job.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[PodVMImageJobLabel] = "true"
+	job.SetLabels(labels)
+
+	applyWorkloadOverrides(&job.Spec.Template.Spec, r.jobOverrides)
+
 	return job, nil
 }
 
@@ -353,7 +537,7 @@ func (r *ImageGenerator) createJob(job *batchv1.Job) error {
 			igLogger.Info("Image Job already exists", "jobName", job.Name, "namespace", job.Namespace)
 			return nil
 		}
-		return nil
+		return err
 	}
 	igLogger.Info("Image job successfully created", "jobName", job.Name, "namespace", job.Namespace)
 	return nil
@@ -372,10 +556,12 @@ func (r *ImageGenerator) deleteJob(job *batchv1.Job) error {
 	if err := r.client.Delete(context.TODO(), job, &client.DeleteOptions{}); err != nil {
 		if k8serrors.IsNotFound(err) {
 			igLogger.Info("Job has already been deleted", "jobName", job.Name, "namespace", job.Namespace)
+			r.forgetJobMetrics(job.Name)
 			return nil
 		}
 		return err
 	}
+	r.forgetJobMetrics(job.Name)
 
 	igLogger.Info("Job has been deleted successfully", "jobName", job.Name, "namespace", job.Namespace)
 	return nil
@@ -404,8 +590,10 @@ func (r *ImageGenerator) getPeerPodsCM() (*corev1.ConfigMap, error) {
 // azure-podvm-image-cm.yaml for Azure
 // aws-podvm-image-cm.yaml for AWS
 // libvirt-podvm-image-cm.yaml for Libvirt
+// If ociArtifact is set the image is created from the disk image in the
+// artifact instead of being built from scratch
 
-func (r *ImageGenerator) imageCreateJobRunner() (int, error) {
+func (r *ImageGenerator) imageCreateJobRunner(ociArtifact *kataconfigurationv1.PodVMImageOCIArtifact) (int, error) {
 	igLogger.Info("imageCreateJobRunner: Start")
 
 	// We create the job first irrespective of the image ID being set or not
@@ -419,15 +607,29 @@ func (r *ImageGenerator) imageCreateJobRunner() (int, error) {
 		return ImageCreationFailed, ErrCreatingImageJob
 	}
 
-	// Handle job deletion if the image ID is already set and entering here on requeue
-	if r.isImageIDSet() {
-		igLogger.Info("Image ID is already set, skipping image creation")
-		// Delete the job if it still exists
-		if err := r.deleteJob(job); err != nil {
-			igLogger.Info("Error deleting job", "err", err)
-			return RequeueNeeded, err
-		}
+	source := podvmImageSourceBuild
+	if ociArtifact != nil {
+		// Overrides PODVM_IMAGE_URI from the podvm image configMap
+		podvmImageURI := getPodVMImageURI(ociArtifact)
+		source = podvmImageURI
+		igLogger.Info("Creating the image from an OCI artifact", "PODVM_IMAGE_URI", podvmImageURI)
+		job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  "PODVM_IMAGE_URI",
+			Value: podvmImageURI,
+		})
+	}
 
+	// Skip the creation if the image has already been built from the source.
+	// An image imported or built from another source is replaced.  A job
+	// replacing the image is followed up on below as it sets the image ID
+	// before the operator records the new source.
+	present, err := r.isJobPresent(job.Name)
+	if err != nil {
+		igLogger.Info("error checking whether the image creation job exists", "err", err)
+		return ImageCreationStatusUnknown, ErrCheckingJobStatus
+	}
+	if !present && r.isImageBuiltFrom(source) {
+		igLogger.Info("Image ID is already set, skipping image creation")
 		return ImageCreatedSuccessfully, nil
 	}
 
@@ -437,7 +639,7 @@ func (r *ImageGenerator) imageCreateJobRunner() (int, error) {
 		return RequeueNeeded, ErrCreatingImageJob
 	}
 
-	status, err := r.checkJobStatus(job.Name, job.Namespace)
+	status, err := r.checkJobStatus(imageJobCreate, job.Name, job.Namespace)
 	if err != nil {
 		igLogger.Info("error checking job status", "err", err)
 		return ImageCreationStatusUnknown, ErrCheckingJobStatus
@@ -446,14 +648,20 @@ func (r *ImageGenerator) imageCreateJobRunner() (int, error) {
 	// Handle different job statuses
 	switch status {
 	case ImageJobRunning:
+		// No need to requeue, the job watch triggers a reconcile
+		// once the job has finished
 		igLogger.Info("Image creation job is still running")
-		return RequeueNeeded, nil
+		return ImageCreationInProgress, nil
 	case ImageJobCompleted:
 		// If job completed successfully but image ID is not set, requeue
 		if !r.isImageIDSet() {
 			igLogger.Info("Image creation job has completed and image ID is not set, requeueing")
 			return RequeueNeeded, nil
 		}
+		if err := r.setImageSource(source); err != nil {
+			igLogger.Info("Error recording the image source in peer-pods-cm", "err", err)
+			return RequeueNeeded, err
+		}
 		// Delete the job as it's no longer needed
 		if err := r.deleteJob(job); err != nil {
 			igLogger.Info("Error deleting job", "err", err)
@@ -517,7 +725,7 @@ func (r *ImageGenerator) imageDeleteJobRunner() (int, error) {
 		return RequeueNeeded, ErrCreatingImageJob
 	}
 
-	status, err := r.checkJobStatus(job.Name, job.Namespace)
+	status, err := r.checkJobStatus(imageJobDelete, job.Name, job.Namespace)
 	if err != nil {
 		igLogger.Info("error checking job status", "err", err)
 		return ImageDeletionStatusUnknown, ErrCheckingJobStatus
@@ -526,8 +734,10 @@ func (r *ImageGenerator) imageDeleteJobRunner() (int, error) {
 	// Handle different job statuses
 	switch status {
 	case ImageJobRunning:
+		// No need to requeue, the job watch triggers a reconcile
+		// once the job has finished
 		igLogger.Info("Image deletion job is still running")
-		return RequeueNeeded, nil
+		return ImageDeletionInProgress, nil
 	case ImageJobCompleted:
 		// If job completed successfully but image ID is still set, requeue
 		if r.isImageIDSet() {
@@ -558,6 +768,518 @@ func (r *ImageGenerator) imageDeleteJobRunner() (int, error) {
 	}
 }
 
+// Method to run image import job
+// Calling this method assumes the same as for imageCreateJobRunner.
+// The job validates the referenced image with the cloud provider, uploads it
+// to the libvirt volume for libvirt, and records it in peer-pods-cm.
+// Return values are the same as for imageCreateJobRunner.
+
+func (r *ImageGenerator) imageImportJobRunner(importSpec *kataconfigurationv1.PodVMImageImportSpec) (int, error) {
+	igLogger.Info("imageImportJobRunner: Start")
+
+	imageRef, env, err := r.getImageImportEnv(importSpec)
+	if err != nil {
+		// Retrying doesn't help here, report it as a failed attempt so
+		// that it shows up in the KataConfig status
+		igLogger.Info("error validating the image import reference", "err", err)
+		r.setLastFailure(imageJobCreate, imageJobFailure{reason: err.Error()})
+		return ImageCreationFailed, nil
+	}
+
+	filename := "osc-podvm-import-job.yaml"
+
+	job, err := r.createJobFromFile(filename)
+	if err != nil {
+		igLogger.Info("error creating the image import job object from yaml file", "err", err)
+		return ImageCreationFailed, ErrCreatingImageJob
+	}
+	// There is only one container in the job
+	job.Spec.Template.Spec.Containers[0].Env = append(job.Spec.Template.Spec.Containers[0].Env, env...)
+
+	// Handle job deletion if the image has already been imported and entering here on requeue
+	if r.isImageImported(imageRef) {
+		igLogger.Info("Image has already been imported, skipping image import", "image", imageRef)
+		// Delete the job if it still exists
+		if err := r.deleteJob(job); err != nil {
+			igLogger.Info("Error deleting job", "err", err)
+			return RequeueNeeded, err
+		}
+
+		return ImageCreatedSuccessfully, nil
+	}
+
+	// Create the job
+	if err = r.createJob(job); err != nil {
+		igLogger.Info("error creating the image import job", "err", err)
+		return RequeueNeeded, ErrCreatingImageJob
+	}
+
+	status, err := r.checkJobStatus(imageJobCreate, job.Name, job.Namespace)
+	if err != nil {
+		igLogger.Info("error checking job status", "err", err)
+		return ImageCreationStatusUnknown, ErrCheckingJobStatus
+	}
+
+	// Handle different job statuses
+	switch status {
+	case ImageJobRunning:
+		// No need to requeue, the job watch triggers a reconcile
+		// once the job has finished
+		igLogger.Info("Image import job is still running")
+		return ImageCreationInProgress, nil
+	case ImageJobCompleted:
+		// If job completed successfully but the image isn't recorded yet, requeue
+		if !r.isImageImported(imageRef) {
+			igLogger.Info("Image import job has completed and the image is not recorded in peer-pods-cm, requeueing")
+			return RequeueNeeded, nil
+		}
+		// Delete the job as it's no longer needed
+		if err := r.deleteJob(job); err != nil {
+			igLogger.Info("Error deleting job", "err", err)
+			return RequeueNeeded, err
+		}
+		igLogger.Info("Image import job has completed successfully", "image", imageRef)
+		return ImageCreatedSuccessfully, nil
+	case ImageJobFailed:
+		// If job failed, don't requeue
+		igLogger.Info("Image import job has failed")
+
+		// Delete the job as it's no longer needed
+		if err := r.deleteJob(job); err != nil {
+			igLogger.Info("Error deleting job", "err", err)
+			return RequeueNeeded, err
+		}
+		return ImageCreationFailed, nil
+	default:
+		// Handle unknown job status
+		igLogger.Info("Unknown job status", "status", status)
+		return RequeueNeeded, nil
+	}
+}
+
+// Method to run the image replication job
+// Calling this method assumes that the image has been created and its ID is
+// set in peer-pods-cm.
+// The job replicates the image to the given regions it isn't available in yet
+// and records the result per region in peer-pods-cm.
+// Return values:
+// ImageReplicatedSuccessfully: the image is available in all regions
+// ImageReplicationInProgress: the job is running
+// ImageReplicationCompleted: the job has finished, see getImageRegions for the
+// per region result
+// ImageReplicationFailed: the job has failed
+// RequeueNeeded: the job couldn't be created or its status checked
+
+func (r *ImageGenerator) imageReplicateJobRunner(regions []string) (int, error) {
+	igLogger.Info("imageReplicateJobRunner: Start")
+
+	filename := "osc-podvm-replicate-job.yaml"
+
+	job, err := r.createJobFromFile(filename)
+	if err != nil {
+		igLogger.Info("error creating the image replication job object from yaml file", "err", err)
+		return RequeueNeeded, ErrCreatingImageJob
+	}
+
+	imageRegions := r.getImageRegions()
+	var pendingRegions []string
+	for _, region := range regions {
+		if imageRegions[region].ImageID == "" {
+			pendingRegions = append(pendingRegions, region)
+		}
+	}
+
+	// Handle job deletion if the image is available in all regions and entering here on requeue
+	if len(pendingRegions) == 0 {
+		igLogger.Info("Image is available in all regions, skipping image replication")
+		// Delete the job if it still exists
+		if err := r.deleteJob(job); err != nil {
+			igLogger.Info("Error deleting job", "err", err)
+			return RequeueNeeded, err
+		}
+
+		return ImageReplicatedSuccessfully, nil
+	}
+
+	// There is only one container in the job
+	setJobEnv(job, "TARGET_REGIONS", strings.Join(pendingRegions, ","))
+
+	// Create the job
+	if err = r.createJob(job); err != nil {
+		igLogger.Info("error creating the image replication job", "err", err)
+		return RequeueNeeded, ErrCreatingImageJob
+	}
+
+	status, err := r.checkJobStatus(imageJobReplicate, job.Name, job.Namespace)
+	if err != nil {
+		igLogger.Info("error checking job status", "err", err)
+		return RequeueNeeded, ErrCheckingJobStatus
+	}
+
+	// Handle different job statuses
+	switch status {
+	case ImageJobRunning:
+		// No need to requeue, the job watch triggers a reconcile
+		// once the job has finished
+		igLogger.Info("Image replication job is still running")
+		return ImageReplicationInProgress, nil
+	case ImageJobCompleted:
+		// Delete the job as it's no longer needed
+		if err := r.deleteJob(job); err != nil {
+			igLogger.Info("Error deleting job", "err", err)
+			return RequeueNeeded, err
+		}
+		igLogger.Info("Image replication job has completed")
+		return ImageReplicationCompleted, nil
+	case ImageJobFailed:
+		igLogger.Info("Image replication job has failed")
+
+		// Delete the job as it's no longer needed
+		if err := r.deleteJob(job); err != nil {
+			igLogger.Info("Error deleting job", "err", err)
+			return RequeueNeeded, err
+		}
+		return ImageReplicationFailed, nil
+	default:
+		// Handle unknown job status
+		igLogger.Info("Unknown job status", "status", status)
+		return RequeueNeeded, nil
+	}
+}
+
+// Result of the image replication to a region as recorded by the image
+// replication job.  Exactly one of ImageID and Error is set.
+type podvmImageRegion struct {
+	ImageID string `json:"imageID,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Format of the PODVM_IMAGE_REGIONS key of peer-pods-cm
+type podvmImageRegions struct {
+	SourceImageID string                      `json:"sourceImageID"`
+	Regions       map[string]podvmImageRegion `json:"regions"`
+}
+
+// Method to get the per region result of the image replication for the image
+// in use.  Results recorded for an earlier image are ignored.
+func (r *ImageGenerator) getImageRegions() map[string]podvmImageRegion {
+	peerPodsCM, err := r.getPeerPodsCM()
+	if peerPodsCM == nil || err != nil {
+		igLogger.Info("error getting peer-pods-cm ConfigMap", "err", err)
+		return nil
+	}
+
+	value := peerPodsCM.Data[peerpodsCMImageRegionsKey]
+	if value == "" {
+		return nil
+	}
+
+	var imageRegions podvmImageRegions
+	if err := json.Unmarshal([]byte(value), &imageRegions); err != nil {
+		igLogger.Info("error parsing the image regions in peer-pods-cm", "err", err)
+		return nil
+	}
+
+	if imageID := peerPodsCM.Data[r.CMimageIDKey]; imageID == "" || imageRegions.SourceImageID != imageID {
+		return nil
+	}
+	return imageRegions.Regions
+}
+
+// Sets the value of an environment variable of the job's only container,
+// adding the variable if the job manifest doesn't define it.  All definitions
+// of the variable are updated as the last one wins.
+func setJobEnv(job *batchv1.Job, name, value string) {
+	container := &job.Spec.Template.Spec.Containers[0]
+	found := false
+	for i := range container.Env {
+		if container.Env[i].Name == name {
+			container.Env[i].Value = value
+			found = true
+		}
+	}
+	if !found {
+		container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+	}
+}
+
+// Method to run the image list job
+// The job records the images tagged with the cluster id in peer-pods-cm,
+// see getClusterImages.  Images are looked up in the cluster's region and in
+// the given regions.
+// Return values:
+// ImageJobRunning, ImageJobCompleted, ImageJobFailed: state of the job
+// RequeueNeeded: the job couldn't be created or its status checked
+
+func (r *ImageGenerator) imageListJobRunner(regions []string) (int, error) {
+	igLogger.Info("imageListJobRunner: Start")
+
+	job, err := r.createJobFromFile("osc-podvm-list-job.yaml")
+	if err != nil {
+		igLogger.Info("error creating the image list job object from yaml file", "err", err)
+		return RequeueNeeded, ErrCreatingImageJob
+	}
+	setJobEnv(job, "TARGET_REGIONS", strings.Join(regions, ","))
+
+	return r.runImageJob(imageJobList, job)
+}
+
+// Method to run a job deleting an orphaned image, ie. an image tagged with
+// the cluster id which isn't referenced in peer-pods-cm.  The job is created
+// from the image deletion job manifest.  peer-pods-cm isn't updated and the
+// image isn't deleted if it turns out to be the one in use.
+// An orphaned Azure gallery of the cluster is deleted along with the image
+// versions it holds using the gallery deletion job manifest.
+// Return values are the same as for imageListJobRunner.
+
+func (r *ImageGenerator) orphanedImageDeleteJobRunner(image kataconfigurationv1.PodVMImageReference) (int, error) {
+	igLogger.Info("orphanedImageDeleteJobRunner: Start", "image", image.ID, "region", image.Region)
+
+	if r.provider == AzureProvider {
+		if galleryName := azureGalleryName(image.ID); galleryName != "" {
+			return r.orphanedGalleryDeleteJobRunner(image, galleryName)
+		}
+	}
+
+	job, err := r.createJobFromFile("osc-podvm-delete-job.yaml")
+	if err != nil {
+		igLogger.Info("error creating the orphaned image deletion job object from yaml file", "err", err)
+		return RequeueNeeded, ErrCreatingImageJob
+	}
+
+	setOrphanedImageDeleteJobName(job, image)
+
+	// Drop "-f" and "-g" so that neither the image in use nor the gallery
+	// are deleted
+	job.Spec.Template.Spec.Containers[0].Command = []string{"/podvm-builder.sh", "delete"}
+	setJobEnv(job, "UPDATE_PEERPODS_CM", "no")
+
+	switch r.provider {
+	case AWSProvider:
+		setJobEnv(job, "AMI_ID", image.ID)
+		if image.Region != "" {
+			setJobEnv(job, "AWS_REGION", image.Region)
+		}
+	case AzureProvider:
+		setJobEnv(job, "IMAGE_ID", image.ID)
+	default:
+		igLogger.Info("Orphaned image deletion is not supported for the cloud provider", "provider", r.provider)
+		return ImageJobFailed, ErrUnsupportedCloudProvider
+	}
+
+	return r.runImageJob(imageJobGC, job)
+}
+
+// Method to run a job deleting an orphaned Azure gallery of the cluster
+func (r *ImageGenerator) orphanedGalleryDeleteJobRunner(image kataconfigurationv1.PodVMImageReference, galleryName string) (int, error) {
+	job, err := r.createJobFromFile("osc-podvm-gallery-delete-job.yaml")
+	if err != nil {
+		igLogger.Info("error creating the orphaned gallery deletion job object from yaml file", "err", err)
+		return RequeueNeeded, ErrCreatingImageJob
+	}
+
+	setOrphanedImageDeleteJobName(job, image)
+	setJobEnv(job, "GC_GALLERY_NAME", galleryName)
+
+	return r.runImageJob(imageJobGC, job)
+}
+
+// Names the job after the image so that the deletions of different images
+// can't be mixed up
+func setOrphanedImageDeleteJobName(job *batchv1.Job, image kataconfigurationv1.PodVMImageReference) {
+	hash := sha256.Sum256([]byte(image.Region + "/" + image.ID))
+	job.Name = orphanedImageDeleteJobPrefix + hex.EncodeToString(hash[:])[:10]
+	job.Spec.Template.Name = job.Name
+}
+
+// Method to create the job unless it exists and to get its state.  Finished
+// jobs are deleted.
+func (r *ImageGenerator) runImageJob(kind imageJobKind, job *batchv1.Job) (int, error) {
+	if err := r.createJob(job); err != nil {
+		igLogger.Info("error creating the job", "jobName", job.Name, "err", err)
+		return RequeueNeeded, ErrCreatingImageJob
+	}
+
+	status, err := r.checkJobStatus(kind, job.Name, job.Namespace)
+	if err != nil {
+		igLogger.Info("error checking job status", "err", err)
+		return RequeueNeeded, ErrCheckingJobStatus
+	}
+
+	switch status {
+	case ImageJobRunning:
+		// No need to requeue, the job watch triggers a reconcile
+		// once the job has finished
+		igLogger.Info("Job is still running", "jobName", job.Name)
+		return ImageJobRunning, nil
+	case ImageJobCompleted, ImageJobFailed:
+		// Delete the job as it's no longer needed
+		if err := r.deleteJob(job); err != nil {
+			igLogger.Info("Error deleting job", "err", err)
+			return RequeueNeeded, err
+		}
+		igLogger.Info("Job has finished", "jobName", job.Name, "status", status)
+		return status, nil
+	default:
+		// Handle unknown job status
+		igLogger.Info("Unknown job status", "status", status)
+		return RequeueNeeded, nil
+	}
+}
+
+// Method to get the images tagged with the cluster id as recorded by the
+// image list job
+func (r *ImageGenerator) getClusterImages() ([]kataconfigurationv1.PodVMImageReference, error) {
+	peerPodsCM, err := r.getPeerPodsCM()
+	if err != nil {
+		return nil, err
+	}
+	if peerPodsCM == nil {
+		return nil, fmt.Errorf("%s ConfigMap not found", peerpodsCMName)
+	}
+
+	var images []kataconfigurationv1.PodVMImageReference
+	if err := json.Unmarshal([]byte(peerPodsCM.Annotations[peerpodsCMClusterImagesAnnotation]), &images); err != nil {
+		return nil, fmt.Errorf("error parsing the %s annotation of %s: %w", peerpodsCMClusterImagesAnnotation, peerpodsCMName, err)
+	}
+	return images, nil
+}
+
+// Method to get the IDs of the images referenced in peer-pods-cm, ie. the
+// image in use and its replicas in other regions
+func (r *ImageGenerator) getReferencedImageIDs() map[string]bool {
+	referenced := map[string]bool{}
+
+	peerPodsCM, err := r.getPeerPodsCM()
+	if peerPodsCM == nil || err != nil {
+		igLogger.Info("error getting peer-pods-cm ConfigMap", "err", err)
+		return referenced
+	}
+
+	if imageID := peerPodsCM.Data[r.CMimageIDKey]; imageID != "" {
+		referenced[imageID] = true
+	}
+	for _, region := range r.getImageRegions() {
+		if region.ImageID != "" {
+			referenced[region.ImageID] = true
+		}
+	}
+	return referenced
+}
+
+// Method to check whether a job exists
+func (r *ImageGenerator) isJobPresent(jobName string) (bool, error) {
+	job := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: OperatorNamespace}, job)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Method to get the reference of the image to import and the environment of
+// the import job from the import settings.  Returns an error if the settings
+// don't match the cloud provider in use.
+func (r *ImageGenerator) getImageImportEnv(importSpec *kataconfigurationv1.PodVMImageImportSpec) (string, []corev1.EnvVar, error) {
+	var imageRef string
+	var env []corev1.EnvVar
+
+	switch r.provider {
+	case AWSProvider:
+		if importSpec.AMIID == "" {
+			return "", nil, fmt.Errorf("%w: amiID must be set for cloud provider %s", ErrInvalidImageImport, r.provider)
+		}
+		imageRef = importSpec.AMIID
+		env = append(env, corev1.EnvVar{Name: "AMI_ID", Value: imageRef})
+	case AzureProvider:
+		if importSpec.AzureImageID == "" {
+			return "", nil, fmt.Errorf("%w: azureImageID must be set for cloud provider %s", ErrInvalidImageImport, r.provider)
+		}
+		imageRef = importSpec.AzureImageID
+		env = append(env, corev1.EnvVar{Name: "IMAGE_ID", Value: imageRef})
+	case LibvirtProvider:
+		if importSpec.URL != "" {
+			imageRef = importSpec.URL
+			env = append(env, corev1.EnvVar{Name: "IMPORT_IMAGE_URL", Value: imageRef})
+		} else if importSpec.OCIArtifact != "" {
+			imageRef = importSpec.OCIArtifact
+			env = append(env, corev1.EnvVar{Name: "PODVM_IMAGE_URI", Value: "oci::" + imageRef})
+		} else {
+			return "", nil, fmt.Errorf("%w: url or ociArtifact must be set for cloud provider %s", ErrInvalidImageImport, r.provider)
+		}
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported cloud provider %s", ErrInvalidImageImport, r.provider)
+	}
+
+	env = append(env, corev1.EnvVar{Name: "IMPORT_IMAGE_REF", Value: imageRef})
+	return imageRef, env, nil
+}
+
+// Returns the PODVM_IMAGE_URI value for the image builder scripts which is of
+// the form oci::<repository>[:<tag>][::<path of the disk image>]
+func getPodVMImageURI(ociArtifact *kataconfigurationv1.PodVMImageOCIArtifact) string {
+	uri := "oci::" + ociArtifact.Image
+	if ociArtifact.DiskPath != "" {
+		uri += "::" + ociArtifact.DiskPath
+	}
+	return uri
+}
+
+// Returns true if the given image has been imported and is the one in use
+func (r *ImageGenerator) isImageImported(imageRef string) bool {
+	return r.getImportedImage() == imageRef
+}
+
+// Method to get the reference of the imported image in use.  Returns an empty
+// string if the image in use was built by the operator or no image is set.
+func (r *ImageGenerator) getImportedImage() string {
+	peerPodsCM, err := r.getPeerPodsCM()
+	if peerPodsCM == nil || err != nil {
+		igLogger.Info("error getting peer-pods-cm ConfigMap", "err", err)
+		return ""
+	}
+
+	if peerPodsCM.Data[r.CMimageIDKey] == "" {
+		return ""
+	}
+	return peerPodsCM.Annotations[peerpodsCMImportedImageAnnotation]
+}
+
+// Returns true if the image in use was built by the operator from the given
+// source.  Images built before the source was recorded are taken as built
+// from any source.
+func (r *ImageGenerator) isImageBuiltFrom(source string) bool {
+	peerPodsCM, err := r.getPeerPodsCM()
+	if peerPodsCM == nil || err != nil {
+		igLogger.Info("error getting peer-pods-cm ConfigMap", "err", err)
+		return false
+	}
+
+	if peerPodsCM.Data[r.CMimageIDKey] == "" || peerPodsCM.Annotations[peerpodsCMImportedImageAnnotation] != "" {
+		return false
+	}
+	recorded := peerPodsCM.Annotations[peerpodsCMImageSourceAnnotation]
+	return recorded == "" || recorded == source
+}
+
+// Method to record the source the image in use was built from in peer-pods-cm
+func (r *ImageGenerator) setImageSource(source string) error {
+	peerPodsCM, err := r.getPeerPodsCM()
+	if err != nil {
+		return err
+	}
+	if peerPodsCM.Annotations[peerpodsCMImageSourceAnnotation] == source {
+		return nil
+	}
+
+	patch := client.MergeFrom(peerPodsCM.DeepCopy())
+	if peerPodsCM.Annotations == nil {
+		peerPodsCM.Annotations = map[string]string{}
+	}
+	peerPodsCM.Annotations[peerpodsCMImageSourceAnnotation] = source
+	return r.client.Patch(context.TODO(), peerPodsCM, patch)
+}
+
 func (r *ImageGenerator) isImageIDSet() bool {
 	peerPodsCM, err := r.getPeerPodsCM()
 	if peerPodsCM == nil || err != nil {
@@ -709,8 +1431,7 @@ func (r *ImageGenerator) createImageConfigMapFromFile() error {
 	}
 
 	filename := r.getImageConfigMapName() + ".yaml"
-	configMapYamlFile := filepath.Join(peerpodsImageJobsPathLocation, filename)
-	yamlData, err := readYamlFile(configMapYamlFile)
+	yamlData, err := fs.ReadFile(r.manifests, filename)
 	if err != nil {
 		return err
 	}
@@ -800,6 +1521,13 @@ func (r *ImageGenerator) isJobActive(job *batchv1.Job) bool {
 	return len(job.Status.Conditions) == 0 && job.Status.Active > 0
 }
 
+// isJobPending: checks if the job hasn't finished but has no running pod,
+// ie. its pod hasn't been created yet or is about to be retried
+func (r *ImageGenerator) isJobPending(job *batchv1.Job) bool {
+	// Conditions == empty, Status.Active == 0
+	return len(job.Status.Conditions) == 0 && job.Status.Active == 0
+}
+
 // hasJobCompleted: checks if the job has completed
 func (r *ImageGenerator) hasJobCompleted(job *batchv1.Job) bool {
 	// Job Conditions.Type: Complete, Status: True and Status.Succeeded > 0
@@ -814,16 +1542,44 @@ func (r *ImageGenerator) hasJobCompleted(job *batchv1.Job) bool {
 
 // Method to check the status of the job
 // Success only when the job has completed successfully
-func (r *ImageGenerator) checkJobStatus(jobName, namespace string) (int, error) {
-	job, err := r.clientset.BatchV1().Jobs(namespace).Get(context.TODO(), jobName, metav1.GetOptions{})
+// The failure of a job is recorded as the last failure of its kind
+// The job is read from the cache which might not have caught up with a job
+// that was just created, or the job might have been deleted behind our back.
+// A missing job is reported as RequeueNeeded so that the caller checks again
+// later and recreates the job if it's really gone.
+func (r *ImageGenerator) checkJobStatus(kind imageJobKind, jobName, namespace string) (int, error) {
+	job := &batchv1.Job{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: jobName, Namespace: namespace}, job)
 	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			igLogger.Info("JobStatus: Job not found, requeueing", "job name", jobName)
+			r.forgetJobMetrics(jobName)
+			return RequeueNeeded, nil
+		}
 		return CheckingJobStatusFailed, err
 	}
 
 	if r.hasJobFailed(job) {
+		r.recordJobMetrics(job, jobResultFailed)
+		failure := imageJobFailure{reason: r.getJobFailureReason(job)}
+		message := fmt.Sprintf("PodVM image job (%s) failed", jobName)
 		action := "Check the logs for the job"
-		err = r.createJobEvent(namespace, jobName, PodVMImageJobFailed,
-			fmt.Sprintf("PodVM image job (%s) failed", jobName), corev1.EventTypeWarning, action)
+
+		// The job is deleted right after it has failed so save the log
+		// of the builder container for later diagnosis
+		cmName, firstErrorLine, err := r.saveJobFailureLogs(job)
+		if err != nil {
+			igLogger.Info("error saving logs of the failed Job", "job name", job.Name, "err", err)
+		} else {
+			failure.logsConfigMap = cmName
+			action = fmt.Sprintf("Check the logs saved in the %s ConfigMap", cmName)
+			if firstErrorLine != "" {
+				message = fmt.Sprintf("%s: %s", message, firstErrorLine)
+			}
+		}
+		r.setLastFailure(kind, failure)
+
+		err = r.createJobEvent(namespace, jobName, PodVMImageJobFailed, message, corev1.EventTypeWarning, action)
 		if err != nil {
 			igLogger.Info("error creating event for failed Job", "job name", job.Name, "err", err)
 		}
@@ -831,6 +1587,7 @@ func (r *ImageGenerator) checkJobStatus(jobName, namespace string) (int, error)
 	}
 
 	if r.hasJobCompleted(job) {
+		r.recordJobMetrics(job, jobResultSucceeded)
 		igLogger.Info("JobStatus: Job has completed successfully", "job name", job.Name)
 		action := "Check the pod vm image details in peer-pods-cm configmap"
 		err = r.createJobEvent(namespace, jobName, PodVMImageJobCompleted,
@@ -841,7 +1598,7 @@ func (r *ImageGenerator) checkJobStatus(jobName, namespace string) (int, error)
 		return ImageJobCompleted, nil
 	}
 
-	if r.isJobActive(job) {
+	if r.isJobActive(job) || r.isJobPending(job) {
 		igLogger.Info("JobStatus: Job is still running", "job name", job.Name)
 		action := "Check the logs for the job"
 		err = r.createJobEvent(namespace, jobName, PodVMImageJobRunning,
@@ -855,6 +1612,139 @@ func (r *ImageGenerator) checkJobStatus(jobName, namespace string) (int, error)
 	return CheckingJobStatusFailed, fmt.Errorf("job status check failed %s", jobName)
 }
 
+// Method to get the reason of a failed job
+// The termination message of the most recently terminated container of the
+// job's pods is preferred.  The job's Failed condition is used as a fallback.
+func (r *ImageGenerator) getJobFailureReason(job *batchv1.Job) string {
+	reason := ""
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			reason = strings.TrimSpace(condition.Reason + ": " + condition.Message)
+		}
+	}
+
+	pods, err := r.getJobPods(job)
+	if err != nil {
+		igLogger.Info("error listing pods of the failed job", "job name", job.Name, "err", err)
+		return reason
+	}
+
+	var lastFinishedAt time.Time
+	for _, pod := range pods {
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			terminated := cs.State.Terminated
+			if terminated == nil || terminated.ExitCode == 0 || terminated.Message == "" {
+				continue
+			}
+			if terminated.FinishedAt.Time.After(lastFinishedAt) {
+				lastFinishedAt = terminated.FinishedAt.Time
+				reason = strings.TrimSpace(terminated.Message)
+			}
+		}
+	}
+
+	return reason
+}
+
+// Method to get the pods created for the job
+func (r *ImageGenerator) getJobPods(job *batchv1.Job) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// Method to save the tail of the builder container log of a failed job in a
+// ConfigMap named after the job.  An existing ConfigMap is overwritten, so it
+// always holds the log of the most recent failure.  The ConfigMap is owned by
+// the KataConfig and removed along with it.
+// Returns the name of the ConfigMap and the first error line found in the log
+func (r *ImageGenerator) saveJobFailureLogs(job *batchv1.Job) (string, string, error) {
+	pods, err := r.getJobPods(job)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Pick the most recently created failed pod
+	var failedPod *corev1.Pod
+	for i := range pods {
+		if pods[i].Status.Phase != corev1.PodFailed {
+			continue
+		}
+		if failedPod == nil || pods[i].CreationTimestamp.After(failedPod.CreationTimestamp.Time) {
+			failedPod = &pods[i]
+		}
+	}
+	if failedPod == nil {
+		return "", "", fmt.Errorf("no failed pod found for job %s", job.Name)
+	}
+
+	// Reconcilers set up without a client for the pod logs, like the one of
+	// the envtest suite, don't save them
+	if r.podLogClient == nil {
+		return "", "", fmt.Errorf("no client to read the logs of pod %s", failedPod.Name)
+	}
+
+	// There is only one container in the job
+	containerName := job.Spec.Template.Spec.Containers[0].Name
+	tailLines := int64(imageJobLogTailLines)
+	logs, err := r.podLogClient.Pods(failedPod.Namespace).GetLogs(failedPod.Name, &corev1.PodLogOptions{
+		Container: containerName,
+		TailLines: &tailLines,
+	}).DoRaw(context.TODO())
+	if err != nil {
+		return "", "", err
+	}
+	if len(logs) > imageJobLogMaxBytes {
+		logs = logs[len(logs)-imageJobLogMaxBytes:]
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.Name + imageJobLogsCMSuffix,
+			Namespace: job.Namespace,
+		},
+	}
+	_, err = controllerutil.CreateOrUpdate(context.TODO(), r.client, cm, func() error {
+		if r.owner != nil {
+			if err := controllerutil.SetControllerReference(r.owner, cm, r.client.Scheme()); err != nil {
+				return err
+			}
+		}
+		cm.Data = map[string]string{
+			"job":        job.Name,
+			"pod":        failedPod.Name,
+			"container":  containerName,
+			"failedAt":   time.Now().UTC().Format(time.RFC3339),
+			"log":        string(logs),
+			"tailLines":  strconv.Itoa(imageJobLogTailLines),
+			"firstError": firstErrorLine(string(logs)),
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	igLogger.Info("Saved logs of the failed Job", "job name", job.Name, "pod", failedPod.Name, "configmap", cm.Name)
+	return cm.Name, cm.Data["firstError"], nil
+}
+
+// Returns the first line of the log which looks like an error
+func firstErrorLine(log string) string {
+	for _, line := range strings.Split(log, "\n") {
+		lower := strings.ToLower(line)
+		if strings.Contains(lower, "error") || strings.Contains(lower, "fatal") || strings.Contains(lower, "failed") {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
 // Method to create an event for the job
 func (r *ImageGenerator) createJobEvent(namespace, jobName, reason, message, eventType, action string) error {
 	event := &corev1.Event{
@@ -876,6 +1766,6 @@ func (r *ImageGenerator) createJobEvent(namespace, jobName, reason, message, eve
 		EventTime:           metav1.NewMicroTime(time.Now()),
 	}
 
-	return createKubernetesEvent(r.clientset, event, reason, metav1.CreateOptions{})
+	return createKubernetesEvent(r.client, event, reason)
 
 }
@@ -0,0 +1,569 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const (
+	testClusterID   = "0123abcd"
+	testImageID     = "ami-0123456789abcdef0"
+	createJobName   = "osc-podvm-image-creation"
+	deleteJobName   = "osc-podvm-image-deletion"
+	awsImageCMName  = "aws-podvm-image-cm"
+	testFailureText = "Failed to create the ami"
+)
+
+// imageGeneratorTest holds a reconciler whose ImageGenerator runs against a
+// fake client.  Jobs are never run, the tests move them through their states
+// by setting the job conditions.
+type imageGeneratorTest struct {
+	t *testing.T
+	g *WithT
+	r *KataConfigOpenShiftReconciler
+	c client.Client
+}
+
+type imageGeneratorTestOption func(*interceptor.Funcs, *ImageGeneratorOptions)
+
+func withFailingJobGet() imageGeneratorTestOption {
+	return func(funcs *interceptor.Funcs, _ *ImageGeneratorOptions) {
+		funcs.Get = func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*batchv1.Job); ok {
+				return errors.New("API server unavailable")
+			}
+			return c.Get(ctx, key, obj, opts...)
+		}
+	}
+}
+
+func withFailingJobCreate() imageGeneratorTestOption {
+	return func(funcs *interceptor.Funcs, _ *ImageGeneratorOptions) {
+		funcs.Create = func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*batchv1.Job); ok {
+				return errors.New("admission webhook denied the request")
+			}
+			return c.Create(ctx, obj, opts...)
+		}
+	}
+}
+
+func withFIPSDetector(detector func() (bool, error)) imageGeneratorTestOption {
+	return func(_ *interceptor.Funcs, opts *ImageGeneratorOptions) {
+		opts.FIPSDetector = detector
+	}
+}
+
+func newImageGeneratorTest(t *testing.T, provider string, peerPodsCMData map[string]string, testOpts ...imageGeneratorTestOption) *imageGeneratorTest {
+	t.Setenv("PROVIDER_OVERRIDE", provider)
+
+	peerPodsCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: peerpodsCMName, Namespace: OperatorNamespace},
+		Data: map[string]string{
+			"CLOUD_PROVIDER": provider,
+			"AWS_REGION":     "us-east-2",
+			"AWS_SUBNET_ID":  "subnet-0123",
+			"AWS_VPC_ID":     "vpc-0123",
+			"AWS_SG_IDS":     "sg-0123",
+		},
+	}
+	for k, v := range peerPodsCMData {
+		peerPodsCM.Data[k] = v
+	}
+	peerPodsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: peerPodsSecretName, Namespace: OperatorNamespace},
+		Data: map[string][]byte{
+			"AWS_ACCESS_KEY_ID":     []byte("key-id"),
+			"AWS_SECRET_ACCESS_KEY": []byte("secret"),
+		},
+	}
+
+	r := newTestReconciler(t, nil, nil, peerPodsCM, peerPodsSecret)
+	funcs := interceptor.Funcs{}
+	opts := ImageGeneratorOptions{
+		FIPSDetector:    func() (bool, error) { return false, nil },
+		ClusterIDLookup: func(client.Client) (string, error) { return testClusterID, nil },
+		Manifests:       os.DirFS(filepath.Join("..", "config", "peerpods", "podvm")),
+	}
+	for _, testOpt := range testOpts {
+		testOpt(&funcs, &opts)
+	}
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), funcs)
+	r.PodLogClient = k8sfake.NewSimpleClientset().CoreV1()
+	r.ImageGeneratorOptions = opts
+
+	return &imageGeneratorTest{t: t, g: NewWithT(t), r: r, c: r.Client}
+}
+
+func (it *imageGeneratorTest) getJob(name string) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	err := it.c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: OperatorNamespace}, job)
+	return job, err
+}
+
+func (it *imageGeneratorTest) expectNoJob(name string) {
+	_, err := it.getJob(name)
+	it.g.Expect(k8serrors.IsNotFound(err)).To(BeTrue(), "job %s should not exist", name)
+}
+
+// Moves the job to a final state like the job controller would
+func (it *imageGeneratorTest) finishJob(name string, condType batchv1.JobConditionType) {
+	job, err := it.getJob(name)
+	it.g.Expect(err).NotTo(HaveOccurred())
+
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   condType,
+		Status: corev1.ConditionTrue,
+	})
+	if condType == batchv1.JobComplete {
+		job.Status.Succeeded = 1
+	} else {
+		job.Status.Failed = 1
+	}
+	it.g.Expect(it.c.Status().Update(context.TODO(), job)).To(Succeed())
+}
+
+// Adds a failed pod to the job like the job controller would
+func (it *imageGeneratorTest) addFailedJobPod(jobName, message string) {
+	job, err := it.getJob(jobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName + "-abcde",
+			Namespace: OperatorNamespace,
+			Labels:    map[string]string{"job-name": jobName},
+		},
+		Spec: job.Spec.Template.Spec,
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: job.Spec.Template.Spec.Containers[0].Name,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode:   1,
+					Message:    message,
+					FinishedAt: metav1.Now(),
+				}},
+			}},
+		},
+	}
+	it.g.Expect(it.c.Create(context.TODO(), pod)).To(Succeed())
+}
+
+func (it *imageGeneratorTest) updatePeerPodsCM(update func(cm *corev1.ConfigMap)) {
+	cm := &corev1.ConfigMap{}
+	it.g.Expect(it.c.Get(context.TODO(), types.NamespacedName{Name: peerpodsCMName, Namespace: OperatorNamespace}, cm)).To(Succeed())
+	update(cm)
+	it.g.Expect(it.c.Update(context.TODO(), cm)).To(Succeed())
+}
+
+func (it *imageGeneratorTest) setImageID(imageID string) {
+	it.updatePeerPodsCM(func(cm *corev1.ConfigMap) {
+		cm.Data[peerpodsCMAWSImageKey] = imageID
+	})
+}
+
+// Each call stands for a reconcile and gets a new ImageGenerator
+func (it *imageGeneratorTest) expectCreate(expectedStatus int, expectedErr error) {
+	it.t.Helper()
+	it.r.imageGenerator = nil
+	status, err := it.r.imageCreate()
+	it.g.Expect(status).To(Equal(expectedStatus))
+	if expectedErr == nil {
+		it.g.Expect(err).NotTo(HaveOccurred())
+	} else {
+		it.g.Expect(err).To(MatchError(expectedErr))
+	}
+}
+
+func (it *imageGeneratorTest) expectDelete(expectedStatus int, expectedErr error) {
+	it.t.Helper()
+	it.r.imageGenerator = nil
+	status, err := it.r.imageDelete()
+	it.g.Expect(status).To(Equal(expectedStatus))
+	if expectedErr == nil {
+		it.g.Expect(err).NotTo(HaveOccurred())
+	} else {
+		it.g.Expect(err).To(MatchError(expectedErr))
+	}
+}
+
+func jobEnv(job *batchv1.Job, name string) string {
+	value := ""
+	for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == name {
+			value = env.Value
+		}
+	}
+	return value
+}
+
+func TestImageCreateUnsupportedProvider(t *testing.T) {
+	it := newImageGeneratorTest(t, "gcp", nil)
+	it.expectCreate(UnsupportedPodVMImageProvider, ErrUnsupportedCloudProvider)
+	it.expectNoJob(createJobName)
+}
+
+func TestImageCreateInitializationFailure(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil, withFIPSDetector(func() (bool, error) {
+		return false, errors.New("no FIPS setting")
+	}))
+	it.expectCreate(ImageCreationFailed, ErrInitializingImageGenerator)
+}
+
+func TestImageCreateInvalidPeerPodsConfig(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, map[string]string{"AWS_VPC_ID": ""})
+	it.expectCreate(ImageCreationFailed, ErrValidatingPeerPodsConfigs)
+	it.expectNoJob(createJobName)
+}
+
+func TestImageCreateMissingPeerPodsConfig(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.g.Expect(it.c.Delete(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: peerpodsCMName, Namespace: OperatorNamespace},
+	})).To(Succeed())
+	it.expectCreate(ImageCreationFailed, ErrValidatingPeerPodsConfigs)
+}
+
+func TestImageCreateJobSucceeds(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	job, err := it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(job.Labels).To(HaveKeyWithValue(PodVMImageJobLabel, "true"))
+	it.g.Expect(jobEnv(job, "CLUSTER_ID")).To(Equal(testClusterID))
+
+	imageCM := &corev1.ConfigMap{}
+	it.g.Expect(it.c.Get(context.TODO(), types.NamespacedName{Name: awsImageCMName, Namespace: OperatorNamespace}, imageCM)).To(Succeed())
+
+	// Nothing changes while the job is running
+	it.expectCreate(ImageCreationInProgress, nil)
+
+	// The job has completed but didn't record the image
+	it.finishJob(createJobName, batchv1.JobComplete)
+	it.expectCreate(RequeueNeeded, nil)
+
+	it.setImageID(testImageID)
+	it.expectCreate(ImageCreatedSuccessfully, nil)
+	it.expectNoJob(createJobName)
+	it.g.Expect(it.r.kataConfig.Status.PodVMImage.ImportedImage).To(BeEmpty())
+
+	// No new job once the image exists
+	it.expectCreate(ImageCreatedSuccessfully, nil)
+	it.expectNoJob(createJobName)
+}
+
+func TestImageCreateFromOCIArtifact(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.r.kataConfig.Spec.PodVMImage = &kataconfigurationv1.PodVMImageSpec{
+		OCIArtifact: &kataconfigurationv1.PodVMImageOCIArtifact{
+			Image:    "quay.io/example/podvm:1.0",
+			DiskPath: "/image/podvm.qcow2",
+		},
+	}
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	job, err := it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(jobEnv(job, "PODVM_IMAGE_URI")).To(Equal("oci::quay.io/example/podvm:1.0::/image/podvm.qcow2"))
+}
+
+func TestImageCreateJobOverrides(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.r.kataConfig.Spec.Workloads = &kataconfigurationv1.WorkloadsSpec{
+		PodVMImageJobs: &kataconfigurationv1.WorkloadOverrides{
+			NodeSelector:      map[string]string{"node-role.kubernetes.io/infra": ""},
+			PriorityClassName: "low-priority",
+			ImagePullPolicy:   corev1.PullIfNotPresent,
+		},
+	}
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	job, err := it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+	podSpec := job.Spec.Template.Spec
+	it.g.Expect(podSpec.NodeSelector).To(HaveKeyWithValue("node-role.kubernetes.io/infra", ""))
+	it.g.Expect(podSpec.PriorityClassName).To(Equal("low-priority"))
+	it.g.Expect(podSpec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+	it.g.Expect(podSpec.InitContainers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+}
+
+func TestImageCreateJobFails(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	it.addFailedJobPod(createJobName, testFailureText)
+	it.finishJob(createJobName, batchv1.JobFailed)
+
+	it.expectCreate(ImageCreationFailed, nil)
+	it.expectNoJob(createJobName)
+
+	ig := it.r.imageGenerator
+	it.g.Expect(ig.LastFailureReason(imageJobCreate)).To(Equal(testFailureText))
+	it.g.Expect(ig.LastFailureLogsConfigMap(imageJobCreate)).To(Equal(createJobName + imageJobLogsCMSuffix))
+
+	logsCM := &corev1.ConfigMap{}
+	it.g.Expect(it.c.Get(context.TODO(), types.NamespacedName{Name: ig.LastFailureLogsConfigMap(imageJobCreate), Namespace: OperatorNamespace}, logsCM)).To(Succeed())
+	it.g.Expect(logsCM.Data).To(HaveKeyWithValue("job", createJobName))
+	it.g.Expect(logsCM.Data).To(HaveKey("log"))
+	it.g.Expect(metav1.IsControlledBy(logsCM, it.r.kataConfig)).To(BeTrue())
+
+	// The next attempt starts a new job
+	it.expectCreate(ImageCreationInProgress, nil)
+	_, err := it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+
+	// The log of a failed job whose pod is gone can't be saved, the
+	// ConfigMap of the previous failure isn't reported for it
+	it.g.Expect(it.c.DeleteAllOf(context.TODO(), &corev1.Pod{}, client.InNamespace(OperatorNamespace))).To(Succeed())
+	it.finishJob(createJobName, batchv1.JobFailed)
+	it.expectCreate(ImageCreationFailed, nil)
+	it.g.Expect(it.r.imageGenerator.LastFailureLogsConfigMap(imageJobCreate)).To(BeEmpty())
+}
+
+func TestImageCreateJobFailsWithoutPodLogClient(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.r.PodLogClient = nil
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	it.addFailedJobPod(createJobName, testFailureText)
+	it.finishJob(createJobName, batchv1.JobFailed)
+
+	// The failure is still recorded, only the logs aren't saved
+	it.expectCreate(ImageCreationFailed, nil)
+	it.g.Expect(it.r.imageGenerator.LastFailureReason(imageJobCreate)).To(Equal(testFailureText))
+	it.g.Expect(it.r.imageGenerator.LastFailureLogsConfigMap(imageJobCreate)).To(BeEmpty())
+}
+
+func TestImageCreateJobStatusUnknown(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil, withFailingJobGet())
+	it.expectCreate(ImageCreationStatusUnknown, ErrCheckingJobStatus)
+}
+
+func TestImageCreateJobCreationFails(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil, withFailingJobCreate())
+	it.expectCreate(RequeueNeeded, ErrCreatingImageJob)
+}
+
+func TestImageCreateJobMissing(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+
+	// A job that isn't found after its creation isn't reported as running
+	ig, err := it.r.getImageGenerator()
+	it.g.Expect(err).NotTo(HaveOccurred())
+	status, err := ig.checkJobStatus(imageJobCreate, createJobName, OperatorNamespace)
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(status).To(Equal(RequeueNeeded))
+
+	// A job deleted behind our back is recreated
+	it.expectCreate(ImageCreationInProgress, nil)
+	job, err := it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(it.c.Delete(context.TODO(), job)).To(Succeed())
+	it.expectCreate(ImageCreationInProgress, nil)
+	_, err = it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestImageCreateSetsFIPS(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil, withFIPSDetector(func() (bool, error) {
+		return true, nil
+	}))
+
+	it.expectCreate(ImageCreationInProgress, nil)
+
+	imageCM := &corev1.ConfigMap{}
+	it.g.Expect(it.c.Get(context.TODO(), types.NamespacedName{Name: awsImageCMName, Namespace: OperatorNamespace}, imageCM)).To(Succeed())
+	it.g.Expect(imageCM.Data).To(HaveKeyWithValue(fipsCMKey, "true"))
+}
+
+func TestImageImport(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.r.kataConfig.Spec.PodVMImage = &kataconfigurationv1.PodVMImageSpec{
+		Import: &kataconfigurationv1.PodVMImageImportSpec{AMIID: testImageID},
+	}
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	job, err := it.getJob("osc-podvm-image-import")
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(jobEnv(job, "AMI_ID")).To(Equal(testImageID))
+
+	it.finishJob(job.Name, batchv1.JobComplete)
+	it.expectCreate(RequeueNeeded, nil)
+
+	// Record the image like the import job does
+	it.updatePeerPodsCM(func(cm *corev1.ConfigMap) {
+		cm.Data[peerpodsCMAWSImageKey] = testImageID
+		cm.Annotations = map[string]string{peerpodsCMImportedImageAnnotation: testImageID}
+	})
+	it.expectCreate(ImageCreatedSuccessfully, nil)
+	it.expectNoJob(job.Name)
+	it.g.Expect(it.r.kataConfig.Status.PodVMImage.ImportedImage).To(Equal(testImageID))
+}
+
+func TestImageImportProviderMismatch(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.r.kataConfig.Spec.PodVMImage = &kataconfigurationv1.PodVMImageSpec{
+		Import: &kataconfigurationv1.PodVMImageImportSpec{URL: "https://example.com/podvm.qcow2"},
+	}
+
+	it.expectCreate(ImageCreationFailed, nil)
+	it.g.Expect(it.r.imageGenerator.LastFailureReason(imageJobCreate)).To(ContainSubstring(ErrInvalidImageImport.Error()))
+	it.expectNoJob("osc-podvm-image-import")
+}
+
+// Runs the image import job to completion like the job would
+func (it *imageGeneratorTest) importImage(imageRef string) {
+	it.t.Helper()
+	it.r.kataConfig.Spec.PodVMImage = &kataconfigurationv1.PodVMImageSpec{
+		Import: &kataconfigurationv1.PodVMImageImportSpec{AMIID: imageRef},
+	}
+	it.expectCreate(ImageCreationInProgress, nil)
+	it.finishJob("osc-podvm-image-import", batchv1.JobComplete)
+	it.updatePeerPodsCM(func(cm *corev1.ConfigMap) {
+		cm.Data[peerpodsCMAWSImageKey] = imageRef
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[peerpodsCMImportedImageAnnotation] = imageRef
+	})
+	it.expectCreate(ImageCreatedSuccessfully, nil)
+	it.expectNoJob("osc-podvm-image-import")
+}
+
+func TestImageImportNewReference(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.importImage(testImageID)
+
+	// Changing the reference imports the new image
+	it.r.kataConfig.Spec.PodVMImage.Import.AMIID = "ami-0fedcba9876543210"
+	it.expectCreate(ImageCreationInProgress, nil)
+	job, err := it.getJob("osc-podvm-image-import")
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(jobEnv(job, "AMI_ID")).To(Equal("ami-0fedcba9876543210"))
+}
+
+func TestImageImportJobFails(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.r.kataConfig.Spec.PodVMImage = &kataconfigurationv1.PodVMImageSpec{
+		Import: &kataconfigurationv1.PodVMImageImportSpec{AMIID: testImageID},
+	}
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	it.addFailedJobPod("osc-podvm-image-import", "AMI not found")
+	it.finishJob("osc-podvm-image-import", batchv1.JobFailed)
+	it.expectCreate(ImageCreationFailed, nil)
+	it.expectNoJob("osc-podvm-image-import")
+	it.g.Expect(it.r.imageGenerator.LastFailureReason(imageJobCreate)).To(Equal("AMI not found"))
+}
+
+func TestImageImportSwitchBackToBuild(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.importImage(testImageID)
+	it.g.Expect(it.r.kataConfig.Status.PodVMImage.ImportedImage).To(Equal(testImageID))
+
+	// Dropping the import builds a new image in place of the imported one
+	it.r.kataConfig.Spec.PodVMImage = nil
+	it.expectCreate(ImageCreationInProgress, nil)
+	_, err := it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+
+	// The creation job replaces the image and drops the import annotation
+	it.updatePeerPodsCM(func(cm *corev1.ConfigMap) {
+		cm.Data[peerpodsCMAWSImageKey] = "ami-0fedcba9876543210"
+		delete(cm.Annotations, peerpodsCMImportedImageAnnotation)
+	})
+	it.finishJob(createJobName, batchv1.JobComplete)
+	it.expectCreate(ImageCreatedSuccessfully, nil)
+	it.expectNoJob(createJobName)
+	it.g.Expect(it.r.kataConfig.Status.PodVMImage.ImportedImage).To(BeEmpty())
+
+	peerPodsCM := &corev1.ConfigMap{}
+	it.g.Expect(it.c.Get(context.TODO(), types.NamespacedName{Name: peerpodsCMName, Namespace: OperatorNamespace}, peerPodsCM)).To(Succeed())
+	it.g.Expect(peerPodsCM.Annotations).To(HaveKeyWithValue(peerpodsCMImageSourceAnnotation, podvmImageSourceBuild))
+
+	// Building from an OCI artifact instead replaces the image again
+	it.r.kataConfig.Spec.PodVMImage = &kataconfigurationv1.PodVMImageSpec{
+		OCIArtifact: &kataconfigurationv1.PodVMImageOCIArtifact{Image: "quay.io/example/podvm:1.0"},
+	}
+	it.expectCreate(ImageCreationInProgress, nil)
+	_, err = it.getJob(createJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestImageDeleteUnsupportedProvider(t *testing.T) {
+	it := newImageGeneratorTest(t, "gcp", nil)
+	it.expectDelete(UnsupportedPodVMImageProvider, ErrUnsupportedCloudProvider)
+}
+
+func TestImageDeleteInvalidPeerPodsConfig(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, map[string]string{"AWS_REGION": ""})
+	it.expectDelete(ImageDeletionFailed, ErrValidatingPeerPodsConfigs)
+}
+
+func TestImageDeleteWithoutImage(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	it.expectDelete(ImageDeletedSuccessfully, nil)
+	it.expectNoJob(deleteJobName)
+}
+
+func TestImageDeleteJobSucceeds(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, map[string]string{peerpodsCMAWSImageKey: testImageID})
+
+	it.expectDelete(ImageDeletionInProgress, nil)
+	job, err := it.getJob(deleteJobName)
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(jobEnv(job, "AMI_ID")).To(Equal(testImageID))
+
+	// The job has completed but didn't clear the image ID
+	it.finishJob(deleteJobName, batchv1.JobComplete)
+	it.expectDelete(RequeueNeeded, nil)
+
+	it.setImageID("")
+	it.expectDelete(ImageDeletedSuccessfully, nil)
+	it.expectNoJob(deleteJobName)
+}
+
+func TestImageDeleteJobFails(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, map[string]string{peerpodsCMAWSImageKey: testImageID})
+
+	it.expectDelete(ImageDeletionInProgress, nil)
+	it.addFailedJobPod(deleteJobName, "Failed to delete the ami")
+	it.finishJob(deleteJobName, batchv1.JobFailed)
+
+	it.expectDelete(ImageDeletionFailed, nil)
+	it.expectNoJob(deleteJobName)
+	it.g.Expect(it.r.imageGenerator.LastFailureReason(imageJobDelete)).To(Equal("Failed to delete the ami"))
+	// Failures of other kinds of jobs are recorded separately
+	it.g.Expect(it.r.imageGenerator.LastFailureReason(imageJobCreate)).To(BeEmpty())
+}
+
+func TestImageDeleteJobStatusUnknown(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, map[string]string{peerpodsCMAWSImageKey: testImageID}, withFailingJobGet())
+	it.expectDelete(ImageDeletionStatusUnknown, ErrCheckingJobStatus)
+}
+
+func TestImageDeleteSkipsImportedImage(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, map[string]string{peerpodsCMAWSImageKey: testImageID})
+	it.updatePeerPodsCM(func(cm *corev1.ConfigMap) {
+		cm.Annotations = map[string]string{peerpodsCMImportedImageAnnotation: testImageID}
+	})
+
+	it.expectDelete(ImageDeletionSkipped, nil)
+	it.expectNoJob(deleteJobName)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// JobEventHandler triggers a reconcile when a pod VM image job is created or
+// changes its state.  This replaces polling of the job status.
+type JobEventHandler struct {
+	reconciler *KataConfigOpenShiftReconciler
+}
+
+func isPodVMImageJob(job client.Object) bool {
+	return job.GetNamespace() == OperatorNamespace && job.GetLabels()[PodVMImageJobLabel] == "true"
+}
+
+func (jh *JobEventHandler) Create(ctx context.Context, event event.CreateEvent, queue workqueue.RateLimitingInterface) {
+
+	if jh.reconciler.kataConfig == nil {
+		return
+	}
+
+	job := event.Object
+
+	if !isPodVMImageJob(job) {
+		return
+	}
+	log := jh.reconciler.Log.WithName("JobCreate").WithValues("job name", job.GetName())
+	log.Info("PodVM image job created")
+
+	queue.Add(jh.reconciler.makeReconcileRequest())
+}
+
+func (jh *JobEventHandler) Update(ctx context.Context, event event.UpdateEvent, queue workqueue.RateLimitingInterface) {
+
+	if jh.reconciler.kataConfig == nil {
+		return
+	}
+
+	job := event.ObjectNew
+	jobOld := event.ObjectOld
+
+	if !isPodVMImageJob(job) {
+		return
+	}
+
+	// Only the job conditions tell whether the job has finished, changes
+	// of the active/failed counters while it's running are of no interest
+	conditionsOld := jobOld.(*batchv1.Job).Status.Conditions
+	conditionsNew := job.(*batchv1.Job).Status.Conditions
+	if reflect.DeepEqual(conditionsOld, conditionsNew) {
+		return
+	}
+
+	log := jh.reconciler.Log.WithName("JobUpdate").WithValues("job name", job.GetName())
+	log.Info("PodVM image job conditions changed")
+
+	queue.Add(jh.reconciler.makeReconcileRequest())
+}
+
+func (jh *JobEventHandler) Delete(ctx context.Context, event event.DeleteEvent, queue workqueue.RateLimitingInterface) {
+}
+
+func (jh *JobEventHandler) Generic(ctx context.Context, event event.GenericEvent, queue workqueue.RateLimitingInterface) {
+}
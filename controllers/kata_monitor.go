@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	kataMonitorName         = "openshift-sandboxed-containers-monitor"
+	kataMonitorServiceName  = "metrics"
+	kataMonitorRulesName    = "prometheus-sandboxed-containers-rules"
+	kataMonitorPortName     = "metrics"
+	kataMonitorPort         = 8090
+	kataMonitorRuleGroup    = "kata_monitor_rules"
+	defaultKataMonitorLevel = "info"
+
+	defaultKataMonitorDownMinutes = 10
+	defaultKataMonitorScrapeFails = 5
+
+	// Annotation holding the hash of the daemonset spec the operator
+	// created kata-monitor with
+	kataMonitorSpecHashAnnotation = "kataconfiguration.openshift.io/spec-hash"
+)
+
+func (r *KataConfigOpenShiftReconciler) isKataMonitorEnabled() bool {
+	monitoring := r.kataConfig.Spec.Monitoring
+	return monitoring == nil || monitoring.Enabled == nil || *monitoring.Enabled
+}
+
+func (r *KataConfigOpenShiftReconciler) getKataMonitorLogLevel() string {
+	if monitoring := r.kataConfig.Spec.Monitoring; monitoring != nil && monitoring.LogLevel != "" {
+		return monitoring.LogLevel
+	}
+	return defaultKataMonitorLevel
+}
+
+// Returns the thresholds of the KataMonitorDown and KataMonitorScrapeFailures
+// alerts
+func (r *KataConfigOpenShiftReconciler) getKataMonitorAlertThresholds() (int32, int32) {
+	downMinutes, scrapeFailures := int32(defaultKataMonitorDownMinutes), int32(defaultKataMonitorScrapeFails)
+	if r.kataConfig.Spec.Monitoring == nil || r.kataConfig.Spec.Monitoring.Alerts == nil {
+		return downMinutes, scrapeFailures
+	}
+	alerts := r.kataConfig.Spec.Monitoring.Alerts
+	if alerts.KataMonitorDownMinutes != nil {
+		downMinutes = *alerts.KataMonitorDownMinutes
+	}
+	if alerts.ScrapeFailures != nil {
+		scrapeFailures = *alerts.ScrapeFailures
+	}
+	return downMinutes, scrapeFailures
+}
+
+// Creates or updates kata-monitor and the objects getting its metrics
+// scraped and alerted on, or removes them all if monitoring is disabled
+func (r *KataConfigOpenShiftReconciler) reconcileKataMonitor() error {
+	if !r.isKataMonitorEnabled() {
+		r.Log.Info("monitoring is disabled, removing kata-monitor")
+		return r.deleteKataMonitor()
+	}
+
+	desiredDs := r.processDaemonsetForMonitor()
+
+	// The API server defaults many fields of the spec, compare a hash of
+	// the spec the operator wants instead to only update the daemonset,
+	// and roll it out, when the operator changes it
+	specJSON, err := json.Marshal(desiredDs.Spec)
+	if err != nil {
+		return err
+	}
+	specHash := sha256.Sum256(specJSON)
+
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: desiredDs.Name, Namespace: desiredDs.Namespace}}
+	if err := r.createOrUpdateOwnedObject(ds, func() {
+		if ds.Annotations[kataMonitorSpecHashAnnotation] == hex.EncodeToString(specHash[:]) {
+			return
+		}
+		if ds.Annotations == nil {
+			ds.Annotations = map[string]string{}
+		}
+		ds.Annotations[kataMonitorSpecHashAnnotation] = hex.EncodeToString(specHash[:])
+		ds.Spec = desiredDs.Spec
+	}); err != nil {
+		r.Log.Info("error creating or updating the monitor daemonset", "err", err)
+		return err
+	}
+
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorServiceName, Namespace: OperatorNamespace}}
+	if err := r.createOrUpdateOwnedObject(svc, func() {
+		svc.Labels = map[string]string{"name": kataMonitorName}
+		svc.Spec.Selector = map[string]string{"name": kataMonitorName}
+		svc.Spec.Ports = []corev1.ServicePort{
+			{
+				Name:       kataMonitorPortName,
+				Port:       kataMonitorPort,
+				Protocol:   corev1.ProtocolTCP,
+				TargetPort: intstr.FromInt32(kataMonitorPort),
+			},
+		}
+	}); err != nil {
+		r.Log.Info("error creating or updating the kata-monitor service", "err", err)
+		return err
+	}
+
+	sm := &monitoringv1.ServiceMonitor{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorName, Namespace: OperatorNamespace}}
+	err = r.createOrUpdateOwnedObject(sm, func() {
+		sm.Spec.NamespaceSelector = monitoringv1.NamespaceSelector{MatchNames: []string{OperatorNamespace}}
+		sm.Spec.Selector = metav1.LabelSelector{MatchLabels: map[string]string{"name": kataMonitorName}}
+		sm.Spec.Endpoints = []monitoringv1.Endpoint{{Port: kataMonitorPortName}}
+	})
+	if meta.IsNoMatchError(err) {
+		// Without the Prometheus operator there's nothing to scrape the
+		// metrics nor to evaluate the alerts
+		r.Log.Info("monitoring.coreos.com API unavailable, not creating the kata-monitor ServiceMonitor and PrometheusRule")
+		return nil
+	} else if err != nil {
+		r.Log.Info("error creating or updating the kata-monitor ServiceMonitor", "err", err)
+		return err
+	}
+
+	rule := &monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorRulesName, Namespace: OperatorNamespace}}
+	if err := r.createOrUpdateOwnedObject(rule, func() {
+		rule.Spec.Groups = r.getKataMonitorRuleGroups()
+	}); err != nil {
+		r.Log.Info("error creating or updating the kata-monitor PrometheusRule", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// Creates or updates an object owned by the KataConfig, mutate sets the
+// desired state on the object read from the cluster
+func (r *KataConfigOpenShiftReconciler) createOrUpdateOwnedObject(obj client.Object, mutate func()) error {
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), r.Client, obj, func() error {
+		mutate()
+		return controllerutil.SetControllerReference(r.kataConfig, obj, r.Scheme)
+	})
+	return err
+}
+
+func (r *KataConfigOpenShiftReconciler) getKataMonitorRuleGroups() []monitoringv1.RuleGroup {
+	downMinutes, scrapeFailures := r.getKataMonitorAlertThresholds()
+	downFor := monitoringv1.Duration(fmt.Sprintf("%dm", downMinutes))
+
+	return []monitoringv1.RuleGroup{
+		{
+			Name: kataMonitorRuleGroup,
+			Rules: []monitoringv1.Rule{
+				{
+					Record: "cluster:kata_monitor_running_shim_count:sum",
+					Expr:   intstr.FromString("sum(kata_monitor_running_shim_count)"),
+				},
+				{
+					Alert: "KataMonitorDown",
+					Expr:  intstr.FromString(fmt.Sprintf(`up{job="%s",namespace="%s"} == 0`, kataMonitorServiceName, OperatorNamespace)),
+					For:   &downFor,
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary":     "kata-monitor is down",
+						"description": fmt.Sprintf("kata-monitor on {{ $labels.pod }} has not been scraped for %d minutes, the metrics of the kata sandboxes of its node are missing.", downMinutes),
+					},
+				},
+				{
+					Alert: "KataMonitorScrapeFailures",
+					Expr:  intstr.FromString(fmt.Sprintf("increase(kata_monitor_scrape_failed_count[10m]) >= %d", scrapeFailures)),
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary":     "kata-monitor fails to read the metrics of kata sandboxes",
+						"description": "kata-monitor on {{ $labels.pod }} failed {{ $value }} times to scrape the metrics of the kata sandboxes of its node over the last 10 minutes.",
+					},
+				},
+			},
+		},
+	}
+}
+
+// Removes kata-monitor and the objects getting its metrics scraped and
+// alerted on
+func (r *KataConfigOpenShiftReconciler) deleteKataMonitor() error {
+	for _, obj := range []client.Object{
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorName, Namespace: OperatorNamespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorServiceName, Namespace: OperatorNamespace}},
+		&monitoringv1.ServiceMonitor{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorName, Namespace: OperatorNamespace}},
+		&monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorRulesName, Namespace: OperatorNamespace}},
+	} {
+		err := r.Client.Delete(context.TODO(), obj)
+		if err != nil && !k8serrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			r.Log.Info("error deleting kata-monitor object", "name", obj.GetName(), "err", err)
+			return err
+		}
+	}
+	r.Log.Info("kata-monitor removed")
+	return nil
+}
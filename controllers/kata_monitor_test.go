@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestKataMonitor(t *testing.T) {
+	g := NewWithT(t)
+	downMinutes := int32(20)
+	kataConfig := &kataconfigurationv1.KataConfig{}
+	kataConfig.Spec.Monitoring = &kataconfigurationv1.MonitoringSpec{
+		LogLevel: "warn",
+		Alerts:   &kataconfigurationv1.MonitoringAlertsSpec{KataMonitorDownMinutes: &downMinutes},
+	}
+	r := newTestReconciler(t, kataConfig, monitoringTestSchemes)
+
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorName, Namespace: OperatorNamespace}}
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorServiceName, Namespace: OperatorNamespace}}
+	sm := &monitoringv1.ServiceMonitor{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorName, Namespace: OperatorNamespace}}
+	rule := &monitoringv1.PrometheusRule{ObjectMeta: metav1.ObjectMeta{Name: kataMonitorRulesName, Namespace: OperatorNamespace}}
+
+	g.Expect(r.reconcileKataMonitor()).To(Succeed())
+	for _, obj := range []client.Object{ds, svc, sm, rule} {
+		expectObjectExists(g, r.Client, obj, true)
+		g.Expect(metav1.IsControlledBy(obj, r.kataConfig)).To(BeTrue(), "%s should be owned by the KataConfig", obj.GetName())
+	}
+	g.Expect(ds.Spec.Template.Spec.Containers[0].Command).To(ContainElement("--log-level=warn"))
+	g.Expect(svc.Spec.Ports[0].Port).To(Equal(int32(kataMonitorPort)))
+
+	// Fields defaulted by the API server don't trigger an update
+	revisionHistoryLimit := int32(10)
+	ds.Spec.RevisionHistoryLimit = &revisionHistoryLimit
+	g.Expect(r.Client.Update(context.TODO(), ds)).To(Succeed())
+	resourceVersion := ds.ResourceVersion
+	g.Expect(r.reconcileKataMonitor()).To(Succeed())
+	expectObjectExists(g, r.Client, ds, true)
+	g.Expect(ds.ResourceVersion).To(Equal(resourceVersion))
+
+	// Changing the spec the operator wants does
+	r.kataConfig.Spec.Monitoring.LogLevel = "debug"
+	g.Expect(r.reconcileKataMonitor()).To(Succeed())
+	expectObjectExists(g, r.Client, ds, true)
+	g.Expect(ds.Spec.Template.Spec.Containers[0].Command).To(ContainElement("--log-level=debug"))
+
+	alerts := map[string]monitoringv1.Rule{}
+	for _, promRule := range rule.Spec.Groups[0].Rules {
+		alerts[promRule.Alert] = promRule
+	}
+	g.Expect(string(*alerts["KataMonitorDown"].For)).To(Equal("20m"))
+	g.Expect(alerts["KataMonitorScrapeFailures"].Expr.StrVal).To(HaveSuffix(">= 5"))
+
+	// Disabling monitoring removes everything
+	enabled := false
+	r.kataConfig.Spec.Monitoring.Enabled = &enabled
+	g.Expect(r.reconcileKataMonitor()).To(Succeed())
+	for _, obj := range []client.Object{ds, svc, sm, rule} {
+		expectObjectExists(g, r.Client, obj, false)
+	}
+
+	// Uninstalling with nothing left to remove succeeds
+	g.Expect(r.deleteKataMonitor()).To(Succeed())
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"text/template"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+)
+
+const (
+	kataRemoteConfigTemplate = "configuration-remote.toml.tmpl"
+	kataRemoteConfigPath     = "/opt/kata/configuration-remote.toml"
+	kataRemoteConfigMode     = 0644
+)
+
+// Values rendered into configuration-remote.toml.tmpl, see
+// KataConfig.spec.kataRemoteConfig for their meaning
+type kataRemoteConfig struct {
+	RemoteHypervisorTimeout int32
+	AgentDialTimeout        int32
+	CreateContainerTimeout  int32
+	DefaultVCPUs            int32
+	DefaultMemoryMiB        int32
+	EnableAnnotations       []string
+}
+
+// Returns the kata-remote settings from KataConfig.spec.kataRemoteConfig
+// with defaults filled in for anything the user didn't set
+func getKataRemoteConfig(spec *kataconfigurationv1.KataRemoteConfigSpec) kataRemoteConfig {
+	config := kataRemoteConfig{
+		RemoteHypervisorTimeout: 900,
+		AgentDialTimeout:        30,
+		CreateContainerTimeout:  900,
+		DefaultVCPUs:            1,
+		DefaultMemoryMiB:        2048,
+		EnableAnnotations:       []string{"default_vcpus", "default_memory", "machine_type"},
+	}
+
+	if spec == nil {
+		return config
+	}
+
+	if spec.RemoteHypervisorTimeout != nil {
+		config.RemoteHypervisorTimeout = *spec.RemoteHypervisorTimeout
+	}
+	if spec.AgentDialTimeout != nil {
+		config.AgentDialTimeout = *spec.AgentDialTimeout
+	}
+	if spec.CreateContainerTimeout != nil {
+		config.CreateContainerTimeout = *spec.CreateContainerTimeout
+	}
+	if spec.DefaultVCPUs != nil {
+		config.DefaultVCPUs = *spec.DefaultVCPUs
+	}
+	if spec.DefaultMemoryMiB != nil {
+		config.DefaultMemoryMiB = *spec.DefaultMemoryMiB
+	}
+	if len(spec.EnableAnnotations) > 0 {
+		config.EnableAnnotations = spec.EnableAnnotations
+	}
+	return config
+}
+
+// Formats a value as TOML.  Only used for strings and lists of strings
+// whose JSON encoding is valid TOML as well.
+func tomlValue(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Renders configuration-remote.toml from the template in the peer-pods
+// manifests
+func renderKataRemoteConfig(manifests fs.FS, config kataRemoteConfig) ([]byte, error) {
+	templateData, err := fs.ReadFile(manifests, kataRemoteConfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(kataRemoteConfigTemplate).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"toml": tomlValue}).
+		Parse(string(templateData))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, config); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Returns the Ignition file entry writing configuration-remote.toml.  The
+// contents are gzip compressed the same way Butane does it to keep the
+// MachineConfig small.
+func kataRemoteConfigIgnitionFile(contents []byte) (ignTypes.File, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(contents); err != nil {
+		return ignTypes.File{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return ignTypes.File{}, err
+	}
+
+	source := "data:;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	compression := "gzip"
+	mode := kataRemoteConfigMode
+
+	return ignTypes.File{
+		Node: ignTypes.Node{
+			Path: kataRemoteConfigPath,
+		},
+		FileEmbedded1: ignTypes.FileEmbedded1{
+			Contents: ignTypes.Resource{
+				Compression: &compression,
+				Source:      &source,
+			},
+			Mode: &mode,
+		},
+	}, nil
+}
+
+// Adds configuration-remote.toml rendered from
+// KataConfig.spec.kataRemoteConfig to the Ignition config of the kata-remote
+// configuration MachineConfig
+func (r *KataConfigOpenShiftReconciler) addKataRemoteConfigFile(machineConfig *mcfgv1.MachineConfig) error {
+	contents, err := renderKataRemoteConfig(peerPodsManifestsOrDefault(r.Manifests), getKataRemoteConfig(r.kataConfig.Spec.KataRemoteConfig))
+	if err != nil {
+		r.Log.Info("Error in rendering the kata remote configuration", "err", err)
+		return err
+	}
+
+	file, err := kataRemoteConfigIgnitionFile(contents)
+	if err != nil {
+		return err
+	}
+
+	ic := ignTypes.Config{}
+	if len(machineConfig.Spec.Config.Raw) > 0 {
+		if err := json.Unmarshal(machineConfig.Spec.Config.Raw, &ic); err != nil {
+			r.Log.Info("Error in parsing the Ignition config of the MachineConfig", "mc", machineConfig.Name, "err", err)
+			return err
+		}
+	}
+	ic.Storage.Files = append(ic.Storage.Files, file)
+
+	icb, err := json.Marshal(ic)
+	if err != nil {
+		return err
+	}
+	machineConfig.Spec.Config.Raw = icb
+	return nil
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	ignTypes "github.com/coreos/ignition/v2/config/v3_2/types"
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+)
+
+// Returns the decompressed contents of an Ignition file written by
+// kataRemoteConfigIgnitionFile
+func readIgnitionFileContents(g *WithT, file ignTypes.File) string {
+	g.Expect(file.Contents.Compression).To(HaveValue(Equal("gzip")))
+	g.Expect(file.Contents.Source).NotTo(BeNil())
+	source, ok := strings.CutPrefix(*file.Contents.Source, "data:;base64,")
+	g.Expect(ok).To(BeTrue())
+
+	compressed, err := base64.StdEncoding.DecodeString(source)
+	g.Expect(err).NotTo(HaveOccurred())
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	g.Expect(err).NotTo(HaveOccurred())
+	contents, err := io.ReadAll(gz)
+	g.Expect(err).NotTo(HaveOccurred())
+	return string(contents)
+}
+
+func TestKataRemoteConfigDefaults(t *testing.T) {
+	g := NewWithT(t)
+
+	contents, err := renderKataRemoteConfig(NewPeerPodsManifests(""), getKataRemoteConfig(nil))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	toml := string(contents)
+	g.Expect(toml).To(ContainSubstring("remote_hypervisor_timeout = 900\n"))
+	g.Expect(toml).To(ContainSubstring(`enable_annotations = ["default_vcpus","default_memory","machine_type"]` + "\n"))
+	g.Expect(toml).To(ContainSubstring("\ndefault_vcpus = 1\n"))
+	g.Expect(toml).To(ContainSubstring("\ndefault_memory = 2048\n"))
+	g.Expect(toml).To(ContainSubstring("\ndial_timeout = 30\n"))
+	g.Expect(toml).To(ContainSubstring("create_container_timeout = 900\n"))
+	g.Expect(toml).NotTo(ContainSubstring("{{"))
+}
+
+func TestKataRemoteConfigFromKataConfig(t *testing.T) {
+	g := NewWithT(t)
+	remoteHypervisorTimeout := int32(600)
+	agentDialTimeout := int32(90)
+	defaultVCPUs := int32(2)
+
+	config := getKataRemoteConfig(&kataconfigurationv1.KataRemoteConfigSpec{
+		RemoteHypervisorTimeout: &remoteHypervisorTimeout,
+		AgentDialTimeout:        &agentDialTimeout,
+		DefaultVCPUs:            &defaultVCPUs,
+		EnableAnnotations:       []string{"default_vcpus", `machine_"type`},
+	})
+	contents, err := renderKataRemoteConfig(NewPeerPodsManifests(""), config)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	toml := string(contents)
+	g.Expect(toml).To(ContainSubstring("remote_hypervisor_timeout = 600\n"))
+	g.Expect(toml).To(ContainSubstring("\ndial_timeout = 90\n"))
+	g.Expect(toml).To(ContainSubstring("\ndefault_vcpus = 2\n"))
+	g.Expect(toml).To(ContainSubstring(`enable_annotations = ["default_vcpus","machine_\"type"]` + "\n"))
+	// Unset fields keep their defaults
+	g.Expect(toml).To(ContainSubstring("\ndefault_memory = 2048\n"))
+	g.Expect(toml).To(ContainSubstring("create_container_timeout = 900\n"))
+}
+
+func TestKataRemoteConfigMachineConfig(t *testing.T) {
+	g := NewWithT(t)
+	manifests := NewPeerPodsManifests("")
+	defaultMemory := int32(4096)
+	r := newTestReconciler(t, &kataconfigurationv1.KataConfig{
+		Spec: kataconfigurationv1.KataConfigSpec{
+			KataRemoteConfig: &kataconfigurationv1.KataRemoteConfigSpec{
+				DefaultMemoryMiB: &defaultMemory,
+			},
+		},
+	}, nil)
+
+	data, err := fs.ReadFile(manifests, peerpodsKataRemoteMachineConfigYaml)
+	g.Expect(err).NotTo(HaveOccurred())
+	machineConfig, err := parseMachineConfigYAML(data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.addKataRemoteConfigFile(machineConfig)).To(Succeed())
+
+	ic := ignTypes.Config{}
+	g.Expect(json.Unmarshal(machineConfig.Spec.Config.Raw, &ic)).To(Succeed())
+	g.Expect(ic.Ignition.Version).To(Equal("3.2.0"))
+	g.Expect(ic.Storage.Files).To(HaveLen(1))
+
+	file := ic.Storage.Files[0]
+	g.Expect(file.Path).To(Equal(kataRemoteConfigPath))
+	g.Expect(file.Mode).To(HaveValue(Equal(0644)))
+
+	expected, err := renderKataRemoteConfig(manifests, getKataRemoteConfig(r.kataConfig.Spec.KataRemoteConfig))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(readIgnitionFileContents(g, file)).To(Equal(string(expected)))
+	g.Expect(string(expected)).To(ContainSubstring("\ndefault_memory = 4096\n"))
+
+	// Rendering the same settings again gives an identical MachineConfig so
+	// that reconciling doesn't roll out a change
+	again, err := parseMachineConfigYAML(data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.addKataRemoteConfigFile(again)).To(Succeed())
+	g.Expect(again.Spec.Config.Raw).To(Equal(machineConfig.Spec.Config.Raw))
+}
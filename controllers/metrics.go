@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Operations and phases of the kataconfig_phase_* metrics
+const (
+	operationInstall   = "install"
+	operationUninstall = "uninstall"
+
+	phaseWaitingForMco   = "waiting_for_mco"
+	phaseNodeLabelling   = "node_labelling"
+	phasePodVMImageBuild = "podvm_image_build"
+	phasePeerPodsSetup   = "peer_pods_setup"
+)
+
+// The metrics endpoint of the operator is served by kube-rbac-proxy with a
+// certificate issued by the service CA for controller-manager-metrics-svc
+const (
+	operatorMetricsMonitorName = "controller-manager-metrics-monitor"
+	operatorMetricsServiceName = "controller-manager-metrics-svc"
+	operatorMetricsPortName    = "https"
+	// Files in the Prometheus pods of the OpenShift cluster monitoring
+	prometheusTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	prometheusServiceCAFile = "/etc/prometheus/configmaps/serving-certs-ca-bundle/service-ca.crt"
+)
+
+// Results of the pod VM image jobs
+const (
+	jobResultSucceeded = "succeeded"
+	jobResultFailed    = "failed"
+)
+
+// The operator's metrics are served on the controller-runtime metrics
+// endpoint together with the generic controller metrics
+var (
+	phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kataconfig_phase_duration_seconds",
+		Help: "Time spent in the phases of a KataConfig installation or uninstallation.",
+		// From a second for the node labelling to over two hours for the
+		// MCO rolling out to a large pool
+		Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+	}, []string{"operation", "phase"})
+
+	phaseStartTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kataconfig_phase_start_time_seconds",
+		Help: "Start time of the KataConfig phases in progress since the operator started, as a Unix timestamp.",
+	}, []string{"operation", "phase"})
+
+	kataNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kataconfig_nodes",
+		Help: "Number of nodes in each state of the KataConfig status.",
+	}, []string{"state"})
+
+	podVMImageJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kataconfig_podvm_image_job_duration_seconds",
+		Help:    "Duration of the finished pod VM image jobs.",
+		Buckets: []float64{30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 5400, 7200},
+	}, []string{"job", "result"})
+
+	podVMImageJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kataconfig_podvm_image_jobs_total",
+		Help: "Number of finished pod VM image jobs by result.",
+	}, []string{"job", "result"})
+
+	featureGateEnabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kataconfig_feature_gate_enabled",
+		Help: "Whether the feature gate is enabled (1) or not (0).",
+	}, []string{"feature_gate"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		phaseDuration,
+		phaseStartTime,
+		kataNodes,
+		podVMImageJobDuration,
+		podVMImageJobs,
+		featureGateEnabled,
+	)
+}
+
+type phaseKey struct {
+	operation string
+	phase     string
+}
+
+// Marks a phase that spans reconciles as in progress.  The phase starts
+// with the first call, later calls don't change it.
+func (r *KataConfigOpenShiftReconciler) startPhase(operation string, phase string) {
+	key := phaseKey{operation, phase}
+	if _, ok := r.phaseStarts[key]; ok {
+		return
+	}
+	if r.phaseStarts == nil {
+		r.phaseStarts = map[phaseKey]time.Time{}
+	}
+	now := time.Now()
+	r.phaseStarts[key] = now
+	phaseStartTime.WithLabelValues(operation, phase).Set(float64(now.Unix()))
+}
+
+// Records the duration of a phase started with startPhase, does nothing if
+// the phase isn't in progress
+func (r *KataConfigOpenShiftReconciler) endPhase(operation string, phase string) {
+	key := phaseKey{operation, phase}
+	start, ok := r.phaseStarts[key]
+	if !ok {
+		return
+	}
+	delete(r.phaseStarts, key)
+	phaseStartTime.DeleteLabelValues(operation, phase)
+	phaseDuration.WithLabelValues(operation, phase).Observe(time.Since(start).Seconds())
+}
+
+// Records the duration of a phase run within a single reconcile
+func observePhase(operation string, phase string, start time.Time) {
+	phaseDuration.WithLabelValues(operation, phase).Observe(time.Since(start).Seconds())
+}
+
+// Forgets the phases in progress once the KataConfig is gone
+func (r *KataConfigOpenShiftReconciler) resetPhases() {
+	r.phaseStarts = nil
+	phaseStartTime.Reset()
+	kataNodes.Reset()
+}
+
+func recordKataNodesMetrics(status *kataconfigurationv1.KataNodesStatus) {
+	for state, nodes := range map[string][]string{
+		"installed":            status.Installed,
+		"installing":           status.Installing,
+		"waiting":              status.WaitingToInstall,
+		"failed":               status.FailedToInstall,
+		"uninstalling":         status.Uninstalling,
+		"waiting_to_uninstall": status.WaitingToUninstall,
+		"failed_to_uninstall":  status.FailedToUninstall,
+	} {
+		kataNodes.WithLabelValues(state).Set(float64(len(nodes)))
+	}
+}
+
+func recordFeatureGateMetrics(fgStatus *FeatureGateStatus) {
+	for feature := range DefaultFeatureGates {
+		value := 0.0
+		if IsEnabled(fgStatus, feature) {
+			value = 1
+		}
+		featureGateEnabled.WithLabelValues(feature).Set(value)
+	}
+}
+
+// Returns the job label of a pod VM image job.  The jobs deleting orphaned
+// images are named after the image, they share a single label.
+func getPodVMImageJobLabel(jobName string) string {
+	if strings.HasPrefix(jobName, orphanedImageDeleteJobPrefix) {
+		return strings.TrimSuffix(orphanedImageDeleteJobPrefix, "-")
+	}
+	return jobName
+}
+
+// Records the result of a finished pod VM image job once, its status is
+// checked on every reconcile until it's deleted
+func (r *ImageGenerator) recordJobMetrics(job *batchv1.Job, result string) {
+	key := job.Name + "/" + string(job.UID)
+	if r.recordedJobs[key] {
+		return
+	}
+	if r.recordedJobs == nil {
+		r.recordedJobs = map[string]bool{}
+	}
+	r.recordedJobs[key] = true
+
+	start := job.CreationTimestamp.Time
+	if job.Status.StartTime != nil {
+		start = job.Status.StartTime.Time
+	}
+	end := time.Now()
+	if job.Status.CompletionTime != nil {
+		end = job.Status.CompletionTime.Time
+	} else {
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+				end = condition.LastTransitionTime.Time
+			}
+		}
+	}
+
+	label := getPodVMImageJobLabel(job.Name)
+	podVMImageJobDuration.WithLabelValues(label, result).Observe(end.Sub(start).Seconds())
+	podVMImageJobs.WithLabelValues(label, result).Inc()
+}
+
+// Forgets the recorded results of the jobs of the given name once they are
+// gone
+func (r *ImageGenerator) forgetJobMetrics(jobName string) {
+	for key := range r.recordedJobs {
+		if strings.HasPrefix(key, jobName+"/") {
+			delete(r.recordedJobs, key)
+		}
+	}
+}
+
+// Creates or updates the ServiceMonitor getting the operator's metrics
+// scraped through kube-rbac-proxy, or removes it if monitoring is disabled
+func (r *KataConfigOpenShiftReconciler) reconcileOperatorServiceMonitor() error {
+	if !r.isKataMonitorEnabled() {
+		return r.deleteOperatorServiceMonitor()
+	}
+
+	sm := &monitoringv1.ServiceMonitor{ObjectMeta: metav1.ObjectMeta{Name: operatorMetricsMonitorName, Namespace: OperatorNamespace}}
+	err := r.createOrUpdateOwnedObject(sm, func() {
+		sm.Spec.NamespaceSelector = monitoringv1.NamespaceSelector{MatchNames: []string{OperatorNamespace}}
+		sm.Spec.Selector = metav1.LabelSelector{MatchLabels: map[string]string{"control-plane": "controller-manager"}}
+		sm.Spec.Endpoints = []monitoringv1.Endpoint{
+			{
+				Port:   operatorMetricsPortName,
+				Path:   "/metrics",
+				Scheme: "https",
+				// kube-rbac-proxy authorizes the token of Prometheus
+				// with the metrics-reader ClusterRole
+				BearerTokenFile: prometheusTokenFile,
+				TLSConfig: &monitoringv1.TLSConfig{
+					CAFile: prometheusServiceCAFile,
+					SafeTLSConfig: monitoringv1.SafeTLSConfig{
+						ServerName: operatorMetricsServiceName + "." + OperatorNamespace + ".svc",
+					},
+				},
+			},
+		}
+	})
+	if meta.IsNoMatchError(err) {
+		r.Log.Info("monitoring.coreos.com API unavailable, not creating the operator ServiceMonitor")
+		return nil
+	} else if err != nil {
+		r.Log.Info("error creating or updating the operator ServiceMonitor", "err", err)
+		return err
+	}
+	return nil
+}
+
+func (r *KataConfigOpenShiftReconciler) deleteOperatorServiceMonitor() error {
+	sm := &monitoringv1.ServiceMonitor{ObjectMeta: metav1.ObjectMeta{Name: operatorMetricsMonitorName, Namespace: OperatorNamespace}}
+	err := r.Client.Delete(context.TODO(), sm)
+	if err != nil && !k8serrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		r.Log.Info("error deleting the operator ServiceMonitor", "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMetricsPhases(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+	histogram := func() *dto.Histogram {
+		metric := &dto.Metric{}
+		observer := phaseDuration.WithLabelValues(operationInstall, phaseWaitingForMco)
+		g.Expect(observer.(prometheus.Metric).Write(metric)).To(Succeed())
+		return metric.GetHistogram()
+	}
+	before := histogram().GetSampleCount()
+
+	// Ending a phase that hasn't started does nothing
+	r.endPhase(operationInstall, phaseWaitingForMco)
+	g.Expect(testutil.CollectAndCount(phaseStartTime)).To(Equal(0))
+
+	r.startPhase(operationInstall, phaseWaitingForMco)
+	start := testutil.ToFloat64(phaseStartTime.WithLabelValues(operationInstall, phaseWaitingForMco))
+	g.Expect(start).To(BeNumerically("~", time.Now().Unix(), 1))
+
+	// Later reconciles don't restart the phase
+	r.phaseStarts[phaseKey{operationInstall, phaseWaitingForMco}] = time.Now().Add(-time.Hour)
+	r.startPhase(operationInstall, phaseWaitingForMco)
+	g.Expect(r.phaseStarts).To(HaveLen(1))
+
+	r.endPhase(operationInstall, phaseWaitingForMco)
+	g.Expect(r.phaseStarts).To(BeEmpty())
+	g.Expect(testutil.CollectAndCount(phaseStartTime)).To(Equal(0))
+	g.Expect(histogram().GetSampleCount()).To(Equal(before + 1))
+	g.Expect(histogram().GetSampleSum()).To(BeNumerically(">=", time.Hour.Seconds()))
+
+	recordKataNodesMetrics(&kataconfigurationv1.KataNodesStatus{
+		Installed:       []string{"worker-0", "worker-1"},
+		FailedToInstall: []string{"worker-2"},
+	})
+	g.Expect(testutil.ToFloat64(kataNodes.WithLabelValues("installed"))).To(Equal(2.0))
+	g.Expect(testutil.ToFloat64(kataNodes.WithLabelValues("failed"))).To(Equal(1.0))
+	r.resetPhases()
+	g.Expect(testutil.CollectAndCount(kataNodes)).To(Equal(0))
+}
+
+func TestImageJobMetrics(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, nil)
+	succeeded := podVMImageJobs.WithLabelValues(createJobName, jobResultSucceeded)
+	before := testutil.ToFloat64(succeeded)
+
+	it.expectCreate(ImageCreationInProgress, nil)
+	it.finishJob(createJobName, batchv1.JobComplete)
+
+	// The job is checked until the image shows up, it's counted once
+	// across reconciles
+	it.expectCreate(RequeueNeeded, nil)
+	it.expectCreate(RequeueNeeded, nil)
+	it.g.Expect(testutil.ToFloat64(succeeded)).To(Equal(before + 1))
+	it.g.Expect(it.r.recordedImageJobs).To(HaveLen(1))
+
+	// The record is dropped along with the job
+	it.setImageID(testImageID)
+	it.expectCreate(ImageCreatedSuccessfully, nil)
+	it.expectNoJob(createJobName)
+	it.g.Expect(it.r.recordedImageJobs).To(BeEmpty())
+
+	it.g.Expect(getPodVMImageJobLabel(orphanedImageDeleteJobPrefix + "ami-0123")).To(Equal("osc-podvm-image-gc-deletion"))
+}
+
+func TestOperatorServiceMonitor(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, monitoringTestSchemes)
+
+	g.Expect(r.reconcileOperatorServiceMonitor()).To(Succeed())
+	sm := &monitoringv1.ServiceMonitor{ObjectMeta: metav1.ObjectMeta{Name: operatorMetricsMonitorName, Namespace: OperatorNamespace}}
+	expectObjectExists(g, r.Client, sm, true)
+	g.Expect(metav1.IsControlledBy(sm, r.kataConfig)).To(BeTrue())
+	g.Expect(sm.Spec.Endpoints).To(HaveLen(1))
+	endpoint := sm.Spec.Endpoints[0]
+	g.Expect(endpoint.Scheme).To(Equal("https"))
+	g.Expect(endpoint.BearerTokenFile).NotTo(BeEmpty())
+	g.Expect(endpoint.TLSConfig.ServerName).To(Equal("controller-manager-metrics-svc.openshift-sandboxed-containers-operator.svc"))
+
+	enabled := false
+	r.kataConfig.Spec.Monitoring = &kataconfigurationv1.MonitoringSpec{Enabled: &enabled}
+	g.Expect(r.reconcileOperatorServiceMonitor()).To(Succeed())
+	expectObjectExists(g, r.Client, sm, false)
+}
+
+func TestInProgressConditionTransitionTime(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+
+	r.setInProgressConditionToInstalling()
+	cond := r.findInProgressCondition()
+	cond.LastTransitionTime = metav1.NewTime(cond.LastTransitionTime.Add(-time.Hour))
+	transitionTime := cond.LastTransitionTime
+
+	// Reconciles that keep the status don't look like progress
+	r.setInProgressConditionToInstalling()
+	r.setInProgressConditionToPodVMImageCreating()
+	g.Expect(r.findInProgressCondition().LastTransitionTime).To(Equal(transitionTime))
+
+	r.resetInProgressCondition()
+	g.Expect(r.findInProgressCondition().LastTransitionTime).NotTo(Equal(transitionTime))
+}
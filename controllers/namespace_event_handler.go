@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// NamespaceEventHandler triggers a reconcile when a namespace is created or
+// its labels or annotations change so that the peer pods quotas follow
+// KataConfig.spec.peerPodsNamespaceQuota.namespaceSelector and the quota
+// annotation.
+type NamespaceEventHandler struct {
+	reconciler *KataConfigOpenShiftReconciler
+}
+
+func (nh *NamespaceEventHandler) isPeerPodsQuotaEnabled() bool {
+	kataConfig := nh.reconciler.kataConfig
+	return kataConfig != nil && kataConfig.Spec.EnablePeerPods && kataConfig.Spec.PeerPodsNamespaceQuota != nil
+}
+
+func (nh *NamespaceEventHandler) Create(ctx context.Context, event event.CreateEvent, queue workqueue.RateLimitingInterface) {
+
+	if !nh.isPeerPodsQuotaEnabled() {
+		return
+	}
+
+	log := nh.reconciler.Log.WithName("NamespaceCreate").WithValues("namespace", event.Object.GetName())
+	log.Info("Namespace created")
+
+	queue.Add(nh.reconciler.makeReconcileRequest())
+}
+
+func (nh *NamespaceEventHandler) Update(ctx context.Context, event event.UpdateEvent, queue workqueue.RateLimitingInterface) {
+
+	if !nh.isPeerPodsQuotaEnabled() {
+		return
+	}
+
+	ns := event.ObjectNew
+	nsOld := event.ObjectOld
+
+	if reflect.DeepEqual(nsOld.GetLabels(), ns.GetLabels()) &&
+		nsOld.GetAnnotations()[PeerPodsQuotaAnnotation] == ns.GetAnnotations()[PeerPodsQuotaAnnotation] {
+		return
+	}
+
+	log := nh.reconciler.Log.WithName("NamespaceUpdate").WithValues("namespace", ns.GetName())
+	log.Info("Namespace labels or peer pods quota changed")
+
+	queue.Add(nh.reconciler.makeReconcileRequest())
+}
+
+func (nh *NamespaceEventHandler) Delete(ctx context.Context, event event.DeleteEvent, queue workqueue.RateLimitingInterface) {
+}
+
+func (nh *NamespaceEventHandler) Generic(ctx context.Context, event event.GenericEvent, queue workqueue.RateLimitingInterface) {
+}
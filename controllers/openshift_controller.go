@@ -19,8 +19,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"reflect"
 	"time"
 
@@ -36,6 +36,9 @@ import (
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	mcfgconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	"go.opentelemetry.io/otel/trace"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	nodeapi "k8s.io/api/node/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -43,6 +46,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -60,9 +64,45 @@ type KataConfigOpenShiftReconciler struct {
 	Log    logr.Logger
 	Scheme *runtime.Scheme
 
+	// Used to read the logs of failed pod VM image job pods which the
+	// controller-runtime client cannot do
+	PodLogClient corev1client.PodsGetter
+
+	// Dependencies of the pod VM image generator, the defaults are used
+	// for unset fields
+	ImageGeneratorOptions ImageGeneratorOptions
+
+	// Peer-pods manifests, defaults to the ones embedded in the operator
+	// binary
+	Manifests fs.FS
+
+	// Provider of the tracer recording the reconciles, tracing is disabled
+	// if nil
+	TracerProvider trace.TracerProvider
+
 	kataConfig *kataconfigurationv1.KataConfig
 
 	ImgMc *mcfgv1.MachineConfig
+
+	// ImageGenerator of the reconcile in progress, see getImageGenerator
+	imageGenerator *ImageGenerator
+
+	// Finished pod VM image jobs whose result has been recorded in the
+	// metrics, by name and UID.  The jobs outlive a reconcile so this
+	// can't be kept in the ImageGenerator.
+	recordedImageJobs map[string]bool
+
+	// Whether the peer pods mutating webhook is served by the operator
+	// instead of the peer-pods-webhook deployment, see the
+	// inProcessPeerPodsWebhook feature gate
+	inProcessPeerPodsWebhook bool
+
+	// Start times of the phases in progress, see startPhase
+	phaseStarts map[phaseKey]time.Time
+
+	// Context of the span in progress, nil outside of a reconcile.  The
+	// KataConfig reconciles are never run concurrently.
+	traceCtx context.Context
 }
 
 const (
@@ -73,7 +113,6 @@ const (
 	extension_mc_name                   = "50-enable-sandboxed-containers-extension"
 	DEFAULT_PEER_PODS                   = "10"
 	peerpodConfigCrdName                = "peerpodconfig-openshift"
-	peerpodsMachineConfigPathLocation   = "/config/peerpods"
 	peerpodsCrioMachineConfig           = "50-kata-remote"
 	peerpodsCrioMachineConfigYaml       = "mc-50-crio-config.yaml"
 	peerpodsKataRemoteMachineConfig     = "40-worker-kata-remote-config"
@@ -91,25 +130,30 @@ const (
 // +kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions,verbs=get;list;watch
 // +kubebuilder:rbac:groups="";machineconfiguration.openshift.io,resources=nodes;machineconfigs;machineconfigpools;containerruntimeconfigs;pods;services;services/finalizers;endpoints;persistentvolumeclaims;events;configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,verbs=use;get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups="",resources=nodes/status,verbs=patch
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
 // +kubebuilder:rbac:groups=confidentialcontainers.org,resources=peerpodconfigs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=confidentialcontainers.org,resources=peerpodconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=confidentialcontainers.org,resources=peerpodconfigs/finalizers,verbs=update
 // +kubebuilder:rbac:groups=confidentialcontainers.org,resources=peerpods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=confidentialcontainers.org,resources=peerpods/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=confidentialcontainers.org,resources=peerpods/finalizers,verbs=update
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors;prometheusrules,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,verbs=get;list;watch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups=config.openshift.io,resources=infrastructures,verbs=get;list;watch
 // +kubebuilder:rbac:groups="batch",resources=jobs,verbs=create;get;list;watch;delete
 
-func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, err error) {
 	_ = r.Log.WithValues("kataconfig", req.NamespacedName)
 	r.Log.Info("Reconciling KataConfig in OpenShift Cluster")
 
 	// Fetch the KataConfig instance
 	r.kataConfig = &kataconfigurationv1.KataConfig{}
-	err := r.Client.Get(context.TODO(), req.NamespacedName, r.kataConfig)
+	err = r.Client.Get(context.TODO(), req.NamespacedName, r.kataConfig)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after ctrl request.
@@ -122,7 +166,16 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.
 		return ctrl.Result{}, err
 	}
 
+	endReconcileSpan := r.startReconcileSpan(ctx)
+	defer func() { endReconcileSpan(err) }()
+
+	// Each reconcile gets its own ImageGenerator set up for the KataConfig
+	// just read
+	r.imageGenerator = nil
+
+	endSpan := r.startPhaseSpan("processFeatureGates")
 	err = r.processFeatureGates()
+	endSpan(err)
 	if err != nil {
 		r.Log.Info("Unable to process feature gates", "err", err)
 		return ctrl.Result{}, err
@@ -147,7 +200,9 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.
 		// uninstallation commence if another operation (installation, update)
 		// is underway.
 		if r.kataConfig.GetDeletionTimestamp() != nil && !r.isInstalling() && !r.isUpdating() {
+			endSpan := r.startPhaseSpan("processKataConfigDeleteRequest")
 			res, err := r.processKataConfigDeleteRequest()
+			endSpan(err)
 
 			updateErr := r.Client.Status().Update(context.TODO(), r.kataConfig)
 			// The finalizer test is to get rid of the
@@ -163,7 +218,19 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.
 			return res, err
 		}
 
+		// The operator alerts and metrics cover failed and stuck
+		// uninstallations as well, the uninstallation leaves them in
+		// place until it's done
+		if err := r.reconcileOperatorAlertRules(); err != nil {
+			r.Log.Info("Error reconciling the operator alerts", "err", err)
+		}
+		if err := r.reconcileOperatorServiceMonitor(); err != nil {
+			r.Log.Info("Error reconciling the operator ServiceMonitor", "err", err)
+		}
+
+		endSpan := r.startPhaseSpan("processKataConfigInstallRequest")
 		res, err := r.processKataConfigInstallRequest()
+		endSpan(err)
 		if err != nil {
 			return res, err
 		}
@@ -172,27 +239,6 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(ctx context.Context, req ctrl.
 			return ctrl.Result{}, updateErr
 		}
 
-		cMap := r.processDashboardConfigMap()
-		if cMap == nil {
-			r.Log.Info("failed to generate config map for metrics dashboard")
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
-		}
-		foundCm := &corev1.ConfigMap{}
-		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: cMap.Name, Namespace: cMap.Namespace}, foundCm)
-		if err != nil {
-			if k8serrors.IsNotFound(err) {
-				r.Log.Info("Installing metrics dashboard")
-				err = r.Client.Create(context.TODO(), cMap)
-				if err != nil {
-					r.Log.Error(err, "Error when creating the dashboard configmap")
-					res = ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}
-				}
-			} else {
-				r.Log.Error(err, "could not get dashboard info, try again")
-				res = ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}
-			}
-		}
-
 		err = r.processLogLevel(r.kataConfig.Spec.LogLevel)
 		if err != nil {
 			res = ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}
@@ -334,14 +380,14 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForMonitor() *appsv1.Dae
 	}
 
 	r.Log.Info("Creating monitor DaemonSet with image file: \"" + kataMonitorImage + "\"")
-	dsName := "openshift-sandboxed-containers-monitor"
+	dsName := kataMonitorName
 	dsLabels := map[string]string{
 		"name": dsName,
 	}
 
 	nodeSelector := r.getNodeSelectorAsMap()
 
-	return &appsv1.DaemonSet{
+	ds := &appsv1.DaemonSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
 			Kind:       "DaemonSet",
@@ -379,7 +425,7 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForMonitor() *appsv1.Dae
 									Type: "osc_monitor.process",
 								},
 							},
-							Command: []string{"/usr/bin/kata-monitor", "--listen-address=:8090", "--log-level=debug", "--runtime-endpoint=/run/crio/crio.sock"},
+							Command: []string{"/usr/bin/kata-monitor", fmt.Sprintf("--listen-address=:%d", kataMonitorPort), "--log-level=" + r.getKataMonitorLogLevel(), "--runtime-endpoint=/run/crio/crio.sock"},
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "crio-sock",
@@ -413,35 +459,10 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForMonitor() *appsv1.Dae
 			},
 		},
 	}
-}
-
-func (r *KataConfigOpenShiftReconciler) processDashboardConfigMap() *corev1.ConfigMap {
-
-	r.Log.Info("Creating sandboxed containers dashboard in the OpenShift console")
-	cmLabels := map[string]string{
-		"console.openshift.io/dashboard": "true",
-	}
 
-	// retrieve content of the dashboard from our own namespace
-	foundCm := &corev1.ConfigMap{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: dashboard_configmap_name, Namespace: OperatorNamespace}, foundCm)
-	if err != nil {
-		r.Log.Error(err, "could not get dashboard data")
-		return nil
-	}
+	applyWorkloadOverrides(&ds.Spec.Template.Spec, r.getKataMonitorOverrides())
 
-	return &corev1.ConfigMap{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "apps/v1",
-			Kind:       "ConfigMap",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      dashboard_configmap_name,
-			Namespace: dashboard_configmap_namespace,
-			Labels:    cmLabels,
-		},
-		Data: foundCm.Data,
-	}
+	return ds
 }
 
 func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool {
@@ -831,7 +852,11 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		r.Log.Info("Couldn't get node selector for unlabelling nodes", "err", err)
 		return ctrl.Result{Requeue: true}, nil
 	}
+	labellingStart := time.Now()
 	labelingChanged, err := r.unlabelNodes(kataNodeSelector)
+	if labelingChanged {
+		observePhase(operationUninstall, phaseNodeLabelling, labellingStart)
+	}
 
 	if err != nil {
 		if k8serrors.IsConflict(err) {
@@ -895,6 +920,9 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		targetPool = "master"
 	}
 	isMcoUpdating := r.isMcpUpdating(targetPool)
+	if isMcoUpdating || r.kataConfig.Status.WaitingForMcoToStart {
+		r.startPhase(operationUninstall, phaseWaitingForMco)
+	}
 
 	if !isMcoUpdating && r.kataConfig.Status.WaitingForMcoToStart {
 		r.Log.Info("Waiting for MCO to start updating.")
@@ -906,7 +934,9 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		r.kataConfig.Status.WaitingForMcoToStart = false
 	}
 
+	endSpan := r.startPhaseSpan("updateStatus")
 	err = r.updateStatus()
+	endSpan(err)
 	if err != nil {
 		r.Log.Info("Error updating KataConfig.status", "err", err)
 	}
@@ -915,6 +945,7 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		r.Log.Info("Waiting for MachineConfigPool to be fully updated", "machinePool", targetPool)
 		return reconcile.Result{}, nil
 	}
+	r.endPhase(operationUninstall, phaseWaitingForMco)
 
 	r.resetInProgressCondition()
 
@@ -937,15 +968,16 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		}
 	}
 
-	ds := r.processDaemonsetForMonitor()
-	err = r.Client.Delete(context.TODO(), ds)
+	err = r.deleteKataMonitor()
 	if err != nil {
-		if k8serrors.IsNotFound(err) {
-			r.Log.Info("monitor daemonset was already deleted")
-		} else {
-			r.Log.Error(err, "error when deleting monitor Daemonset, try again")
-			return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 15}, err
-		}
+		r.Log.Error(err, "error when deleting kata-monitor, try again")
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 15}, err
+	}
+
+	err = r.deleteDashboards()
+	if err != nil {
+		r.Log.Error(err, "error when deleting the dashboards, try again")
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 15}, err
 	}
 
 	if r.kataConfig.Spec.EnablePeerPods {
@@ -954,52 +986,9 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		// of operator functionality
 		_ = r.disablePeerPods()
 
-		// Handle podvm image deletion
-		// Since we want to declaratively reach the final state, we need to reconcile when there are errors
-		// as we want the system to give a chance of fixing the error.
-		// For cases we don't want to reconcile, ie for ImageDeletedSuccessfully and UnsupportedPodVMImageProvider
-		// we should just log the message and let the code continue without explicitly returning from the method
-
-		// Following are returned statuses:
-		// ImageDeletedSuccessfully
-		// UnsupportedPodVMImageProvider
-		// ImageDeletionFailed
-		// RequeueNeeded
-		// ImageDeletionStatusUnknown
-
-		// Handle podvm image deletion
-		status, err := ImageDelete(r.Client)
-		switch status {
-		case ImageDeletedSuccessfully:
-			r.setInProgressConditionToPodVMImageDeleted()
-			r.Log.Info("PodVM Image deleted successfully")
-
-		case UnsupportedPodVMImageProvider:
-			r.setInProgressConditionToPodVMImageUnsupportedProvider()
-			r.Log.Info("unsupported cloud provider, skipping image deletion")
-
-		case RequeueNeeded:
-			r.setInProgressConditionToPodVMImageDeleting()
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
-
-		case ImageDeletionFailed:
-			r.setInProgressConditionToPodVMImageDeletionFailed()
-			if err != nil {
-				// We requeue only if there is an error.
-				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
-			}
-			// If there's no error, log and continue
-			r.Log.Info("Image deletion failed. Check logs for more details")
-
-		case ImageDeletionStatusUnknown:
-			r.setInProgressConditionToPodVMImageDeletionUnknown()
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
-
-		default:
-			// For all other statuses, just log and continue
-			r.Log.Info("PodVM Image deletion status and error", "status", status, "error", err)
+		if done, res, err := r.deletePodVMImage(); !done {
+			return res, err
 		}
-
 	}
 
 	scc := GetScc()
@@ -1018,14 +1007,91 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	err = r.deleteOperatorAlertRules()
+	if err != nil {
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 15}, err
+	}
+
+	err = r.deleteOperatorServiceMonitor()
+	if err != nil {
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second * 15}, err
+	}
+
 	r.Log.Info("Uninstallation completed. Proceeding with the KataConfig deletion")
 	if err = r.removeFinalizer(); err != nil {
 		return ctrl.Result{Requeue: true}, nil
 	}
+	r.resetPhases()
 
 	return ctrl.Result{}, nil
 }
 
+// Deletes the pod VM image created by the operator.  done is false while
+// the deletion isn't finished, the reconcile should return the result and
+// the error in that case.
+func (r *KataConfigOpenShiftReconciler) deletePodVMImage() (done bool, res ctrl.Result, err error) {
+	// Since we want to declaratively reach the final state, we need to reconcile when there are errors
+	// as we want the system to give a chance of fixing the error.
+	// For cases we don't want to reconcile, ie for ImageDeletedSuccessfully and UnsupportedPodVMImageProvider
+	// we should just log the message and let the code continue without explicitly returning from the method
+
+	// Following are returned statuses:
+	// ImageDeletedSuccessfully
+	// UnsupportedPodVMImageProvider
+	// ImageDeletionSkipped
+	// ImageDeletionFailed
+	// ImageDeletionInProgress
+	// RequeueNeeded
+	// ImageDeletionStatusUnknown
+
+	endSpan := r.startPhaseSpan("imageDelete")
+	status, err := r.imageDelete()
+	endSpan(err)
+	switch status {
+	case ImageDeletedSuccessfully:
+		r.setInProgressConditionToPodVMImageDeleted()
+		r.resetPodVMImageStatus()
+		r.Log.Info("PodVM Image deleted successfully")
+
+	case UnsupportedPodVMImageProvider:
+		r.setInProgressConditionToPodVMImageUnsupportedProvider()
+		r.Log.Info("unsupported cloud provider, skipping image deletion")
+
+	case ImageDeletionSkipped:
+		r.resetPodVMImageStatus()
+		r.Log.Info("PodVM Image has been imported, leaving it in place")
+
+	case ImageDeletionInProgress:
+		// The image deletion job watch triggers a reconcile once
+		// the job has finished
+		r.setInProgressConditionToPodVMImageDeleting()
+		return false, ctrl.Result{}, nil
+
+	case RequeueNeeded:
+		r.setInProgressConditionToPodVMImageDeleting()
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+
+	case ImageDeletionFailed:
+		r.setInProgressConditionToPodVMImageDeletionFailed()
+		if err != nil {
+			// We requeue only if there is an error.
+			return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+		}
+		// If there's no error, log and continue
+		r.Log.Info("Image deletion failed. Check logs for more details")
+
+	case ImageDeletionStatusUnknown:
+		r.setInProgressConditionToPodVMImageDeletionUnknown()
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+
+	default:
+		// For all other statuses, just log and continue
+		r.Log.Info("PodVM Image deletion status and error", "status", status, "error", err)
+	}
+
+	return true, ctrl.Result{}, nil
+}
+
 func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
 	r.Log.Info("Kata installation in progress")
 
@@ -1046,6 +1112,20 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 			r.Log.Info("Enabling peerpods machineconfigs failed", "err", err)
 			return ctrl.Result{}, err
 		}
+
+		// The quotas only count peer pods, they don't need to wait for
+		// the nodes or the podvm image
+		err = r.reconcilePeerPodsQuotas()
+		if err != nil {
+			r.Log.Info("Error in reconciling peer pods quotas", "err", err)
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+		}
+	} else {
+		// peer pods might have been switched off on an installed KataConfig
+		done, res, err := r.processPeerPodsDisableRequest()
+		if !done {
+			return res, err
+		}
 	}
 
 	// If converged cluster, then MCP == master, otherwise "kata-oc" if it exists
@@ -1078,7 +1158,11 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 
 	// Create kata-oc MCP only if it's not a converged cluster
 	if !isConvergedCluster {
+		labellingStart := time.Now()
 		labelingChanged, err := r.updateNodeLabels()
+		if labelingChanged {
+			observePhase(operationInstall, phaseNodeLabelling, labellingStart)
+		}
 		if err != nil {
 			if k8serrors.IsConflict(err) {
 				return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
@@ -1134,6 +1218,9 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 	if isMcoUpdating && r.getInProgressConditionValue() == corev1.ConditionFalse {
 		r.setInProgressConditionToUpdating()
 	}
+	if isMcoUpdating || r.kataConfig.Status.WaitingForMcoToStart {
+		r.startPhase(operationInstall, phaseWaitingForMco)
+	}
 
 	// This condition might look tricky so here's a quick rundown of
 	// what each possible state means:
@@ -1161,12 +1248,15 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 		r.kataConfig.Status.WaitingForMcoToStart = false
 	}
 
+	endSpan := r.startPhaseSpan("updateStatus")
 	err = r.updateStatus()
+	endSpan(err)
 	if err != nil {
 		r.Log.Info("Error updating KataConfig.status", "err", err)
 	}
 
 	if !isMcoUpdating {
+		r.endPhase(operationInstall, phaseWaitingForMco)
 		r.Log.Info("create runtime class")
 		r.resetInProgressCondition()
 		err := r.createRuntimeClass("kata", "0.25", "350Mi")
@@ -1181,35 +1271,16 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 			return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
 		}
 
-		ds := r.processDaemonsetForMonitor()
-		// Set KataConfig instance as the owner and controller
-		if err = controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
-			r.Log.Error(err, "failed to set controller reference on the monitor daemonset")
-			return ctrl.Result{}, err
+		err = r.reconcileKataMonitor()
+		if err != nil {
+			r.Log.Error(err, "error when reconciling kata-monitor")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
 		}
-		r.Log.Info("controller reference set for the monitor daemonset")
 
-		foundDs := &appsv1.DaemonSet{}
-		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+		err = r.reconcileDashboards()
 		if err != nil {
-			if k8serrors.IsNotFound(err) {
-				r.Log.Info("Creating a new installation monitor daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
-				err = r.Client.Create(context.TODO(), ds)
-				if err != nil {
-					r.Log.Error(err, "error when creating monitor daemonset")
-					return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
-				}
-			} else {
-				r.Log.Error(err, "could not get monitor daemonset, try again")
-				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
-			}
-		} else {
-			r.Log.Info("Updating monitor daemonset", "ds.Namespace", ds.Namespace, "ds.Name", ds.Name)
-			err = r.Client.Update(context.TODO(), ds)
-			if err != nil {
-				r.Log.Error(err, "error when updating monitor daemonset")
-				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
-			}
+			r.Log.Error(err, "error when reconciling the dashboards")
+			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
 		}
 
 		// create Pod VM image PeerPodConfig CRD and runtimeclass for peerpods
@@ -1232,29 +1303,68 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 			// ImageCreatedSuccessfully
 			// UnsupportedPodVMImageProvider
 			// ImageCreationFailed
+			// ImageCreationInProgress
 			// RequeueNeeded
 			// ImageCreationStatusUnknown
 
-			status, err := ImageCreate(r.Client)
+			// Failed image creation attempts are retried with exponential
+			// backoff until the retry budget from
+			// KataConfig.spec.podVMImage.retryPolicy is spent.  Raising
+			// maxAttempts re-enables image creation after that.
+			var status int
+			if r.isPodVMImageRetryBudgetSpent() {
+				r.setPodVMImageBuildFailedCondition()
+				r.Log.Info("PodVM image creation retry budget is spent, skipping image creation")
+				status = ImageCreationFailed
+			} else {
+				r.clearPodVMImageBuildFailedCondition()
+				if wait := r.getPodVMImageRetryWait(); wait > 0 {
+					r.Log.Info("Waiting before retrying PodVM image creation", "wait", wait)
+					return ctrl.Result{Requeue: true, RequeueAfter: wait}, nil
+				}
+				endSpan := r.startPhaseSpan("imageCreate")
+				status, err = r.imageCreate()
+				endSpan(err)
+			}
 			switch status {
 			case ImageCreatedSuccessfully:
+				r.endPhase(operationInstall, phasePodVMImageBuild)
 				r.setInProgressConditionToPodVMImageCreated()
+				r.resetPodVMImageCreationFailures()
 				r.Log.Info("PodVM Image created successfully")
 
 			case UnsupportedPodVMImageProvider:
+				r.endPhase(operationInstall, phasePodVMImageBuild)
 				r.setInProgressConditionToPodVMImageUnsupportedProvider()
 				r.Log.Info("unsupported cloud provider, skipping image creation")
 
+			case ImageCreationInProgress:
+				// The image creation job watch triggers a reconcile once
+				// the job has finished
+				r.startPhase(operationInstall, phasePodVMImageBuild)
+				r.setInProgressConditionToPodVMImageCreating()
+				return ctrl.Result{}, nil
+
 			case RequeueNeeded:
+				r.startPhase(operationInstall, phasePodVMImageBuild)
 				r.setInProgressConditionToPodVMImageCreating()
 				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
 
 			case ImageCreationFailed:
+				r.endPhase(operationInstall, phasePodVMImageBuild)
 				r.setInProgressConditionToPodVMImageCreationFailed()
 				if err != nil {
 					// We requeue only if there is an error.
 					return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
 				}
+				if !r.isPodVMImageRetryBudgetSpent() {
+					// The image creation job has failed, retry later
+					// if the retry budget allows it
+					ig := r.imageGenerator
+					if retryAfter := r.recordPodVMImageCreationFailure(ig.LastFailureReason(imageJobCreate), ig.LastFailureLogsConfigMap(imageJobCreate)); retryAfter > 0 {
+						return ctrl.Result{Requeue: true, RequeueAfter: retryAfter}, nil
+					}
+				}
 				// If there's no error, log and continue
 				r.Log.Info("Image creation failed. Check logs for more details")
 
@@ -1269,16 +1379,33 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 				r.Log.Info("PodVM Image creation status and error", "status", status, "error", err)
 			}
 
+			peerPodsSetupStart := time.Now()
+			endSpan := r.startPhaseSpan("enablePeerPodsMiscConfigs")
 			err = r.enablePeerPodsMiscConfigs()
+			endSpan(err)
 			if err != nil {
 				r.Log.Info("Enabling peerpodconfig CR, runtimeclass etc", "err", err)
 				// Give sometime for the error to go away before reconciling again
 				return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
 
 			}
+			observePhase(operationInstall, phasePeerPodsSetup, peerPodsSetupStart)
 
 			// Reset the in progress condition
 			r.resetInProgressCondition()
+
+			// Replicate the podvm image to the additional regions and
+			// delete orphaned images.  Neither blocks the use of peer pods
+			// in the cluster's region.
+			replicationResult, err := r.replicatePodVMImage()
+			if err != nil {
+				return replicationResult, err
+			}
+			gcResult, err := r.collectOrphanedPodVMImages()
+			if err != nil {
+				return gcResult, err
+			}
+			return earliestResult(replicationResult, gcResult), nil
 		}
 
 	} else {
@@ -1635,6 +1762,9 @@ func (eh *NodeEventHandler) Generic(ctx context.Context, event event.GenericEven
 }
 
 func (r *KataConfigOpenShiftReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.isTracingEnabled() {
+		r.Client = newTracingClient(r.Client, r)
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kataconfigurationv1.KataConfig{}).
 		Watches(
@@ -1645,7 +1775,16 @@ func (r *KataConfigOpenShiftReconciler) SetupWithManager(mgr ctrl.Manager) error
 			&NodeEventHandler{r}).
 		Watches(
 			&corev1.ConfigMap{},
-			&ConfigMapEventHandler{r},
+			&ConfigMapEventHandler{r}).
+		Watches(
+			&batchv1.Job{},
+			&JobEventHandler{r}).
+		Watches(
+			&corev1.Namespace{},
+			&NamespaceEventHandler{r}).
+		Watches(
+			&admissionregistrationv1.ValidatingWebhookConfiguration{},
+			&WebhookEventHandler{r},
 		).Complete(r)
 }
 
@@ -1810,6 +1949,7 @@ func (r *KataConfigOpenShiftReconciler) updateStatus() error {
 	}
 
 	r.kataConfig.Status.KataNodes.ReadyNodeCount = len(r.kataConfig.Status.KataNodes.Installed)
+	recordKataNodesMetrics(&r.kataConfig.Status.KataNodes)
 
 	return err
 }
@@ -1998,21 +2138,25 @@ func (r *KataConfigOpenShiftReconciler) addInProgressCondition() *kataconfigurat
 }
 
 // This is just a technical helper to all InProgress Condition mutators,
-// factoring their common preamble out into an own function.
-func (r *KataConfigOpenShiftReconciler) retrieveInProgressConditionForChange() *kataconfigurationv1.KataConfigCondition {
+// factoring their common preamble out into an own function.  The
+// transition time only moves when the status changes so that reconciles
+// which don't change anything don't look like progress.
+func (r *KataConfigOpenShiftReconciler) retrieveInProgressConditionForChange(status corev1.ConditionStatus) *kataconfigurationv1.KataConfigCondition {
 	cond := r.findInProgressCondition()
 	if cond == nil {
 		cond = r.addInProgressCondition()
 	}
 
-	cond.LastTransitionTime = metav1.Now()
+	if cond.Status != status || cond.LastTransitionTime.IsZero() {
+		cond.LastTransitionTime = metav1.Now()
+	}
+	cond.Status = status
 
 	return cond
 }
 
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToInstalling() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = "Installing"
 	cond.Message = "Performing initial installation of kata on cluster"
 
@@ -2020,8 +2164,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToInstalling() {
 }
 
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToUninstalling() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = "Uninstalling"
 	cond.Message = "Removing kata from cluster"
 
@@ -2029,8 +2172,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToUninstalling() {
 }
 
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToUpdating() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = "Updating"
 	cond.Message = "Adding and/or removing kata-enabled nodes"
 
@@ -2043,8 +2185,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToFailed(failingNo
 		r.Log.Info("Missing machineconfiguration.openshift.io/reason on Degraded node", "node", failingNode.GetName())
 	}
 
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = "Failed"
 	cond.Message = "Node " + failingNode.GetName() + " Degraded: " + reasonForDegraded
 
@@ -2052,17 +2193,31 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToFailed(failingNo
 }
 
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToBlockedByExistingKataPods(message string) {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionFalse
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionFalse)
 	cond.Reason = "BlockedByExistingKataPods"
 	cond.Message = message
 
 	r.Log.Info("InProgress Condition set to BlockedByExistingKataPods")
 }
 
+func (r *KataConfigOpenShiftReconciler) setInProgressConditionToBlockedByExistingPeerPods(message string) {
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionFalse)
+	cond.Reason = "BlockedByExistingPeerPods"
+	cond.Message = message
+
+	r.Log.Info("InProgress Condition set to BlockedByExistingPeerPods")
+}
+
+func (r *KataConfigOpenShiftReconciler) setInProgressConditionToDisablingPeerPods() {
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
+	cond.Reason = "DisablingPeerPods"
+	cond.Message = "Removing peer pods from cluster"
+
+	r.Log.Info("InProgress Condition set to DisablingPeerPods")
+}
+
 func (r *KataConfigOpenShiftReconciler) resetInProgressCondition() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionFalse
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionFalse)
 	cond.Reason = ""
 	cond.Message = ""
 
@@ -2071,8 +2226,7 @@ func (r *KataConfigOpenShiftReconciler) resetInProgressCondition() {
 
 // Method to set the InProgress condition to indicate that the Pod VM Image is being created
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreating() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = PodVMImageJobRunning
 	cond.Message = "Creating Pod VM Image"
 
@@ -2081,8 +2235,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreati
 
 // Method to set the InProgress condition to indicate that the Pod VM Image has been created
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreated() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = PodVMImageJobCompleted
 	cond.Message = "Created Pod VM Image"
 
@@ -2091,8 +2244,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreate
 
 // Method to set the InProgress condition to indicate that the Pod VM Image creation has failed
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreationFailed() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = PodVMImageJobFailed
 	cond.Message = "Failed to create Pod VM Image"
 
@@ -2101,8 +2253,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreati
 
 // Method to set the InProgress condition to indicate that the Pod VM Image creation status is unknown
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreationUnknown() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionUnknown
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionUnknown)
 	cond.Reason = PodVMImageJobStatusUnknown
 	cond.Message = "Pod VM Image creation status is unknown"
 
@@ -2111,8 +2262,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageCreati
 
 // Method to set the InProgress condition to indicate that the Pod VM Image is being deleted
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDeleting() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = PodVMImageJobRunning
 	cond.Message = "Deleting Pod VM Image"
 
@@ -2121,8 +2271,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDeleti
 
 // Method to set the InProgress condition to indicate that the Pod VM Image has been deleted
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDeleted() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = PodVMImageJobCompleted
 	cond.Message = "Deleted Pod VM Image"
 
@@ -2131,8 +2280,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDelete
 
 // Method to set the InProgress condition to indicate that the Pod VM Image deletion has failed
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDeletionFailed() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = PodVMImageJobFailed
 	cond.Message = "Failed to delete Pod VM Image"
 
@@ -2141,8 +2289,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDeleti
 
 // Method to set the InProgress condition to indicate that the Pod VM Image deletion status is unknown
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDeletionUnknown() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionUnknown
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionUnknown)
 	cond.Reason = PodVMImageJobStatusUnknown
 	cond.Message = "Pod VM Image deletion status is unknown"
 
@@ -2151,8 +2298,7 @@ func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageDeleti
 
 // Method to set the InProgress condition to indicate that the Pod VM image provider is unsupported
 func (r *KataConfigOpenShiftReconciler) setInProgressConditionToPodVMImageUnsupportedProvider() {
-	cond := r.retrieveInProgressConditionForChange()
-	cond.Status = corev1.ConditionTrue
+	cond := r.retrieveInProgressConditionForChange(corev1.ConditionTrue)
 	cond.Reason = PodVMImageUnsupportedProvider
 	cond.Message = "Pod VM image provider is unsupported"
 
@@ -2218,16 +2364,16 @@ func (r *KataConfigOpenShiftReconciler) createAuthJsonSecret() error {
 func (r *KataConfigOpenShiftReconciler) enablePeerPodsMc() error {
 
 	//Create MachineConfig for kata-remote hyp CRIO config
-	crioMachineConfigFilePath := filepath.Join(peerpodsMachineConfigPathLocation, peerpodsCrioMachineConfigYaml)
-	err := r.createMcFromFile(crioMachineConfigFilePath)
+	err := r.createMcFromFile(peerpodsCrioMachineConfigYaml, nil)
 	if err != nil {
 		r.Log.Info("Error in creating CRIO MachineConfig", "err", err)
 		return err
 	}
 
-	//Create MachineConfig for kata-remote hyp config toml
-	kataConfigMachineConfigFilePath := filepath.Join(peerpodsMachineConfigPathLocation, peerpodsKataRemoteMachineConfigYaml)
-	err = r.createMcFromFile(kataConfigMachineConfigFilePath)
+	//Create MachineConfig for kata-remote hyp config toml, which is rendered
+	//from KataConfig.spec.kataRemoteConfig.  Changes to it are rolled out
+	//by updating the existing MachineConfig.
+	err = r.createMcFromFile(peerpodsKataRemoteMachineConfigYaml, r.addKataRemoteConfigFile)
 	if err != nil {
 		r.Log.Info("Error in creating kata remote configuration.toml MachineConfig", "err", err)
 		return err
@@ -2244,41 +2390,64 @@ func (r *KataConfigOpenShiftReconciler) enablePeerPodsMiscConfigs() error {
 			Name:      peerpodConfigCrdName,
 			Namespace: OperatorNamespace,
 		},
-		Spec: v1alpha1.PeerPodConfigSpec{
-			CloudSecretName: "peer-pods-secret",
-			ConfigMapName:   "peer-pods-cm",
-			Limit:           DEFAULT_PEER_PODS,
-			NodeSelector:    r.getNodeSelectorAsMap(),
-		},
 	}
 
-	err := r.Client.Create(context.TODO(), &peerPodConfig)
-	if err != nil && !k8serrors.IsAlreadyExists(err) {
+	// The limit and the node selector can change on an installed
+	// KataConfig, keep the existing PeerPodConfig in sync with them
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), r.Client, &peerPodConfig, func() error {
+		peerPodConfig.Spec.CloudSecretName = "peer-pods-secret"
+		peerPodConfig.Spec.ConfigMapName = "peer-pods-cm"
+		peerPodConfig.Spec.Limit = r.getPeerPodsLimit()
+		peerPodConfig.Spec.NodeSelector = r.getNodeSelectorAsMap()
+		return nil
+	})
+	if err != nil {
 		r.Log.Info("Error in creating peerpodconfig", "err", err)
 		return err
 	}
 
-	// Create the mutating webhook deployment
-	err = r.createMutatingWebhookDeployment()
-	if err != nil {
-		r.Log.Info("Error in creating mutating webhook deployment for peerpods", "err", err)
-		return err
-	}
+	if !r.inProcessPeerPodsWebhook {
+		// Create the mutating webhook deployment
+		err = r.createMutatingWebhookDeployment()
+		if err != nil {
+			r.Log.Info("Error in creating mutating webhook deployment for peerpods", "err", err)
+			return err
+		}
 
-	// Create the mutating webhook service
-	err = r.createMutatingWebhookService()
-	if err != nil {
-		r.Log.Info("Error in creating mutating webhook service for peerpods", "err", err)
-		return err
+		// Create the mutating webhook service
+		err = r.createMutatingWebhookService()
+		if err != nil {
+			r.Log.Info("Error in creating mutating webhook service for peerpods", "err", err)
+			return err
+		}
 	}
 
-	// Create the mutating webhook
+	// Create the mutating webhook.  It's switched over to the new webhook
+	// server before the old one is removed so that pod creations don't
+	// fail in between.
 	err = r.createMutatingWebhookConfig()
 	if err != nil {
 		r.Log.Info("Error in creating mutating webhook for peerpods", "err", err)
 		return err
 	}
 
+	if r.inProcessPeerPodsWebhook {
+		// The operator serves the webhook itself, remove the
+		// deployment and the service a previous reconcile might have
+		// created
+		err = r.deleteMutatingWebhookDeployment()
+		if err != nil {
+			r.Log.Info("Error in deleting mutating webhook deployment for peerpods", "err", err)
+			return err
+		}
+
+		err = r.deleteMutatingWebhookService()
+		if err != nil {
+			r.Log.Info("Error in deleting mutating webhook service for peerpods", "err", err)
+			return err
+		}
+	}
+
 	// Create runtimeClass config for peer-pods
 	err = r.createRuntimeClass(peerpodsRuntimeClassName, peerpodsRuntimeClassCpuOverhead, peerpodsRuntimeClassMemOverhead)
 	if err != nil {
@@ -2336,10 +2505,11 @@ func (r *KataConfigOpenShiftReconciler) disablePeerPods() error {
 			"mc", mc.Name, "err", err)
 	}
 
-	// Delete mutating webhook deployment
-	err = r.deleteMutatingWebhookDeployment()
+	// Delete the mutating webhook first, with the Fail failure policy pod
+	// creations would be rejected once its server is gone
+	err = r.deleteMutatingWebhookConfig()
 	if err != nil {
-		r.Log.Info("Error in deleting mutating webhook deployment for peerpods", "err", err)
+		r.Log.Info("Error in deleting mutating webhook for peerpods", "err", err)
 		return err
 	}
 
@@ -2350,10 +2520,10 @@ func (r *KataConfigOpenShiftReconciler) disablePeerPods() error {
 		return err
 	}
 
-	// Delete the mutating webhook
-	err = r.deleteMutatingWebhookConfig()
+	// Delete mutating webhook deployment
+	err = r.deleteMutatingWebhookDeployment()
 	if err != nil {
-		r.Log.Info("Error in deleting mutating webhook for peerpods", "err", err)
+		r.Log.Info("Error in deleting mutating webhook deployment for peerpods", "err", err)
 		return err
 	}
 
@@ -2361,9 +2531,11 @@ func (r *KataConfigOpenShiftReconciler) disablePeerPods() error {
 }
 
 // Create the MachineConfigs from file
-// Full path of the file should be provided
-func (r *KataConfigOpenShiftReconciler) createMcFromFile(machineConfigYamlFile string) error {
-	yamlData, err := readYamlFile(machineConfigYamlFile)
+// The name of the file in the peer-pods manifests should be provided
+// If set, mutate is called to complete the MachineConfig before it's created
+// or updated
+func (r *KataConfigOpenShiftReconciler) createMcFromFile(machineConfigYamlFile string, mutate func(*mcfgv1.MachineConfig) error) error {
+	yamlData, err := fs.ReadFile(peerPodsManifestsOrDefault(r.Manifests), machineConfigYamlFile)
 	if err != nil {
 		r.Log.Info("Error in reading MachineConfigYaml", "mcFile", machineConfigYamlFile, "err", err)
 		return err
@@ -2377,6 +2549,13 @@ func (r *KataConfigOpenShiftReconciler) createMcFromFile(machineConfigYamlFile s
 		return err
 	}
 
+	if mutate != nil {
+		if err := mutate(machineConfig); err != nil {
+			r.Log.Info("Error in completing MachineConfig", "mcFile", machineConfigYamlFile, "err", err)
+			return err
+		}
+	}
+
 	// Default MCP is kata-oc, however for converged cluster it should be "master"
 	isConvergedCluster, err := r.checkConvergedCluster()
 	if isConvergedCluster && err == nil {
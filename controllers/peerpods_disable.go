@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/peerpodconfig-ctrl/api/v1alpha1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Returns true if the object exists in the cluster
+func (r *KataConfigOpenShiftReconciler) objectExists(key types.NamespacedName, obj client.Object) (bool, error) {
+	err := r.Client.Get(context.TODO(), key, obj)
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns true if any of the peer-pods MachineConfigs exists
+func (r *KataConfigOpenShiftReconciler) peerPodsMachineConfigsExist() (bool, error) {
+	for _, name := range []string{peerpodsCrioMachineConfig, peerpodsKataRemoteMachineConfig} {
+		exists, err := r.objectExists(types.NamespacedName{Name: name}, &mcfgv1.MachineConfig{})
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+// Returns true if anything set up for peer pods is still in place.  The
+// kata-remote entry in KataConfig.status.runtimeClasses is removed last so
+// that an interrupted teardown is picked up again.
+func (r *KataConfigOpenShiftReconciler) isPeerPodsInstalled() (bool, error) {
+	if contains(r.kataConfig.Status.RuntimeClasses, peerpodsRuntimeClassName) {
+		return true, nil
+	}
+
+	exists, err := r.objectExists(types.NamespacedName{Name: peerpodConfigCrdName, Namespace: OperatorNamespace}, &v1alpha1.PeerPodConfig{})
+	if err != nil || exists {
+		return exists, err
+	}
+
+	return r.peerPodsMachineConfigsExist()
+}
+
+// Returns an error if there are pods using the kata-remote RuntimeClass
+func (r *KataConfigOpenShiftReconciler) listPeerPods() error {
+	podList := &corev1.PodList{}
+	if err := r.Client.List(context.TODO(), podList, client.InNamespace(corev1.NamespaceAll)); err != nil {
+		return fmt.Errorf("failed to list peer pods: %v", err)
+	}
+	for _, pod := range podList.Items {
+		if pod.Spec.RuntimeClassName != nil && *pod.Spec.RuntimeClassName == peerpodsRuntimeClassName {
+			return fmt.Errorf("existing pods using \"%v\" RuntimeClass found. Please delete the pods manually for peer pods to be disabled", peerpodsRuntimeClassName)
+		}
+	}
+	return nil
+}
+
+// Deletes the RuntimeClass and removes it from
+// KataConfig.status.runtimeClasses
+func (r *KataConfigOpenShiftReconciler) deleteRuntimeClass(runtimeClassName string) error {
+	rc := &nodeapi.RuntimeClass{}
+	rc.Name = runtimeClassName
+	err := r.Client.Delete(context.TODO(), rc)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	runtimeClasses := []string{}
+	for _, name := range r.kataConfig.Status.RuntimeClasses {
+		if name != runtimeClassName {
+			runtimeClasses = append(runtimeClasses, name)
+		}
+	}
+	r.kataConfig.Status.RuntimeClasses = runtimeClasses
+	return nil
+}
+
+// Tears peer pods down after EnablePeerPods has been switched off on an
+// installed KataConfig.  Nothing is removed while pods still use the
+// kata-remote RuntimeClass.  The pod VM image is deleted first if
+// KataConfig.spec.podVMImage.deleteOnDisable is set since its deletion job
+// needs the peer-pods credentials, then the PeerPodConfig, the peer-pods
+// MachineConfigs, the mutating webhook, the peer pods quotas and the
+// kata-remote RuntimeClass are deleted.  done is false while the teardown isn't finished, the
+// reconcile should return the result and the error in that case.
+func (r *KataConfigOpenShiftReconciler) processPeerPodsDisableRequest() (done bool, res ctrl.Result, err error) {
+	installed, err := r.isPeerPodsInstalled()
+	if err != nil {
+		r.Log.Info("Error in checking whether peer pods are installed", "err", err)
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+	if !installed {
+		return true, ctrl.Result{}, nil
+	}
+
+	r.Log.Info("Peer pods disablement in progress")
+
+	if err := r.listPeerPods(); err != nil {
+		// Leave the status update to the reconcile so that the user
+		// can see why peer pods haven't been disabled yet
+		r.setInProgressConditionToBlockedByExistingPeerPods(err.Error())
+		r.Log.Info("Peer pods are present. Requeue for reconciliation", "err", err)
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+	}
+
+	r.setInProgressConditionToDisablingPeerPods()
+
+	if r.kataConfig.Spec.PodVMImage != nil && r.kataConfig.Spec.PodVMImage.DeleteOnDisable {
+		if done, res, err := r.deletePodVMImage(); !done {
+			return false, res, err
+		}
+	}
+
+	mcsExist, err := r.peerPodsMachineConfigsExist()
+	if err != nil {
+		r.Log.Info("Error in getting peerpods machineconfigs", "err", err)
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	if err := r.disablePeerPods(); err != nil {
+		r.Log.Info("Disabling peerpods failed", "err", err)
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	// Drops all peer pods quotas since EnablePeerPods is false
+	if err := r.reconcilePeerPodsQuotas(); err != nil {
+		r.Log.Info("Error in deleting peer pods quotas", "err", err)
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	if err := r.deleteRuntimeClass(peerpodsRuntimeClassName); err != nil {
+		r.Log.Info("Error in deleting kata remote runtimeclass", "err", err)
+		return false, ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	// Removing the MachineConfigs makes the MCO update the nodes, the rest
+	// of the reconcile waits for that
+	if mcsExist {
+		r.kataConfig.Status.WaitingForMcoToStart = true
+	}
+
+	r.Log.Info("Peer pods disabled")
+	return true, ctrl.Result{}, nil
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confidential-containers/cloud-api-adaptor/src/peerpodconfig-ctrl/api/v1alpha1"
+	. "github.com/onsi/gomega"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// Returns a KataConfig with peer pods switched off
+func newPeerPodsDisabledKataConfig(runtimeClasses []string) *kataconfigurationv1.KataConfig {
+	return &kataconfigurationv1.KataConfig{
+		Spec:   kataconfigurationv1.KataConfigSpec{EnablePeerPods: false},
+		Status: kataconfigurationv1.KataConfigStatus{RuntimeClasses: runtimeClasses},
+	}
+}
+
+// Returns the objects peer pods installs
+func peerPodsObjects() []client.Object {
+	return []client.Object{
+		&mcfgv1.MachineConfig{ObjectMeta: metav1.ObjectMeta{Name: peerpodsCrioMachineConfig}},
+		&mcfgv1.MachineConfig{ObjectMeta: metav1.ObjectMeta{Name: peerpodsKataRemoteMachineConfig}},
+		&v1alpha1.PeerPodConfig{ObjectMeta: metav1.ObjectMeta{Name: peerpodConfigCrdName, Namespace: OperatorNamespace}},
+		&nodeapi.RuntimeClass{ObjectMeta: metav1.ObjectMeta{Name: peerpodsRuntimeClassName}, Handler: peerpodsRuntimeClassName},
+	}
+}
+
+func TestPeerPodsDisableNotInstalled(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, newPeerPodsDisabledKataConfig([]string{"kata"}), peerPodsTestSchemes)
+
+	done, res, err := r.processPeerPodsDisableRequest()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+	g.Expect(res.IsZero()).To(BeTrue())
+	g.Expect(r.findInProgressCondition()).To(BeNil())
+	g.Expect(r.kataConfig.Status.WaitingForMcoToStart).To(BeFalse())
+}
+
+func TestPeerPodsDisableBlockedByPeerPods(t *testing.T) {
+	g := NewWithT(t)
+	runtimeClassName := peerpodsRuntimeClassName
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer-pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{RuntimeClassName: &runtimeClassName},
+	}
+	objs := append(peerPodsObjects(), pod)
+	r := newTestReconciler(t, newPeerPodsDisabledKataConfig([]string{"kata", peerpodsRuntimeClassName}), peerPodsTestSchemes, objs...)
+
+	done, res, err := r.processPeerPodsDisableRequest()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(done).To(BeFalse())
+	g.Expect(res.RequeueAfter).NotTo(BeZero())
+
+	cond := r.findInProgressCondition()
+	g.Expect(cond).NotTo(BeNil())
+	g.Expect(cond.Reason).To(Equal("BlockedByExistingPeerPods"))
+	g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+
+	for _, obj := range peerPodsObjects() {
+		expectObjectExists(g, r.Client, obj, true)
+	}
+	g.Expect(r.kataConfig.Status.RuntimeClasses).To(ContainElement(peerpodsRuntimeClassName))
+}
+
+func TestPeerPodsDisableTearsDownPeerPods(t *testing.T) {
+	g := NewWithT(t)
+	kataRuntimeClassName := "kata"
+	// Pods using other runtime classes don't block disabling peer pods
+	kataPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kata-pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{RuntimeClassName: &kataRuntimeClassName},
+	}
+	objs := append(peerPodsObjects(), kataPod)
+	r := newTestReconciler(t, newPeerPodsDisabledKataConfig([]string{"kata", peerpodsRuntimeClassName}), peerPodsTestSchemes, objs...)
+
+	done, _, err := r.processPeerPodsDisableRequest()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+
+	for _, obj := range peerPodsObjects() {
+		expectObjectExists(g, r.Client, obj, false)
+	}
+	expectObjectExists(g, r.Client, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "kata-pod", Namespace: "default"}}, true)
+	g.Expect(r.kataConfig.Status.RuntimeClasses).To(Equal([]string{"kata"}))
+	g.Expect(r.kataConfig.Status.WaitingForMcoToStart).To(BeTrue())
+	g.Expect(r.findInProgressCondition().Reason).To(Equal("DisablingPeerPods"))
+
+	// Nothing is left to tear down in the next reconcile
+	installed, err := r.isPeerPodsInstalled()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(installed).To(BeFalse())
+}
+
+func TestPeerPodsDisableResumesWithoutRuntimeClass(t *testing.T) {
+	g := NewWithT(t)
+	// Peer pods were switched off before the RuntimeClass was created
+	r := newTestReconciler(t, newPeerPodsDisabledKataConfig([]string{"kata"}), peerPodsTestSchemes,
+		&mcfgv1.MachineConfig{ObjectMeta: metav1.ObjectMeta{Name: peerpodsKataRemoteMachineConfig}})
+
+	done, _, err := r.processPeerPodsDisableRequest()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(done).To(BeTrue())
+
+	mc := &mcfgv1.MachineConfig{}
+	exists, err := r.objectExists(types.NamespacedName{Name: peerpodsKataRemoteMachineConfig}, mc)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(exists).To(BeFalse())
+	g.Expect(r.kataConfig.Status.WaitingForMcoToStart).To(BeTrue())
+}
+
+func TestPeerPodsDisableRemovesWebhookConfigFirst(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, newPeerPodsDisabledKataConfig(nil), peerPodsTestSchemes,
+		&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: webhookConfigName}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: webhookSvcName, Namespace: OperatorNamespace}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: webhookDeploymentName, Namespace: OperatorNamespace}})
+	var deleted []string
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			err := c.Delete(ctx, obj, opts...)
+			if err == nil {
+				deleted = append(deleted, obj.GetName())
+			}
+			return err
+		},
+	})
+
+	// With the Fail failure policy pod creations are rejected while the
+	// webhook config points to a server that is gone
+	g.Expect(r.disablePeerPods()).To(Succeed())
+	g.Expect(deleted).To(Equal([]string{webhookConfigName, webhookSvcName, webhookDeploymentName}))
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yaml "github.com/ghodss/yaml"
+	"github.com/openshift/sandboxed-containers-operator/config/peerpods"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Environment variable pointing at an optional directory with patches
+	// for the embedded peer-pods manifests
+	PeerPodsManifestsOverrideDirEnv = "PEERPODS_MANIFESTS_OVERRIDE_DIR"
+
+	peerpodsPodVMManifestsDir              = "podvm"
+	peerpodsCredentialsRequestsManifestDir = "credentials-requests"
+)
+
+// The kind of object each peer-pods manifest must parse into.  Every
+// manifest listed here has to be present in the operator binary.
+var peerPodsManifestKinds = map[string]func([]byte) (client.Object, error){
+	peerpodsCrioMachineConfigYaml:       parseMachineConfigManifest,
+	peerpodsKataRemoteMachineConfigYaml: parseMachineConfigManifest,
+
+	peerpodsCredentialsRequestsManifestDir + "/credentials_request_aws.yaml":   parseCredentialsRequestManifest,
+	peerpodsCredentialsRequestsManifestDir + "/credentials_request_azure.yaml": parseCredentialsRequestManifest,
+
+	peerpodsPodVMManifestsDir + "/osc-podvm-create-job.yaml":         parseJobManifest,
+	peerpodsPodVMManifestsDir + "/osc-podvm-delete-job.yaml":         parseJobManifest,
+	peerpodsPodVMManifestsDir + "/osc-podvm-gallery-delete-job.yaml": parseJobManifest,
+	peerpodsPodVMManifestsDir + "/osc-podvm-import-job.yaml":         parseJobManifest,
+	peerpodsPodVMManifestsDir + "/osc-podvm-list-job.yaml":           parseJobManifest,
+	peerpodsPodVMManifestsDir + "/osc-podvm-replicate-job.yaml":      parseJobManifest,
+
+	peerpodsPodVMManifestsDir + "/aws-podvm-image-cm.yaml":     parseConfigMapManifest,
+	peerpodsPodVMManifestsDir + "/azure-podvm-image-cm.yaml":   parseConfigMapManifest,
+	peerpodsPodVMManifestsDir + "/libvirt-podvm-image-cm.yaml": parseConfigMapManifest,
+}
+
+// peerPodsManifests serves the manifests embedded in the operator binary
+// with the patches from the override directory applied.  A patch is a YAML
+// merge patch (RFC 7386) stored under the same relative path as the
+// manifest it applies to, eg. <overrideDir>/podvm/osc-podvm-create-job.yaml.
+// Patches are only applied through ReadFile, which is what fs.ReadFile uses.
+type peerPodsManifests struct {
+	fs.FS
+	overrideDir string
+}
+
+// NewPeerPodsManifests returns the embedded peer-pods manifests with the
+// patches from overrideDir applied.  An empty overrideDir disables patching.
+func NewPeerPodsManifests(overrideDir string) fs.FS {
+	return &peerPodsManifests{FS: peerpods.Manifests, overrideDir: overrideDir}
+}
+
+func (m *peerPodsManifests) ReadFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(m.FS, name)
+	if err != nil || m.overrideDir == "" {
+		return data, err
+	}
+
+	patch, err := os.ReadFile(filepath.Join(m.overrideDir, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return data, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	data, err = mergePatchYAML(data, patch)
+	if err != nil {
+		return nil, fmt.Errorf("applying the override for %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Returns the pod VM image manifests of the peer-pods manifests
+func podVMManifests(manifests fs.FS) fs.FS {
+	sub, err := fs.Sub(manifests, peerpodsPodVMManifestsDir)
+	if err != nil {
+		// Only happens for an invalid directory name
+		panic(err)
+	}
+	return sub
+}
+
+// Returns the given manifests, or the embedded ones if unset
+func peerPodsManifestsOrDefault(manifests fs.FS) fs.FS {
+	if manifests == nil {
+		return NewPeerPodsManifests("")
+	}
+	return manifests
+}
+
+// ValidatePeerPodsManifests checks that every peer-pods manifest the
+// operator needs is present and parses into the expected kind of object
+// without unknown fields, with the overrides applied, and that the kata-remote
+// configuration template renders.  It also rejects files
+// in the override directory which don't match any manifest so that typos in
+// their names don't go unnoticed.
+func ValidatePeerPodsManifests(manifests fs.FS, overrideDir string) error {
+	names := make([]string, 0, len(peerPodsManifestKinds))
+	for name := range peerPodsManifestKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		parse := peerPodsManifestKinds[name]
+		data, err := fs.ReadFile(manifests, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading manifest %s: %w", name, err))
+			continue
+		}
+		if err := validateManifest(data, parse); err != nil {
+			errs = append(errs, fmt.Errorf("invalid manifest %s: %w", name, err))
+		}
+	}
+
+	if _, err := renderKataRemoteConfig(manifests, getKataRemoteConfig(nil)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid template %s: %w", kataRemoteConfigTemplate, err))
+	}
+
+	if overrideDir != "" {
+		err := filepath.WalkDir(overrideDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Skip the ..data symlink and the timestamped directories
+			// of a mounted ConfigMap
+			if strings.HasPrefix(d.Name(), "..") {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			name, err := filepath.Rel(overrideDir, path)
+			if err != nil {
+				return err
+			}
+			if _, ok := peerPodsManifestKinds[filepath.ToSlash(name)]; !ok {
+				errs = append(errs, fmt.Errorf("override %s doesn't match any peer-pods manifest", path))
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reading override directory %s: %w", overrideDir, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Parses the manifest rejecting unknown fields and checks that the object
+// has a name
+func validateManifest(data []byte, parse func([]byte) (client.Object, error)) error {
+	obj, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj.DeepCopyObject()); err != nil {
+		return err
+	}
+
+	if obj.GetName() == "" {
+		return errors.New("metadata.name is not set")
+	}
+	return nil
+}
+
+func parseMachineConfigManifest(data []byte) (client.Object, error) {
+	return parseMachineConfigYAML(data)
+}
+
+func parseCredentialsRequestManifest(data []byte) (client.Object, error) {
+	return parseCredentialsRequestYAML(data)
+}
+
+func parseJobManifest(data []byte) (client.Object, error) {
+	return parseJobYAML(data)
+}
+
+func parseConfigMapManifest(data []byte) (client.Object, error) {
+	return parseConfigMapYAML(data)
+}
+
+// Applies a YAML merge patch (RFC 7386) to a YAML document
+func mergePatchYAML(data []byte, patch []byte) ([]byte, error) {
+	var doc, patchDoc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(mergePatch(doc, patchDoc))
+}
+
+// Merges patch into target following RFC 7386: objects are merged
+// recursively, null values remove fields and anything else, including
+// lists, replaces the target value
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = map[string]interface{}{}
+	}
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+		} else {
+			targetMap[key] = mergePatch(targetMap[key], value)
+		}
+	}
+	return targetMap
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// Writes a patch for the named manifest into the override directory
+func writeManifestOverride(t *testing.T, dir string, name string, patch string) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(patch), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeerPodsManifestsEmbeddedAreValid(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(ValidatePeerPodsManifests(NewPeerPodsManifests(""), "")).To(Succeed())
+}
+
+func TestPeerPodsManifestsOverridePatchesFields(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+	writeManifestOverride(t, dir, "podvm/osc-podvm-create-job.yaml", `
+metadata:
+  labels:
+    example.com/team: peerpods
+spec:
+  backoffLimit: 5
+`)
+
+	manifests := NewPeerPodsManifests(dir)
+	g.Expect(ValidatePeerPodsManifests(manifests, dir)).To(Succeed())
+
+	data, err := fs.ReadFile(podVMManifests(manifests), "osc-podvm-create-job.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+	job, err := parseJobYAML(data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(job.Name).To(Equal("osc-podvm-image-creation"))
+	g.Expect(job.Labels).To(HaveKeyWithValue("example.com/team", "peerpods"))
+	g.Expect(job.Labels).To(HaveKeyWithValue(PodVMImageJobLabel, "true"))
+	g.Expect(job.Spec.BackoffLimit).To(HaveValue(BeEquivalentTo(5)))
+	g.Expect(job.Spec.Template.Spec.Containers).NotTo(BeEmpty())
+}
+
+func TestPeerPodsManifestsOverrideRemovesFields(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+	writeManifestOverride(t, dir, "podvm/aws-podvm-image-cm.yaml", `
+data:
+  INSTANCE_TYPE: null
+`)
+
+	data, err := fs.ReadFile(NewPeerPodsManifests(dir), "podvm/aws-podvm-image-cm.yaml")
+	g.Expect(err).NotTo(HaveOccurred())
+	cm, err := parseConfigMapYAML(data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cm.Data).NotTo(HaveKey("INSTANCE_TYPE"))
+	g.Expect(cm.Data).To(HaveKeyWithValue("PODVM_DISTRO", "rhel"))
+}
+
+func TestPeerPodsManifestsInvalidOverride(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+	writeManifestOverride(t, dir, "mc-50-crio-config.yaml", `
+spec:
+  kernelTypo: realtime
+`)
+
+	err := ValidatePeerPodsManifests(NewPeerPodsManifests(dir), dir)
+	g.Expect(err).To(MatchError(ContainSubstring("mc-50-crio-config.yaml")))
+	g.Expect(err).To(MatchError(ContainSubstring("kernelTypo")))
+}
+
+func TestPeerPodsManifestsUnknownOverride(t *testing.T) {
+	g := NewWithT(t)
+	dir := t.TempDir()
+	writeManifestOverride(t, dir, "podvm/osc-podvm-creat-job.yaml", "spec: {}\n")
+	// A mounted ConfigMap's bookkeeping entries are ignored
+	writeManifestOverride(t, dir, "..2024_01_01_00_00_00.000000000/podvm/osc-podvm-create-job.yaml", "spec: {}\n")
+
+	err := ValidatePeerPodsManifests(NewPeerPodsManifests(dir), dir)
+	g.Expect(err).To(MatchError(ContainSubstring("osc-podvm-creat-job.yaml doesn't match any peer-pods manifest")))
+	g.Expect(err.Error()).NotTo(ContainSubstring("..2024"))
+}
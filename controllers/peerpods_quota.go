@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// Extended resource the peer-pods webhook adds to every peer pod
+	peerPodsExtendedResource = "kata.peerpods.io/vm"
+	peerPodsQuotaName        = "peer-pods-quota"
+	// Label marking the ResourceQuotas managed by the operator
+	peerPodsQuotaLabel = "kataconfiguration.openshift.io/peer-pods-quota"
+	// Namespace annotation overriding
+	// KataConfig.spec.peerPodsNamespaceQuota.maxPeerPods
+	PeerPodsQuotaAnnotation = "kataconfiguration.openshift.io/peer-pods-quota"
+)
+
+// Returns the per-node peer pods limit in the format PeerPodConfig expects
+func (r *KataConfigOpenShiftReconciler) getPeerPodsLimit() string {
+	if r.kataConfig.Spec.PeerPodsLimitPerNode != nil {
+		return strconv.Itoa(int(*r.kataConfig.Spec.PeerPodsLimitPerNode))
+	}
+	return DEFAULT_PEER_PODS
+}
+
+// Returns the selector of the namespaces getting a peer pods quota
+func (r *KataConfigOpenShiftReconciler) getPeerPodsQuotaSelector() (labels.Selector, error) {
+	if r.kataConfig.Spec.PeerPodsNamespaceQuota.NamespaceSelector == nil {
+		return labels.Everything(), nil
+	}
+	return metav1.LabelSelectorAsSelector(r.kataConfig.Spec.PeerPodsNamespaceQuota.NamespaceSelector)
+}
+
+// Tells whether the namespace is a system one, which only gets a peer pods
+// quota if a namespace selector explicitly selects it
+func isSystemNamespace(name string) bool {
+	return name == "default" || strings.HasPrefix(name, "kube-") || strings.HasPrefix(name, "openshift-")
+}
+
+// Returns the maximum number of peer pods in the namespace, the namespace
+// annotation takes precedence over the KataConfig
+func (r *KataConfigOpenShiftReconciler) getPeerPodsNamespaceQuota(ns *corev1.Namespace) int64 {
+	maxPeerPods := int64(r.kataConfig.Spec.PeerPodsNamespaceQuota.MaxPeerPods)
+
+	value, ok := ns.Annotations[PeerPodsQuotaAnnotation]
+	if !ok {
+		return maxPeerPods
+	}
+	override, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || override < 0 {
+		r.Log.Info("Ignoring invalid peer pods quota annotation", "namespace", ns.Name, "value", value)
+		return maxPeerPods
+	}
+	return override
+}
+
+// Creates or updates the peer pods ResourceQuota in the namespace
+func (r *KataConfigOpenShiftReconciler) createOrUpdatePeerPodsQuota(namespace string, maxPeerPods int64) error {
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      peerPodsQuotaName,
+			Namespace: namespace,
+		},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), r.Client, quota, func() error {
+		if quota.Labels == nil {
+			quota.Labels = map[string]string{}
+		}
+		quota.Labels[peerPodsQuotaLabel] = "true"
+		quota.Spec.Hard = corev1.ResourceList{
+			corev1.ResourceName("requests." + peerPodsExtendedResource): *resource.NewQuantity(maxPeerPods, resource.DecimalSI),
+		}
+		return controllerutil.SetControllerReference(r.kataConfig, quota, r.Scheme)
+	})
+	return err
+}
+
+// Makes the peer pods ResourceQuotas match
+// KataConfig.spec.peerPodsNamespaceQuota.  Quotas are created in the
+// selected namespaces and removed from all others, which removes all of them
+// once peer pods or the quota are disabled.
+func (r *KataConfigOpenShiftReconciler) reconcilePeerPodsQuotas() error {
+	desired := map[string]bool{}
+	if r.kataConfig.Spec.EnablePeerPods && r.kataConfig.Spec.PeerPodsNamespaceQuota != nil {
+		selector, err := r.getPeerPodsQuotaSelector()
+		if err != nil {
+			r.Log.Info("Invalid peer pods quota namespace selector", "err", err)
+			return err
+		}
+		namespaces := &corev1.NamespaceList{}
+		if err := r.Client.List(context.TODO(), namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return fmt.Errorf("failed to list namespaces: %v", err)
+		}
+		for i := range namespaces.Items {
+			ns := &namespaces.Items[i]
+			if ns.DeletionTimestamp != nil {
+				continue
+			}
+			if r.kataConfig.Spec.PeerPodsNamespaceQuota.NamespaceSelector == nil && isSystemNamespace(ns.Name) {
+				continue
+			}
+			desired[ns.Name] = true
+			if err := r.createOrUpdatePeerPodsQuota(ns.Name, r.getPeerPodsNamespaceQuota(ns)); err != nil {
+				r.Log.Info("Error in creating peer pods quota", "namespace", ns.Name, "err", err)
+				return err
+			}
+		}
+	}
+
+	quotas := &corev1.ResourceQuotaList{}
+	if err := r.Client.List(context.TODO(), quotas, client.HasLabels{peerPodsQuotaLabel}); err != nil {
+		return fmt.Errorf("failed to list peer pods quotas: %v", err)
+	}
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		if desired[quota.Namespace] {
+			continue
+		}
+		r.Log.Info("Deleting peer pods quota", "namespace", quota.Namespace)
+		if err := r.Client.Delete(context.TODO(), quota); err != nil && !k8serrors.IsNotFound(err) {
+			r.Log.Info("Error in deleting peer pods quota", "namespace", quota.Namespace, "err", err)
+			return err
+		}
+	}
+	return nil
+}
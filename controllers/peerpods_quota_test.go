@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Returns a KataConfig with peer pods and the given namespace quota enabled
+func newPeerPodsQuotaKataConfig(quota *kataconfigurationv1.PeerPodsNamespaceQuotaSpec) *kataconfigurationv1.KataConfig {
+	return &kataconfigurationv1.KataConfig{
+		Spec: kataconfigurationv1.KataConfigSpec{
+			EnablePeerPods:         true,
+			PeerPodsNamespaceQuota: quota,
+		},
+	}
+}
+
+func newNamespace(name string, labels map[string]string, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels, Annotations: annotations},
+	}
+}
+
+// Returns the peer pods quota of the namespace, nil if there is none
+func getPeerPodsQuota(g *WithT, c client.Client, namespace string) *resource.Quantity {
+	quota := &corev1.ResourceQuota{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: peerPodsQuotaName, Namespace: namespace}, quota)
+	if err != nil {
+		return nil
+	}
+	g.Expect(quota.Labels).To(HaveKeyWithValue(peerPodsQuotaLabel, "true"))
+	hard := quota.Spec.Hard[corev1.ResourceName("requests."+peerPodsExtendedResource)]
+	return &hard
+}
+
+func TestPeerPodsLimit(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, newPeerPodsQuotaKataConfig(nil), peerPodsTestSchemes)
+	g.Expect(r.getPeerPodsLimit()).To(Equal(DEFAULT_PEER_PODS))
+
+	limit := int32(25)
+	r.kataConfig.Spec.PeerPodsLimitPerNode = &limit
+	g.Expect(r.getPeerPodsLimit()).To(Equal("25"))
+}
+
+func TestPeerPodsQuotaSelectedNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t,
+		newPeerPodsQuotaKataConfig(&kataconfigurationv1.PeerPodsNamespaceQuotaSpec{
+			MaxPeerPods: 5,
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"peerpods": "enabled"},
+			},
+		}),
+		peerPodsTestSchemes,
+		newNamespace("team-a", map[string]string{"peerpods": "enabled"}, nil),
+		newNamespace("team-b", map[string]string{"peerpods": "enabled"}, map[string]string{PeerPodsQuotaAnnotation: "2"}),
+		newNamespace("team-c", map[string]string{"peerpods": "enabled"}, map[string]string{PeerPodsQuotaAnnotation: "many"}),
+		newNamespace("other", nil, nil),
+	)
+
+	g.Expect(r.reconcilePeerPodsQuotas()).To(Succeed())
+
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-a")).To(HaveValue(Equal(resource.MustParse("5"))))
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-b")).To(HaveValue(Equal(resource.MustParse("2"))))
+	// An invalid annotation falls back to the KataConfig
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-c")).To(HaveValue(Equal(resource.MustParse("5"))))
+	g.Expect(getPeerPodsQuota(g, r.Client, "other")).To(BeNil())
+
+	// Changing the KataConfig updates the existing quotas
+	r.kataConfig.Spec.PeerPodsNamespaceQuota.MaxPeerPods = 8
+	g.Expect(r.reconcilePeerPodsQuotas()).To(Succeed())
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-a")).To(HaveValue(Equal(resource.MustParse("8"))))
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-b")).To(HaveValue(Equal(resource.MustParse("2"))))
+}
+
+func TestPeerPodsQuotaRemoved(t *testing.T) {
+	g := NewWithT(t)
+	// A quota the operator doesn't manage is left alone
+	userQuota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "user-quota", Namespace: "team-a"},
+	}
+	r := newTestReconciler(t,
+		newPeerPodsQuotaKataConfig(&kataconfigurationv1.PeerPodsNamespaceQuotaSpec{MaxPeerPods: 5}),
+		peerPodsTestSchemes,
+		newNamespace("team-a", nil, nil),
+		newNamespace("team-b", nil, nil),
+		userQuota,
+	)
+
+	g.Expect(r.reconcilePeerPodsQuotas()).To(Succeed())
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-a")).NotTo(BeNil())
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-b")).NotTo(BeNil())
+
+	r.kataConfig.Spec.PeerPodsNamespaceQuota = nil
+	g.Expect(r.reconcilePeerPodsQuotas()).To(Succeed())
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-a")).To(BeNil())
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-b")).To(BeNil())
+	expectObjectExists(g, r.Client, userQuota, true)
+}
+
+func TestPeerPodsQuotaSystemNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t,
+		newPeerPodsQuotaKataConfig(&kataconfigurationv1.PeerPodsNamespaceQuotaSpec{MaxPeerPods: 5}),
+		peerPodsTestSchemes,
+		newNamespace("team-a", map[string]string{"peerpods": "enabled"}, nil),
+		newNamespace("default", nil, nil),
+		newNamespace("kube-system", nil, nil),
+		newNamespace(OperatorNamespace, map[string]string{"peerpods": "enabled"}, nil),
+	)
+
+	// Without a selector the system namespaces are left alone
+	g.Expect(r.reconcilePeerPodsQuotas()).To(Succeed())
+	g.Expect(getPeerPodsQuota(g, r.Client, "team-a")).NotTo(BeNil())
+	g.Expect(getPeerPodsQuota(g, r.Client, "default")).To(BeNil())
+	g.Expect(getPeerPodsQuota(g, r.Client, "kube-system")).To(BeNil())
+	g.Expect(getPeerPodsQuota(g, r.Client, OperatorNamespace)).To(BeNil())
+
+	// A selector can still pick them
+	r.kataConfig.Spec.PeerPodsNamespaceQuota.NamespaceSelector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"peerpods": "enabled"},
+	}
+	g.Expect(r.reconcilePeerPodsQuotas()).To(Succeed())
+	g.Expect(getPeerPodsQuota(g, r.Client, OperatorNamespace)).NotTo(BeNil())
+}
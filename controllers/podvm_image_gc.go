@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Setting this KataConfig annotation to a new value requests a garbage
+// collection run for orphaned pod VM images
+const PodVMImageGCAnnotation = "kataconfiguration.openshift.io/podvm-image-gc"
+
+// Default for KataConfig.spec.podVMImage.garbageCollection.intervalHours
+const defaultPodVMImageGCIntervalHours = 24
+
+// Returns the interval between scheduled garbage collection runs, zero if
+// scheduled runs are disabled
+func (r *KataConfigOpenShiftReconciler) getPodVMImageGCInterval() time.Duration {
+	hours := defaultPodVMImageGCIntervalHours
+	if r.kataConfig.Spec.PodVMImage != nil && r.kataConfig.Spec.PodVMImage.GarbageCollection != nil &&
+		r.kataConfig.Spec.PodVMImage.GarbageCollection.IntervalHours != nil {
+		hours = *r.kataConfig.Spec.PodVMImage.GarbageCollection.IntervalHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Returns the result requeueing the reconcile for the next scheduled garbage
+// collection run
+func (r *KataConfigOpenShiftReconciler) getPodVMImageGCSchedule() ctrl.Result {
+	interval := r.getPodVMImageGCInterval()
+	lastRunTime := r.kataConfig.Status.PodVMImage.GarbageCollection.LastRunTime
+	if interval == 0 || lastRunTime == nil {
+		return ctrl.Result{}
+	}
+
+	wait := time.Until(lastRunTime.Add(interval))
+	if wait <= 0 {
+		return ctrl.Result{Requeue: true}
+	}
+	return ctrl.Result{Requeue: true, RequeueAfter: wait}
+}
+
+// Returns true if a garbage collection run is requested through the
+// annotation or due according to the schedule
+func (r *KataConfigOpenShiftReconciler) isPodVMImageGCDue() bool {
+	gc := r.kataConfig.Status.PodVMImage.GarbageCollection
+
+	if request := r.kataConfig.Annotations[PodVMImageGCAnnotation]; request != "" && request != gc.LastRequest {
+		return true
+	}
+
+	interval := r.getPodVMImageGCInterval()
+	if interval == 0 {
+		return false
+	}
+	return gc.LastRunTime == nil || time.Since(gc.LastRunTime.Time) >= interval
+}
+
+// Deletes pod VM images which were created for this cluster but aren't
+// referenced in peer-pods-cm anymore, eg. because a KataConfig was
+// force-deleted or the deletion of an image failed.  The images are found
+// through the cluster id tag the image creation job puts on them.  A run
+// first lists the images of the cluster and then deletes the orphaned ones
+// one at a time using the image deletion job.  The progress is kept in
+// KataConfig.status.podVMImage.garbageCollection.
+func (r *KataConfigOpenShiftReconciler) collectOrphanedPodVMImages() (ctrl.Result, error) {
+	ig, err := r.getImageGenerator()
+	if err != nil {
+		r.Log.Info("error initializing ImageGenerator instance", "err", err)
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	if ig.provider != AWSProvider && ig.provider != AzureProvider {
+		return ctrl.Result{}, nil
+	}
+
+	// Without an image in use every image of the cluster would be
+	// considered orphaned
+	if !ig.isImageIDSet() {
+		r.Log.Info("PodVM image ID is not set, skipping garbage collection of orphaned images")
+		return ctrl.Result{}, nil
+	}
+
+	gc := &r.kataConfig.Status.PodVMImage.GarbageCollection
+	if len(gc.PendingImages) > 0 {
+		return r.deleteOrphanedPodVMImage(ig)
+	}
+
+	if !r.isPodVMImageGCDue() {
+		return r.getPodVMImageGCSchedule(), nil
+	}
+
+	// Copies made by a running replication job aren't recorded in
+	// peer-pods-cm yet, the job watch triggers a reconcile once it's done
+	if running, err := ig.isJobPresent(podvmImageReplicationJobName); err != nil || running {
+		r.Log.Info("Waiting for the PodVM image replication job before collecting orphaned images", "err", err)
+		return ctrl.Result{}, err
+	}
+
+	request := r.kataConfig.Annotations[PodVMImageGCAnnotation]
+
+	status, err := ig.imageListJobRunner(r.getPodVMImageRegions())
+	switch status {
+	case ImageJobRunning:
+		r.Log.Info("Listing the PodVM images of the cluster")
+		return ctrl.Result{}, nil
+
+	case ImageJobCompleted, ImageJobFailed:
+		now := metav1.Now()
+		gc.LastRunTime = &now
+		gc.LastRequest = request
+		gc.PendingImages = nil
+		gc.DeletedImages = nil
+		gc.LastError = ""
+
+		if status == ImageJobFailed {
+			gc.LastError = "listing the images of the cluster failed: " + ig.LastFailureReason(imageJobList)
+			r.Log.Info("PodVM image garbage collection failed", "err", gc.LastError)
+			return r.getPodVMImageGCSchedule(), nil
+		}
+
+		images, err := ig.getClusterImages()
+		if err != nil {
+			gc.LastError = err.Error()
+			r.Log.Info("PodVM image garbage collection failed", "err", err)
+			return r.getPodVMImageGCSchedule(), nil
+		}
+
+		gc.PendingImages = selectOrphanedPodVMImages(images, ig.getReferencedImageIDs())
+		r.Log.Info("Listed the PodVM images of the cluster", "images", len(images), "orphaned", len(gc.PendingImages))
+
+		if len(gc.PendingImages) > 0 {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		return r.getPodVMImageGCSchedule(), nil
+
+	default:
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+}
+
+// Deletes the first of the pending orphaned images
+func (r *KataConfigOpenShiftReconciler) deleteOrphanedPodVMImage(ig *ImageGenerator) (ctrl.Result, error) {
+	gc := &r.kataConfig.Status.PodVMImage.GarbageCollection
+	image := gc.PendingImages[0]
+
+	// The image might have been taken into use since it was listed
+	if isPodVMImageReferenced(image, ig.getReferencedImageIDs()) {
+		r.Log.Info("PodVM image is in use, not deleting it", "image", image.ID, "region", image.Region)
+		gc.PendingImages = gc.PendingImages[1:]
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	status, err := ig.orphanedImageDeleteJobRunner(image)
+	switch status {
+	case ImageJobRunning:
+		r.Log.Info("Deleting orphaned PodVM image", "image", image.ID, "region", image.Region)
+		return ctrl.Result{}, nil
+
+	case ImageJobCompleted:
+		r.Log.Info("Orphaned PodVM image deleted", "image", image.ID, "region", image.Region)
+		gc.DeletedImages = append(gc.DeletedImages, image)
+
+	case ImageJobFailed:
+		r.Log.Info("Deleting orphaned PodVM image failed", "image", image.ID, "region", image.Region, "err", err)
+		reason := ig.LastFailureReason(imageJobGC)
+		if err != nil {
+			reason = err.Error()
+		}
+		gc.LastError = fmt.Sprintf("deleting image %s failed: %s", image.ID, reason)
+
+	default:
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	gc.PendingImages = gc.PendingImages[1:]
+	if len(gc.PendingImages) > 0 {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return r.getPodVMImageGCSchedule(), nil
+}
+
+// Returns the images of the cluster which aren't referenced in peer-pods-cm.
+// Azure galleries come last so that the image versions they hold are deleted
+// one by one first.
+func selectOrphanedPodVMImages(images []kataconfigurationv1.PodVMImageReference, referenced map[string]bool) []kataconfigurationv1.PodVMImageReference {
+	var orphaned, galleries []kataconfigurationv1.PodVMImageReference
+	for _, image := range images {
+		if isPodVMImageReferenced(image, referenced) {
+			continue
+		}
+		if azureGalleryName(image.ID) != "" {
+			galleries = append(galleries, image)
+		} else {
+			orphaned = append(orphaned, image)
+		}
+	}
+	return append(orphaned, galleries...)
+}
+
+// Returns true if the image, or for an Azure gallery an image version it
+// holds, is referenced
+func isPodVMImageReferenced(image kataconfigurationv1.PodVMImageReference, referenced map[string]bool) bool {
+	if referenced[image.ID] {
+		return true
+	}
+	if azureGalleryName(image.ID) == "" {
+		return false
+	}
+	// Azure resource IDs are case insensitive
+	prefix := strings.ToLower(image.ID) + "/"
+	for id := range referenced {
+		if strings.HasPrefix(strings.ToLower(id), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the name of the gallery if the resource ID refers to an Azure
+// compute gallery rather than to an image version, an empty string otherwise
+func azureGalleryName(id string) string {
+	const galleriesPath = "/providers/microsoft.compute/galleries/"
+	i := strings.Index(strings.ToLower(id), galleriesPath)
+	if i < 0 {
+		return ""
+	}
+	name := id[i+len(galleriesPath):]
+	if name == "" || strings.Contains(name, "/") {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const testAzureGallery = "/subscriptions/0000/resourceGroups/rg/providers/Microsoft.Compute/galleries/PodVMGallery_" + testClusterID
+
+func TestSelectOrphanedPodVMImages(t *testing.T) {
+	inUse := testAzureGallery + "/images/podvm-image/versions/0.0.2"
+	orphanedVersion := testAzureGallery + "/images/podvm-image/versions/0.0.1"
+	otherGallery := "/subscriptions/0000/resourceGroups/rg/providers/Microsoft.Compute/galleries/PodVMGallery_other"
+
+	for _, tc := range []struct {
+		name       string
+		images     []kataconfigurationv1.PodVMImageReference
+		referenced map[string]bool
+		orphaned   []kataconfigurationv1.PodVMImageReference
+	}{
+		{
+			name:       "nothing listed",
+			referenced: map[string]bool{testImageID: true},
+		},
+		{
+			name: "image in use and its copies are kept",
+			images: []kataconfigurationv1.PodVMImageReference{
+				{ID: testImageID},
+				{ID: "ami-0aaaaaaaaaaaaaaaa", Region: "us-west-1"},
+				{ID: "ami-0bbbbbbbbbbbbbbbb"},
+				{ID: "ami-0cccccccccccccccc", Region: "eu-west-1"},
+			},
+			referenced: map[string]bool{testImageID: true, "ami-0aaaaaaaaaaaaaaaa": true},
+			orphaned: []kataconfigurationv1.PodVMImageReference{
+				{ID: "ami-0bbbbbbbbbbbbbbbb"},
+				{ID: "ami-0cccccccccccccccc", Region: "eu-west-1"},
+			},
+		},
+		{
+			name: "gallery holding the image in use is kept",
+			images: []kataconfigurationv1.PodVMImageReference{
+				{ID: testAzureGallery},
+				{ID: inUse},
+				{ID: orphanedVersion},
+			},
+			// Case differences in Azure resource IDs don't matter
+			referenced: map[string]bool{"/subscriptions/0000/resourceGroups/RG/providers/Microsoft.Compute/galleries/PodVMGallery_" + testClusterID + "/images/podvm-image/versions/0.0.2": true},
+			orphaned:   []kataconfigurationv1.PodVMImageReference{{ID: inUse}, {ID: orphanedVersion}},
+		},
+		{
+			name: "orphaned gallery comes after its image versions",
+			images: []kataconfigurationv1.PodVMImageReference{
+				{ID: testAzureGallery},
+				{ID: orphanedVersion},
+			},
+			referenced: map[string]bool{otherGallery + "/images/imported/versions/1.0.0": true},
+			orphaned:   []kataconfigurationv1.PodVMImageReference{{ID: orphanedVersion}, {ID: testAzureGallery}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(selectOrphanedPodVMImages(tc.images, tc.referenced)).To(Equal(tc.orphaned))
+		})
+	}
+}
+
+func TestAzureGalleryName(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(azureGalleryName(testAzureGallery)).To(Equal("PodVMGallery_" + testClusterID))
+	g.Expect(azureGalleryName(testAzureGallery + "/images/podvm-image/versions/0.0.1")).To(BeEmpty())
+	g.Expect(azureGalleryName(testImageID)).To(BeEmpty())
+}
+
+func TestPodVMImageGC(t *testing.T) {
+	it := newImageGeneratorTest(t, AWSProvider, map[string]string{peerpodsCMAWSImageKey: testImageID})
+
+	// The run starts by listing the images of the cluster
+	_, err := it.r.collectOrphanedPodVMImages()
+	it.g.Expect(err).NotTo(HaveOccurred())
+	listJob, err := it.getJob("osc-podvm-image-list")
+	it.g.Expect(err).NotTo(HaveOccurred())
+
+	// Record the images like the list job does
+	orphaned := kataconfigurationv1.PodVMImageReference{ID: "ami-0bbbbbbbbbbbbbbbb", Region: "us-west-1"}
+	images, _ := json.Marshal([]kataconfigurationv1.PodVMImageReference{{ID: testImageID}, orphaned})
+	it.updatePeerPodsCM(func(cm *corev1.ConfigMap) {
+		cm.Annotations = map[string]string{peerpodsCMClusterImagesAnnotation: string(images)}
+	})
+	it.finishJob(listJob.Name, batchv1.JobComplete)
+
+	result, err := it.r.collectOrphanedPodVMImages()
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(result.Requeue).To(BeTrue())
+	gc := &it.r.kataConfig.Status.PodVMImage.GarbageCollection
+	it.g.Expect(gc.PendingImages).To(Equal([]kataconfigurationv1.PodVMImageReference{orphaned}))
+
+	// The orphaned image is deleted in its region, never the one in use
+	_, err = it.r.collectOrphanedPodVMImages()
+	it.g.Expect(err).NotTo(HaveOccurred())
+	jobs := &batchv1.JobList{}
+	it.g.Expect(it.c.List(context.TODO(), jobs)).To(Succeed())
+	var deleteJob *batchv1.Job
+	for i := range jobs.Items {
+		if jobEnv(&jobs.Items[i], "AMI_ID") == orphaned.ID {
+			deleteJob = &jobs.Items[i]
+		}
+	}
+	it.g.Expect(deleteJob).NotTo(BeNil())
+	it.g.Expect(deleteJob.Name).To(HavePrefix(orphanedImageDeleteJobPrefix))
+	it.g.Expect(jobEnv(deleteJob, "AWS_REGION")).To(Equal("us-west-1"))
+	it.g.Expect(jobEnv(deleteJob, "UPDATE_PEERPODS_CM")).To(Equal("no"))
+
+	it.finishJob(deleteJob.Name, batchv1.JobComplete)
+	_, err = it.r.collectOrphanedPodVMImages()
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(gc.PendingImages).To(BeEmpty())
+	it.g.Expect(gc.DeletedImages).To(Equal([]kataconfigurationv1.PodVMImageReference{orphaned}))
+	it.g.Expect(gc.LastError).To(BeEmpty())
+}
+
+func TestOrphanedAzureGalleryDeletion(t *testing.T) {
+	it := newImageGeneratorTest(t, AzureProvider, nil)
+	ig, err := it.r.getImageGenerator()
+	it.g.Expect(err).NotTo(HaveOccurred())
+
+	status, err := ig.orphanedImageDeleteJobRunner(kataconfigurationv1.PodVMImageReference{ID: testAzureGallery})
+	it.g.Expect(err).NotTo(HaveOccurred())
+	it.g.Expect(status).To(Equal(ImageJobRunning))
+
+	jobs := &batchv1.JobList{}
+	it.g.Expect(it.c.List(context.TODO(), jobs)).To(Succeed())
+	it.g.Expect(jobs.Items).To(HaveLen(1))
+	job := &jobs.Items[0]
+	it.g.Expect(job.Name).To(HavePrefix(orphanedImageDeleteJobPrefix))
+	it.g.Expect(job.Spec.Template.Spec.Containers[0].Command).To(Equal([]string{"/podvm-builder.sh", "delete-gallery", "-f"}))
+	it.g.Expect(jobEnv(job, "GC_GALLERY_NAME")).To(Equal("PodVMGallery_" + testClusterID))
+}
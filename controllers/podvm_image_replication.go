@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Delay before the replication to regions which have failed is retried
+const podVMImageReplicationRetryInterval = 15 * time.Minute
+
+// Returns the regions from KataConfig.spec.podVMImage.regions
+func (r *KataConfigOpenShiftReconciler) getPodVMImageRegions() []string {
+	if r.kataConfig.Spec.PodVMImage == nil {
+		return nil
+	}
+	return r.kataConfig.Spec.PodVMImage.Regions
+}
+
+// Returns true if the replication to at least one region has failed
+func (r *KataConfigOpenShiftReconciler) hasPodVMImageReplicationFailures() bool {
+	for _, region := range r.kataConfig.Status.PodVMImage.Regions {
+		if region.State == kataconfigurationv1.PodVMImageRegionFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns how long we still need to wait before the replication to failed
+// regions can be retried.  Zero means it can be retried right away.
+func (r *KataConfigOpenShiftReconciler) getPodVMImageReplicationWait() time.Duration {
+	status := r.kataConfig.Status.PodVMImage
+	if status.LastReplicationTime == nil || !r.hasPodVMImageReplicationFailures() {
+		return 0
+	}
+
+	wait := time.Until(status.LastReplicationTime.Add(podVMImageReplicationRetryInterval))
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// Updates KataConfig.status.podVMImage.regions from the per region result
+// recorded by the image replication job.  Regions without a result are
+// reported as failed with failureReason if it's set, pending otherwise.
+func (r *KataConfigOpenShiftReconciler) updatePodVMImageRegionsStatus(regions []string, imageRegions map[string]podvmImageRegion, failureReason string) {
+	var regionsStatus []kataconfigurationv1.PodVMImageRegionStatus
+	for _, region := range regions {
+		regionStatus := kataconfigurationv1.PodVMImageRegionStatus{
+			Region: region,
+			State:  kataconfigurationv1.PodVMImageRegionPending,
+		}
+
+		result, ok := imageRegions[region]
+		switch {
+		case ok && result.ImageID != "":
+			regionStatus.State = kataconfigurationv1.PodVMImageRegionReady
+			regionStatus.ImageID = result.ImageID
+		case ok && result.Error != "":
+			regionStatus.State = kataconfigurationv1.PodVMImageRegionFailed
+			regionStatus.Message = result.Error
+		case failureReason != "":
+			regionStatus.State = kataconfigurationv1.PodVMImageRegionFailed
+			regionStatus.Message = failureReason
+		}
+
+		regionsStatus = append(regionsStatus, regionStatus)
+	}
+	r.kataConfig.Status.PodVMImage.Regions = regionsStatus
+}
+
+// Replicates the pod VM image to the regions from
+// KataConfig.spec.podVMImage.regions and records the state per region in
+// KataConfig.status.podVMImage.regions.  Only images created by the operator
+// on AWS and Azure are replicated.  Regions the replication has failed for
+// are retried after podVMImageReplicationRetryInterval.
+func (r *KataConfigOpenShiftReconciler) replicatePodVMImage() (ctrl.Result, error) {
+	regions := r.getPodVMImageRegions()
+	if len(regions) == 0 {
+		r.kataConfig.Status.PodVMImage.Regions = nil
+		r.kataConfig.Status.PodVMImage.LastReplicationTime = nil
+		return ctrl.Result{}, nil
+	}
+
+	ig, err := r.getImageGenerator()
+	if err != nil {
+		r.Log.Info("error initializing ImageGenerator instance", "err", err)
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	if ig.provider != AWSProvider && ig.provider != AzureProvider {
+		r.Log.Info("PodVM image replication is not supported for the cloud provider, skipping", "provider", ig.provider)
+		return ctrl.Result{}, nil
+	}
+
+	if !ig.isImageIDSet() {
+		r.Log.Info("PodVM image ID is not set, skipping image replication")
+		return ctrl.Result{}, nil
+	}
+
+	if importedImage := ig.getImportedImage(); importedImage != "" {
+		r.Log.Info("PodVM image has been imported, skipping image replication", "image", importedImage)
+		r.kataConfig.Status.PodVMImage.Regions = nil
+		return ctrl.Result{}, nil
+	}
+
+	if wait := r.getPodVMImageReplicationWait(); wait > 0 {
+		r.Log.Info("Waiting before retrying PodVM image replication", "wait", wait)
+		return ctrl.Result{Requeue: true, RequeueAfter: wait}, nil
+	}
+
+	status, err := ig.imageReplicateJobRunner(regions)
+	switch status {
+	case ImageReplicatedSuccessfully:
+		r.updatePodVMImageRegionsStatus(regions, ig.getImageRegions(), "")
+		r.Log.Info("PodVM image is available in all regions")
+
+	case ImageReplicationInProgress:
+		// The image replication job watch triggers a reconcile once
+		// the job has finished
+		r.updatePodVMImageRegionsStatus(regions, ig.getImageRegions(), "")
+		return ctrl.Result{}, nil
+
+	case ImageReplicationCompleted, ImageReplicationFailed:
+		failureReason := ""
+		if status == ImageReplicationFailed {
+			failureReason = ig.LastFailureReason(imageJobReplicate)
+			if failureReason == "" {
+				failureReason = "image replication job has failed"
+			}
+		}
+
+		now := metav1.Now()
+		r.kataConfig.Status.PodVMImage.LastReplicationTime = &now
+		r.updatePodVMImageRegionsStatus(regions, ig.getImageRegions(), failureReason)
+
+		if r.hasPodVMImageReplicationFailures() {
+			r.Log.Info("PodVM image replication has failed for some regions, retrying later",
+				"retryAfter", podVMImageReplicationRetryInterval)
+			return ctrl.Result{Requeue: true, RequeueAfter: podVMImageReplicationRetryInterval}, nil
+		}
+		r.Log.Info("PodVM image replication has completed")
+
+	default:
+		return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+	}
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Defaults for KataConfig.spec.podVMImage.retryPolicy
+const (
+	defaultPodVMImageMaxAttempts           = 3
+	defaultPodVMImageInitialBackoffSeconds = 60
+	defaultPodVMImageMaxBackoffSeconds     = 3600
+)
+
+// Returns the retry policy for pod VM image creation with defaults filled in
+// for anything the user didn't set.  The CRD requires the settings to be
+// positive, so zero means unset.
+func (r *KataConfigOpenShiftReconciler) getPodVMImageRetryPolicy() kataconfigurationv1.PodVMImageRetryPolicy {
+	policy := kataconfigurationv1.PodVMImageRetryPolicy{
+		MaxAttempts:           defaultPodVMImageMaxAttempts,
+		InitialBackoffSeconds: defaultPodVMImageInitialBackoffSeconds,
+		MaxBackoffSeconds:     defaultPodVMImageMaxBackoffSeconds,
+	}
+
+	if r.kataConfig.Spec.PodVMImage == nil || r.kataConfig.Spec.PodVMImage.RetryPolicy == nil {
+		return policy
+	}
+
+	userPolicy := r.kataConfig.Spec.PodVMImage.RetryPolicy
+	if userPolicy.MaxAttempts > 0 {
+		policy.MaxAttempts = userPolicy.MaxAttempts
+	}
+	if userPolicy.InitialBackoffSeconds > 0 {
+		policy.InitialBackoffSeconds = userPolicy.InitialBackoffSeconds
+	}
+	if userPolicy.MaxBackoffSeconds > 0 {
+		policy.MaxBackoffSeconds = userPolicy.MaxBackoffSeconds
+	}
+	return policy
+}
+
+// Returns the delay to apply after the given number of failed attempts.
+// The delay starts at InitialBackoffSeconds and doubles with every failure
+// up to MaxBackoffSeconds.
+func podVMImageRetryBackoff(policy kataconfigurationv1.PodVMImageRetryPolicy, failedAttempts int) time.Duration {
+	if failedAttempts <= 0 {
+		return 0
+	}
+
+	backoff := time.Duration(policy.InitialBackoffSeconds) * time.Second
+	maxBackoff := time.Duration(policy.MaxBackoffSeconds) * time.Second
+	for i := 1; i < failedAttempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// Returns true if no further pod VM image creation attempts are allowed
+func (r *KataConfigOpenShiftReconciler) isPodVMImageRetryBudgetSpent() bool {
+	return r.kataConfig.Status.PodVMImage.FailedAttempts >= r.getPodVMImageRetryPolicy().MaxAttempts
+}
+
+// Returns how long we still need to wait before the next pod VM image
+// creation attempt can be started.  Zero means it can be started right away.
+func (r *KataConfigOpenShiftReconciler) getPodVMImageRetryWait() time.Duration {
+	status := r.kataConfig.Status.PodVMImage
+	if status.FailedAttempts == 0 || status.LastFailureTime == nil {
+		return 0
+	}
+
+	backoff := podVMImageRetryBackoff(r.getPodVMImageRetryPolicy(), status.FailedAttempts)
+	wait := time.Until(status.LastFailureTime.Add(backoff))
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// Records a failed pod VM image creation attempt in KataConfig.status and
+// sets the PodVMImageBuildFailed condition once the retry budget is spent.
+// Returns the delay after which the next attempt is due, or zero if no
+// further attempts will be made.
+func (r *KataConfigOpenShiftReconciler) recordPodVMImageCreationFailure(reason string, logsConfigMap string) time.Duration {
+	now := metav1.Now()
+	status := &r.kataConfig.Status.PodVMImage
+	status.FailedAttempts++
+	status.LastFailureReason = reason
+	status.LastFailureTime = &now
+	status.LastFailureLogsConfigMap = logsConfigMap
+
+	policy := r.getPodVMImageRetryPolicy()
+	r.Log.Info("PodVM image creation attempt failed", "attempt", status.FailedAttempts,
+		"maxAttempts", policy.MaxAttempts, "reason", reason)
+
+	if r.isPodVMImageRetryBudgetSpent() {
+		r.setPodVMImageBuildFailedCondition()
+		return 0
+	}
+
+	return podVMImageRetryBackoff(policy, status.FailedAttempts)
+}
+
+// Clears the record of failed pod VM image creation attempts, the rest of
+// the pod VM image status is kept
+func (r *KataConfigOpenShiftReconciler) resetPodVMImageCreationFailures() {
+	status := &r.kataConfig.Status.PodVMImage
+	status.FailedAttempts = 0
+	status.LastFailureReason = ""
+	status.LastFailureTime = nil
+	status.LastFailureLogsConfigMap = ""
+	r.clearPodVMImageBuildFailedCondition()
+}
+
+// Clears the whole pod VM image status once the image is gone
+func (r *KataConfigOpenShiftReconciler) resetPodVMImageStatus() {
+	r.kataConfig.Status.PodVMImage = kataconfigurationv1.PodVMImageStatus{}
+	r.clearPodVMImageBuildFailedCondition()
+}
+
+func (r *KataConfigOpenShiftReconciler) findCondition(condType kataconfigurationv1.KataConfigConditionType) *kataconfigurationv1.KataConfigCondition {
+	for i := 0; i < len(r.kataConfig.Status.Conditions); i++ {
+		if r.kataConfig.Status.Conditions[i].Type == condType {
+			return &r.kataConfig.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func (r *KataConfigOpenShiftReconciler) setPodVMImageBuildFailedCondition() {
+	status := r.kataConfig.Status.PodVMImage
+	message := fmt.Sprintf("PodVM image creation failed %d time(s), giving up. Last failure: %s",
+		status.FailedAttempts, status.LastFailureReason)
+	if status.LastFailureLogsConfigMap != "" {
+		message += fmt.Sprintf(" (logs saved in the %s ConfigMap)", status.LastFailureLogsConfigMap)
+	}
+
+	cond := r.findCondition(kataconfigurationv1.KataConfigPodVMImageBuildFailed)
+	if cond == nil {
+		r.kataConfig.Status.Conditions = append(r.kataConfig.Status.Conditions,
+			kataconfigurationv1.KataConfigCondition{Type: kataconfigurationv1.KataConfigPodVMImageBuildFailed})
+		cond = &r.kataConfig.Status.Conditions[len(r.kataConfig.Status.Conditions)-1]
+	}
+
+	if cond.Status != corev1.ConditionTrue {
+		cond.LastTransitionTime = metav1.Now()
+	}
+	cond.Status = corev1.ConditionTrue
+	cond.Reason = PodVMImageJobFailed
+	cond.Message = message
+
+	r.Log.Info("PodVMImageBuildFailed Condition set")
+}
+
+func (r *KataConfigOpenShiftReconciler) clearPodVMImageBuildFailedCondition() {
+	conditions := r.kataConfig.Status.Conditions[:0]
+	for _, cond := range r.kataConfig.Status.Conditions {
+		if cond.Type != kataconfigurationv1.KataConfigPodVMImageBuildFailed {
+			conditions = append(conditions, cond)
+		}
+	}
+	r.kataConfig.Status.Conditions = conditions
+}
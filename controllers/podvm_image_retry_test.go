@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPodVMImageRetryBackoff(t *testing.T) {
+	policy := kataconfigurationv1.PodVMImageRetryPolicy{
+		MaxAttempts:           10,
+		InitialBackoffSeconds: 60,
+		MaxBackoffSeconds:     300,
+	}
+
+	for _, tc := range []struct {
+		failedAttempts int
+		backoff        time.Duration
+	}{
+		{0, 0},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		// Capped at MaxBackoffSeconds
+		{4, 5 * time.Minute},
+		{9, 5 * time.Minute},
+	} {
+		g := NewWithT(t)
+		g.Expect(podVMImageRetryBackoff(policy, tc.failedAttempts)).To(Equal(tc.backoff), "after %d failed attempts", tc.failedAttempts)
+	}
+}
+
+func TestPodVMImageRetryPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		podVMSpec *kataconfigurationv1.PodVMImageSpec
+		policy    kataconfigurationv1.PodVMImageRetryPolicy
+	}{
+		{
+			name: "defaults",
+			policy: kataconfigurationv1.PodVMImageRetryPolicy{
+				MaxAttempts:           defaultPodVMImageMaxAttempts,
+				InitialBackoffSeconds: defaultPodVMImageInitialBackoffSeconds,
+				MaxBackoffSeconds:     defaultPodVMImageMaxBackoffSeconds,
+			},
+		},
+		{
+			name: "partially set",
+			podVMSpec: &kataconfigurationv1.PodVMImageSpec{
+				RetryPolicy: &kataconfigurationv1.PodVMImageRetryPolicy{InitialBackoffSeconds: 1},
+			},
+			policy: kataconfigurationv1.PodVMImageRetryPolicy{
+				MaxAttempts:           defaultPodVMImageMaxAttempts,
+				InitialBackoffSeconds: 1,
+				MaxBackoffSeconds:     defaultPodVMImageMaxBackoffSeconds,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			r := newTestReconciler(t, nil, nil)
+			r.kataConfig.Spec.PodVMImage = tc.podVMSpec
+			g.Expect(r.getPodVMImageRetryPolicy()).To(Equal(tc.policy))
+		})
+	}
+}
+
+func TestPodVMImageRetryBudget(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+	r.kataConfig.Spec.PodVMImage = &kataconfigurationv1.PodVMImageSpec{
+		RetryPolicy: &kataconfigurationv1.PodVMImageRetryPolicy{
+			MaxAttempts:           3,
+			InitialBackoffSeconds: 10,
+			MaxBackoffSeconds:     15,
+		},
+	}
+	r.kataConfig.Status.PodVMImage.Regions = []kataconfigurationv1.PodVMImageRegionStatus{{Region: "us-west-1"}}
+
+	// Retries are scheduled until the budget is spent
+	g.Expect(r.recordPodVMImageCreationFailure("quota exceeded", "")).To(Equal(10 * time.Second))
+	g.Expect(r.getPodVMImageRetryWait()).To(BeNumerically(">", 0))
+	g.Expect(r.recordPodVMImageCreationFailure("quota exceeded", "")).To(Equal(15 * time.Second))
+	g.Expect(r.isPodVMImageRetryBudgetSpent()).To(BeFalse())
+	g.Expect(r.findCondition(kataconfigurationv1.KataConfigPodVMImageBuildFailed)).To(BeNil())
+
+	g.Expect(r.recordPodVMImageCreationFailure("quota exceeded", "osc-podvm-image-creation-logs")).To(BeZero())
+	g.Expect(r.isPodVMImageRetryBudgetSpent()).To(BeTrue())
+	cond := r.findCondition(kataconfigurationv1.KataConfigPodVMImageBuildFailed)
+	g.Expect(cond).NotTo(BeNil())
+	g.Expect(cond.Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(cond.Message).To(ContainSubstring("osc-podvm-image-creation-logs"))
+
+	// A successful creation clears the failures but keeps the rest of the
+	// status
+	r.resetPodVMImageCreationFailures()
+	g.Expect(r.kataConfig.Status.PodVMImage.FailedAttempts).To(BeZero())
+	g.Expect(r.kataConfig.Status.PodVMImage.LastFailureTime).To(BeNil())
+	g.Expect(r.kataConfig.Status.PodVMImage.Regions).To(HaveLen(1))
+	g.Expect(r.findCondition(kataconfigurationv1.KataConfigPodVMImageBuildFailed)).To(BeNil())
+	g.Expect(r.isPodVMImageRetryBudgetSpent()).To(BeFalse())
+}
@@ -2,12 +2,20 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"os"
 
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
 
 	// import apps/v1 for Deployment
 	appsv1 "k8s.io/api/apps/v1"
@@ -32,6 +40,26 @@ const (
 
 	// Define webhook mutating config name
 	webhookConfigName = "mutating-webhook-configuration"
+
+	webhookPDBName = "peer-pods-webhook-pdb"
+
+	// Port of the health probes of the webhook
+	webhookHealthProbePort = 8081
+
+	// Annotation holding the hash of the deployment spec the operator
+	// created the webhook deployment with
+	webhookSpecHashAnnotation = "kataconfiguration.openshift.io/spec-hash"
+
+	injectCABundleAnnotation = "service.beta.openshift.io/inject-cabundle"
+
+	// Name of the KataConfig validating webhook served by the operator
+	kataConfigWebhookName = "vkataconfig.kb.io"
+
+	// Label scoping the webhook unless KataConfig.spec.peerPodsWebhook
+	// sets another one, and its values
+	defaultPeerPodsWebhookNamespaceLabel = "kataconfiguration.openshift.io/peer-pods-webhook"
+	peerPodsWebhookEnabled               = "enabled"
+	peerPodsWebhookDisabled              = "disabled"
 )
 
 // Method to create the mutating webhook service
@@ -108,9 +136,17 @@ func (r *KataConfigOpenShiftReconciler) createMutatingWebhookDeployment() error
 	// Define webhook deployment replicas
 	webhookDeploymentReplicas := int32(2)
 
-	// Define webhook deployment strategy
+	// Define webhook deployment strategy.  A replica is only replaced once
+	// its successor is ready so that the webhook stays available during a
+	// rollout.
+	maxUnavailable := intstr.FromInt(0)
+	maxSurge := intstr.FromInt(1)
 	webhookDeploymentStrategy := appsv1.DeploymentStrategy{
 		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: &maxUnavailable,
+			MaxSurge:       &maxSurge,
+		},
 	}
 
 	// Define webhook deployment pod template labels
@@ -168,6 +204,23 @@ func (r *KataConfigOpenShiftReconciler) createMutatingWebhookDeployment() error
 				// Run as nonroot user
 				RunAsNonRoot: &runAsNonRoot,
 			},
+			// Spread the replicas over the nodes, and the zones if
+			// possible, so that draining a node never takes the webhook
+			// down
+			TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+				{
+					MaxSkew:           1,
+					TopologyKey:       "kubernetes.io/hostname",
+					WhenUnsatisfiable: corev1.DoNotSchedule,
+					LabelSelector:     &metav1.LabelSelector{MatchLabels: webhookDeploymentPodTemplateLabels},
+				},
+				{
+					MaxSkew:           1,
+					TopologyKey:       "topology.kubernetes.io/zone",
+					WhenUnsatisfiable: corev1.ScheduleAnyway,
+					LabelSelector:     &metav1.LabelSelector{MatchLabels: webhookDeploymentPodTemplateLabels},
+				},
+			},
 			Containers: []corev1.Container{
 				{
 					Name: webhookDeploymentName,
@@ -198,7 +251,7 @@ func (r *KataConfigOpenShiftReconciler) createMutatingWebhookDeployment() error
 						},
 						{
 							Name:  "POD_VM_EXTENDED_RESOURCE",
-							Value: "kata.peerpods.io/vm",
+							Value: peerPodsExtendedResource,
 						},
 					},
 					// Define resources
@@ -212,6 +265,30 @@ func (r *KataConfigOpenShiftReconciler) createMutatingWebhookDeployment() error
 							"memory": resource.MustParse("128Mi"),
 						},
 					},
+					// The health probes of the controller-runtime
+					// manager running the webhook.  Only ready
+					// replicas get admission requests and count as
+					// available during a rollout.
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{
+								Path: "/readyz",
+								Port: intstr.FromInt(webhookHealthProbePort),
+							},
+						},
+						InitialDelaySeconds: 5,
+						PeriodSeconds:       10,
+					},
+					LivenessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{
+								Path: "/healthz",
+								Port: intstr.FromInt(webhookHealthProbePort),
+							},
+						},
+						InitialDelaySeconds: 15,
+						PeriodSeconds:       20,
+					},
 					// Add volume mounts
 					VolumeMounts: []corev1.VolumeMount{
 						{
@@ -227,6 +304,8 @@ func (r *KataConfigOpenShiftReconciler) createMutatingWebhookDeployment() error
 		},
 	}
 
+	applyWorkloadOverrides(&webhookDeploymentPodTemplateSpec.Spec, r.getPeerPodsWebhookOverrides())
+
 	// Define webhook deployment
 	webhookDeployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -239,19 +318,67 @@ func (r *KataConfigOpenShiftReconciler) createMutatingWebhookDeployment() error
 			Selector: &metav1.LabelSelector{
 				MatchLabels: webhookDeploymentLabels,
 			},
-			Strategy: webhookDeploymentStrategy,
-			Template: webhookDeploymentPodTemplateSpec,
+			Strategy:        webhookDeploymentStrategy,
+			MinReadySeconds: 5,
+			Template:        webhookDeploymentPodTemplateSpec,
 		},
 	}
 
-	// Create webhook deployment
-	if err := r.Client.Create(context.Background(), webhookDeployment); err != nil {
-		// Check if the webhook deployment already exists
-		if !k8serrors.IsAlreadyExists(err) {
-			return err
+	// The API server defaults many fields of the spec, compare a hash of
+	// the spec the operator wants instead to only update the deployment,
+	// and roll it out, when the operator changes it
+	specJSON, err := json.Marshal(webhookDeployment.Spec)
+	if err != nil {
+		return err
+	}
+	specHash := sha256.Sum256(specJSON)
+	desiredSpec := webhookDeployment.Spec
+
+	_, err = controllerutil.CreateOrUpdate(context.Background(), r.Client, webhookDeployment, func() error {
+		if webhookDeployment.Annotations[webhookSpecHashAnnotation] == hex.EncodeToString(specHash[:]) {
+			return nil
+		}
+		if webhookDeployment.Annotations == nil {
+			webhookDeployment.Annotations = map[string]string{}
 		}
+		webhookDeployment.Annotations[webhookSpecHashAnnotation] = hex.EncodeToString(specHash[:])
+		webhookDeployment.Labels = webhookDeploymentLabels
+		webhookDeployment.Spec = desiredSpec
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 	r.Log.Info("created peerpods mutating webhook deployment")
+
+	return r.createMutatingWebhookPDB()
+}
+
+// Method to create the mutating webhook PodDisruptionBudget.  Node drains,
+// which the operator triggers itself through the MachineConfigs it
+// creates, evict one webhook replica at a time.
+func (r *KataConfigOpenShiftReconciler) createMutatingWebhookPDB() error {
+	minAvailable := intstr.FromInt(1)
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webhookPDBName,
+			Namespace: os.Getenv("PEERPODS_NAMESPACE"),
+		},
+	}
+
+	_, err := controllerutil.CreateOrUpdate(context.Background(), r.Client, pdb, func() error {
+		pdb.Spec.MinAvailable = &minAvailable
+		pdb.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app": "peer-pods-webhook",
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.Log.Info("created peerpods mutating webhook pod disruption budget")
 	return nil
 }
 
@@ -263,147 +390,186 @@ func (r *KataConfigOpenShiftReconciler) createMutatingWebhookConfig() error {
 
 	// Add failure policy
 	failurePolicy := admissionregistrationv1.Fail
+	if spec := r.kataConfig.Spec.PeerPodsWebhook; spec != nil && spec.FailurePolicy != "" {
+		failurePolicy = admissionregistrationv1.FailurePolicyType(spec.FailurePolicy)
+	}
 
 	// Add side effect
 	sideEffect := admissionregistrationv1.SideEffectClassNone
 
-	// Add list of namespaces to exclude
-	namespacesToExclude := []string{
-		"peer-pods-webhook-system",
-		"openshift-sandboxed-containers-operator",
-		"openshift",
-		"openshift-apiserver",
-		"openshift-apiserver-operator",
-		"openshift-authentication",
-		"openshift-authentication-operator",
-		"openshift-cloud-controller-manager",
-		"openshift-cloud-controller-manager-operator",
-		"openshift-cloud-credential-operator",
-		"openshift-cloud-network-config-controller",
-		"openshift-cluster-csi-drivers",
-		"openshift-cluster-machine-approver",
-		"openshift-cluster-node-tuning-operator",
-		"openshift-cluster-samples-operator",
-		"openshift-cluster-storage-operator",
-		"openshift-cluster-version",
-		"openshift-config",
-		"openshift-config-managed",
-		"openshift-config-operator",
-		"openshift-console",
-		"openshift-console-operator",
-		"openshift-console-user-settings",
-		"openshift-controller-manager",
-		"openshift-controller-manager-operator",
-		"openshift-dns",
-		"openshift-dns-operator",
-		"openshift-etcd",
-		"openshift-etcd-operator",
-		"openshift-host-network",
-		"openshift-image-registry",
-		"openshift-infra",
-		"openshift-ingress",
-		"openshift-ingress-canary",
-		"openshift-ingress-operator",
-		"openshift-insights",
-		"openshift-kni-infra",
-		"openshift-kube-apiserver",
-		"openshift-kube-apiserver-operator",
-		"openshift-kube-controller-manager",
-		"openshift-kube-scheduler",
-		"openshift-kube-scheduler-operator",
-		"openshift-kube-storage-version-migrator",
-		"openshift-kube-storage-version-migrator-operator",
-		"openshift-machine-api",
-		"openshift-machine-config-operator",
-		"openshift-marketplace",
-		"openshift-monitoring",
-		"openshift-multus",
-		"openshift-network-diagnostics",
-		"openshift-network-operator",
-		"openshift-node",
-		"openshift-nutanix-infra",
-		"openshift-oauth-apiserver",
-		"openshift-openstack-infra",
-		"openshift-operator-lifecycle-manager",
-		"openshift-operators",
-		"openshift-ovirt-infra",
-		"openshift-ovn-kubernetes",
-		"openshift-route-controller-manager",
-		"openshift-service-ca",
-		"openshift-service-ca-operator",
-		"openshift-user-workload-monitoring",
-		"openshift-vsphere-infra",
-		"kube-system",
-		"kube-node-lease",
-	}
+	// Set the scope explicitly, the API server defaults it anyway
+	scope := admissionregistrationv1.AllScopes
 
 	webhookSvcNamespace := os.Getenv("PEERPODS_NAMESPACE")
 
-	// Add annotations to inject ca bundle into the webhook config
-	annotations := map[string]string{
-		"service.beta.openshift.io/inject-cabundle": "true",
+	var operatorClientConfig *admissionregistrationv1.WebhookClientConfig
+	if r.inProcessPeerPodsWebhook {
+		var err error
+		operatorClientConfig, err = r.getOperatorWebhookClientConfig()
+		if err != nil {
+			return err
+		}
 	}
 
 	// Add mutating webhook configuration
 	mutatingWebhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: webhookConfigName,
-			// Add annotations
-			Annotations: annotations,
 		},
-		Webhooks: []admissionregistrationv1.MutatingWebhook{
+	}
+
+	// The webhook is updated in place so that the fields defaulted by the
+	// API server and the injected CA bundle don't cause an update on every
+	// reconcile
+	_, err := controllerutil.CreateOrUpdate(context.Background(), r.Client, mutatingWebhookConfig, func() error {
+		webhook := admissionregistrationv1.MutatingWebhook{}
+		for _, existing := range mutatingWebhookConfig.Webhooks {
+			if existing.Name == webhookName {
+				webhook = existing
+			}
+		}
+
+		if r.inProcessPeerPodsWebhook {
+			// The CA bundle is copied from the operator's own webhook on
+			// every reconcile, WebhookEventHandler triggers one when it
+			// rotates
+			delete(mutatingWebhookConfig.Annotations, injectCABundleAnnotation)
+			webhook.ClientConfig = *operatorClientConfig
+		} else {
+			// Add annotations to inject ca bundle into the webhook config
+			if mutatingWebhookConfig.Annotations == nil {
+				mutatingWebhookConfig.Annotations = map[string]string{}
+			}
+			mutatingWebhookConfig.Annotations[injectCABundleAnnotation] = "true"
+
+			// Drop the CA bundle of the operator if the webhook used to
+			// be served in-process
+			service := webhook.ClientConfig.Service
+			if service == nil || service.Name != webhookSvcName || service.Namespace != webhookSvcNamespace {
+				webhook.ClientConfig = admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{},
+				}
+			}
+			webhook.ClientConfig.Service.Name = webhookSvcName
+			webhook.ClientConfig.Service.Namespace = webhookSvcNamespace
+			webhook.ClientConfig.Service.Path = &webhookPath
+		}
+
+		webhook.Name = webhookName
+		// Add rules
+		webhook.Rules = []admissionregistrationv1.RuleWithOperations{
 			{
-				Name: webhookName,
-				ClientConfig: admissionregistrationv1.WebhookClientConfig{
-					Service: &admissionregistrationv1.ServiceReference{
-						Name:      webhookSvcName,
-						Namespace: webhookSvcNamespace,
-						Path:      &webhookPath,
-					},
-				},
-				// Add rules
-				Rules: []admissionregistrationv1.RuleWithOperations{
-					{
-						Operations: []admissionregistrationv1.OperationType{
-							admissionregistrationv1.Create,
-							admissionregistrationv1.Update,
-						},
-						Rule: admissionregistrationv1.Rule{
-							APIGroups:   []string{""},
-							APIVersions: []string{"v1"},
-							Resources:   []string{"pods"},
-						},
-					},
+				Operations: []admissionregistrationv1.OperationType{
+					admissionregistrationv1.Create,
+					admissionregistrationv1.Update,
 				},
-				// Add failure policy
-				FailurePolicy: &failurePolicy,
-				// Add side effects
-				SideEffects: &sideEffect,
-				// Add admission review versions
-				AdmissionReviewVersions: []string{"v1"},
-				// Add namespace selector using matchExpressions
-				NamespaceSelector: &metav1.LabelSelector{
-					MatchExpressions: []metav1.LabelSelectorRequirement{
-						{
-							Key:      "kubernetes.io/metadata.name",
-							Operator: metav1.LabelSelectorOpNotIn,
-							// Take values from a predefined list
-							Values: namespacesToExclude,
-						},
-					},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"pods"},
+					Scope:       &scope,
 				},
 			},
+		}
+		// Add failure policy
+		webhook.FailurePolicy = &failurePolicy
+		// Add side effects
+		webhook.SideEffects = &sideEffect
+		// Add admission review versions
+		webhook.AdmissionReviewVersions = []string{"v1"}
+		// Only call the webhook for peer pods.  Labels can't select on
+		// the RuntimeClass of a pod, a match condition is used for that.
+		webhook.MatchConditions = []admissionregistrationv1.MatchCondition{
+			{
+				Name:       "peer-pods-only",
+				Expression: fmt.Sprintf("has(object.spec.runtimeClassName) && object.spec.runtimeClassName == '%s'", peerpodsRuntimeClassName),
+			},
+		}
+		webhook.NamespaceSelector, webhook.ObjectSelector = r.getPeerPodsWebhookSelectors(webhookSvcNamespace)
+
+		mutatingWebhookConfig.Webhooks = []admissionregistrationv1.MutatingWebhook{webhook}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.Log.Info("created peerpods mutating webhook configuration", "inProcess", r.inProcessPeerPodsWebhook)
+	return nil
+}
+
+// Returns the namespace and the object selector of the peer pods webhook
+// from KataConfig.spec.peerPodsWebhook.  The namespace of the webhook is
+// always excluded so that the webhook never blocks its own pods, in case
+// the API server doesn't support match conditions.
+func (r *KataConfigOpenShiftReconciler) getPeerPodsWebhookSelectors(webhookNamespace string) (*metav1.LabelSelector, *metav1.LabelSelector) {
+	label := defaultPeerPodsWebhookNamespaceLabel
+	mode := kataconfigurationv1.PeerPodsWebhookOptOut
+	if spec := r.kataConfig.Spec.PeerPodsWebhook; spec != nil {
+		if spec.NamespaceLabel != "" {
+			label = spec.NamespaceLabel
+		}
+		if spec.NamespaceLabelMode != "" {
+			mode = spec.NamespaceLabelMode
+		}
+	}
+
+	namespaceRequirement := metav1.LabelSelectorRequirement{
+		Key:      label,
+		Operator: metav1.LabelSelectorOpNotIn,
+		Values:   []string{peerPodsWebhookDisabled},
+	}
+	if mode == kataconfigurationv1.PeerPodsWebhookOptIn {
+		namespaceRequirement = metav1.LabelSelectorRequirement{
+			Key:      label,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{peerPodsWebhookEnabled},
+		}
+	}
+
+	namespaceSelector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      "kubernetes.io/metadata.name",
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   []string{webhookNamespace},
+			},
+			namespaceRequirement,
+		},
+	}
+	objectSelector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      label,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   []string{peerPodsWebhookDisabled},
+			},
 		},
 	}
+	return namespaceSelector, objectSelector
+}
 
-	// Create MutatingWebhookConfiguration object
-	if err := r.Client.Create(context.Background(), mutatingWebhookConfig); err != nil {
-		if !k8serrors.IsAlreadyExists(err) {
-			return err
+// Returns the client config of the webhook server of the operator.  The
+// Service and the CA bundle are taken from the KataConfig validating webhook
+// which OLM (or cert-manager) keeps up to date, only the path is changed to
+// the one of the peer pods mutation.
+func (r *KataConfigOpenShiftReconciler) getOperatorWebhookClientConfig() (*admissionregistrationv1.WebhookClientConfig, error) {
+	webhookConfigs := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	if err := r.Client.List(context.Background(), webhookConfigs); err != nil {
+		return nil, err
+	}
+
+	for _, webhookConfig := range webhookConfigs.Items {
+		for _, webhook := range webhookConfig.Webhooks {
+			if webhook.Name != kataConfigWebhookName || webhook.ClientConfig.Service == nil {
+				continue
+			}
+			clientConfig := webhook.ClientConfig.DeepCopy()
+			path := PeerPodsPodMutatePath
+			clientConfig.Service.Path = &path
+			return clientConfig, nil
 		}
 	}
-	r.Log.Info("created peerpods mutating webhook configuration")
-	return nil
+	return nil, fmt.Errorf("validating webhook %s of the operator not found", kataConfigWebhookName)
 }
 
 // Method to delete the Mutating Webhook Deployment
@@ -425,6 +591,20 @@ func (r *KataConfigOpenShiftReconciler) deleteMutatingWebhookDeployment() error
 		}
 	}
 	r.Log.Info("deleted peerpods mutating webhook deployment")
+
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webhookPDBName,
+			Namespace: webhookDeploymentNamespace,
+		},
+	}
+	err = r.Client.Delete(context.Background(), pdb)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	r.Log.Info("deleted peerpods mutating webhook pod disruption budget")
 	return nil
 }
 
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Path the operator's webhook server serves the peer pods mutation on when
+// the inProcessPeerPodsWebhook feature gate is enabled
+const PeerPodsPodMutatePath = "/mutate-v1-pod-peerpods"
+
+// PeerPodsPodMutator does what the peer-pods-webhook deployment does, from
+// within the operator.  A peer pod doesn't consume the CPU and memory of the
+// node it's scheduled on, its containers run in a pod VM.  The resources of
+// its containers are therefore replaced with a single pod VM extended
+// resource, which limits the number of peer pods per node to the
+// PeerPodConfig limit and is what the peer pods namespace quotas count.
+type PeerPodsPodMutator struct {
+	Decoder *admission.Decoder
+}
+
+// Replaces the container resources of the pod with the pod VM extended
+// resource
+func mutatePeerPod(pod *corev1.Pod) {
+	for idx := range pod.Spec.Containers {
+		pod.Spec.Containers[idx].Resources = corev1.ResourceRequirements{}
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return
+	}
+
+	vmResource := corev1.ResourceList{
+		corev1.ResourceName(peerPodsExtendedResource): resource.MustParse("1"),
+	}
+	pod.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+		Requests: vmResource,
+		Limits:   vmResource.DeepCopy(),
+	}
+}
+
+func (m *PeerPodsPodMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := m.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != peerpodsRuntimeClassName {
+		return admission.Allowed("not a peer pod")
+	}
+
+	mutatePeerPod(pod)
+
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newPodAdmissionRequest(g *WithT, pod *corev1.Pod) admission.Request {
+	raw, err := json.Marshal(pod)
+	g.Expect(err).NotTo(HaveOccurred())
+	return admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func newPeerPodsPodMutator(t *testing.T) *PeerPodsPodMutator {
+	return &PeerPodsPodMutator{Decoder: admission.NewDecoder(newTestScheme(t))}
+}
+
+func TestPeerPodsPodMutatorMutatesPeerPods(t *testing.T) {
+	g := NewWithT(t)
+	runtimeClassName := peerpodsRuntimeClassName
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "peer-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			RuntimeClassName: &runtimeClassName,
+			Containers: []corev1.Container{
+				{
+					Name: "app",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+						Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+					},
+				},
+				{
+					Name: "sidecar",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					},
+				},
+			},
+		},
+	}
+
+	res := newPeerPodsPodMutator(t).Handle(context.TODO(), newPodAdmissionRequest(g, pod))
+	g.Expect(res.Allowed).To(BeTrue())
+	g.Expect(res.Patches).NotTo(BeEmpty())
+
+	mutatePeerPod(pod)
+	vmResource := corev1.ResourceList{corev1.ResourceName(peerPodsExtendedResource): resource.MustParse("1")}
+	g.Expect(pod.Spec.Containers[0].Resources.Requests).To(Equal(vmResource))
+	g.Expect(pod.Spec.Containers[0].Resources.Limits).To(Equal(vmResource))
+	g.Expect(pod.Spec.Containers[1].Resources).To(Equal(corev1.ResourceRequirements{}))
+}
+
+func TestPeerPodsPodMutatorIgnoresOtherPods(t *testing.T) {
+	g := NewWithT(t)
+	runtimeClassName := "kata"
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kata-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			RuntimeClassName: &runtimeClassName,
+			Containers: []corev1.Container{
+				{
+					Name: "app",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+					},
+				},
+			},
+		},
+	}
+
+	res := newPeerPodsPodMutator(t).Handle(context.TODO(), newPodAdmissionRequest(g, pod))
+	g.Expect(res.Allowed).To(BeTrue())
+	g.Expect(res.Patches).To(BeEmpty())
+}
+
+func TestPeerPodsWebhookConfigInProcess(t *testing.T) {
+	g := NewWithT(t)
+
+	kataConfigPath := "/validate-kataconfiguration-openshift-io-v1-kataconfig"
+	port := int32(443)
+	operatorWebhook := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "vkataconfig.kb.io-abcde"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name: kataConfigWebhookName,
+				ClientConfig: admissionregistrationv1.WebhookClientConfig{
+					Service: &admissionregistrationv1.ServiceReference{
+						Name:      "controller-manager-service",
+						Namespace: OperatorNamespace,
+						Path:      &kataConfigPath,
+						Port:      &port,
+					},
+					CABundle: []byte("operator-ca"),
+				},
+			},
+		},
+	}
+
+	r := newTestReconciler(t, nil, nil, operatorWebhook)
+	getWebhook := func() admissionregistrationv1.MutatingWebhookConfiguration {
+		mwc := admissionregistrationv1.MutatingWebhookConfiguration{}
+		g.Expect(r.Client.Get(context.TODO(), client.ObjectKey{Name: webhookConfigName}, &mwc)).To(Succeed())
+		g.Expect(mwc.Webhooks).To(HaveLen(1))
+		return mwc
+	}
+
+	// The external webhook gets its CA bundle injected by the service CA
+	g.Expect(r.createMutatingWebhookConfig()).To(Succeed())
+	mwc := getWebhook()
+	g.Expect(mwc.Annotations).To(HaveKeyWithValue(injectCABundleAnnotation, "true"))
+	g.Expect(mwc.Webhooks[0].ClientConfig.Service.Name).To(Equal(webhookSvcName))
+	mwc.Webhooks[0].ClientConfig.CABundle = []byte("service-ca")
+	g.Expect(r.Client.Update(context.TODO(), &mwc)).To(Succeed())
+
+	// Reconciling again keeps the injected CA bundle
+	g.Expect(r.createMutatingWebhookConfig()).To(Succeed())
+	g.Expect(getWebhook().Webhooks[0].ClientConfig.CABundle).To(Equal([]byte("service-ca")))
+
+	// Switching to the in-process webhook points it to the operator
+	r.inProcessPeerPodsWebhook = true
+	g.Expect(r.createMutatingWebhookConfig()).To(Succeed())
+	mwc = getWebhook()
+	g.Expect(mwc.Annotations).NotTo(HaveKey(injectCABundleAnnotation))
+	clientConfig := mwc.Webhooks[0].ClientConfig
+	g.Expect(clientConfig.Service.Name).To(Equal("controller-manager-service"))
+	g.Expect(clientConfig.Service.Path).To(HaveValue(Equal(PeerPodsPodMutatePath)))
+	g.Expect(clientConfig.CABundle).To(Equal([]byte("operator-ca")))
+	// The operator's own webhook is left alone
+	g.Expect(operatorWebhook.Webhooks[0].ClientConfig.Service.Path).To(HaveValue(Equal(kataConfigPath)))
+
+	// Rotating the operator's CA triggers a reconcile which picks it up
+	rotated := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	g.Expect(r.Client.Get(context.TODO(), client.ObjectKeyFromObject(operatorWebhook), rotated)).To(Succeed())
+	previous := rotated.DeepCopy()
+	rotated.Webhooks[0].ClientConfig.CABundle = []byte("rotated-ca")
+	g.Expect(r.Client.Update(context.TODO(), rotated)).To(Succeed())
+	r.kataConfig.Spec.EnablePeerPods = true
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	(&WebhookEventHandler{r}).Update(context.TODO(), event.UpdateEvent{ObjectOld: previous, ObjectNew: rotated}, queue)
+	g.Expect(queue.Len()).To(Equal(1))
+	g.Expect(r.createMutatingWebhookConfig()).To(Succeed())
+	g.Expect(getWebhook().Webhooks[0].ClientConfig.CABundle).To(Equal([]byte("rotated-ca")))
+
+	// And back
+	r.inProcessPeerPodsWebhook = false
+	g.Expect(r.createMutatingWebhookConfig()).To(Succeed())
+	clientConfig = getWebhook().Webhooks[0].ClientConfig
+	g.Expect(clientConfig.Service.Name).To(Equal(webhookSvcName))
+	g.Expect(clientConfig.CABundle).To(BeEmpty())
+}
+
+func TestPeerPodsWebhookSelectors(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+	matches := func(selector *metav1.LabelSelector, labels map[string]string) bool {
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		g.Expect(err).NotTo(HaveOccurred())
+		return s.Matches(k8slabels.Set(labels))
+	}
+	namespace := func(name string, labels map[string]string) map[string]string {
+		set := map[string]string{"kubernetes.io/metadata.name": name}
+		for k, v := range labels {
+			set[k] = v
+		}
+		return set
+	}
+
+	// Opt-out with the default label
+	namespaceSelector, objectSelector := r.getPeerPodsWebhookSelectors(OperatorNamespace)
+	g.Expect(matches(namespaceSelector, namespace("team-a", nil))).To(BeTrue())
+	g.Expect(matches(namespaceSelector, namespace("team-b", map[string]string{defaultPeerPodsWebhookNamespaceLabel: "disabled"}))).To(BeFalse())
+	g.Expect(matches(namespaceSelector, namespace(OperatorNamespace, nil))).To(BeFalse())
+	g.Expect(matches(objectSelector, nil)).To(BeTrue())
+	g.Expect(matches(objectSelector, map[string]string{defaultPeerPodsWebhookNamespaceLabel: "disabled"})).To(BeFalse())
+
+	// Opt-in with a custom label
+	r.kataConfig.Spec.PeerPodsWebhook = &kataconfigurationv1.PeerPodsWebhookSpec{
+		NamespaceLabel:     "example.com/peer-pods",
+		NamespaceLabelMode: kataconfigurationv1.PeerPodsWebhookOptIn,
+	}
+	namespaceSelector, objectSelector = r.getPeerPodsWebhookSelectors(OperatorNamespace)
+	g.Expect(matches(namespaceSelector, namespace("team-a", nil))).To(BeFalse())
+	g.Expect(matches(namespaceSelector, namespace("team-b", map[string]string{"example.com/peer-pods": "enabled"}))).To(BeTrue())
+	g.Expect(matches(objectSelector, map[string]string{"example.com/peer-pods": "disabled"})).To(BeFalse())
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPeerPodsWebhookDeploymentAvailability(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+
+	g.Expect(r.createMutatingWebhookDeployment()).To(Succeed())
+
+	deployment := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: webhookDeploymentName, Namespace: OperatorNamespace}
+	g.Expect(r.Client.Get(context.TODO(), key, deployment)).To(Succeed())
+	g.Expect(deployment.Annotations).To(HaveKey(webhookSpecHashAnnotation))
+	g.Expect(deployment.Spec.Strategy.RollingUpdate.MaxUnavailable.IntValue()).To(Equal(0))
+	g.Expect(deployment.Spec.Template.Spec.TopologySpreadConstraints).NotTo(BeEmpty())
+	g.Expect(deployment.Spec.Template.Spec.Containers[0].ReadinessProbe).NotTo(BeNil())
+
+	pdb := &policyv1.PodDisruptionBudget{}
+	g.Expect(r.Client.Get(context.TODO(), types.NamespacedName{Name: webhookPDBName, Namespace: OperatorNamespace}, pdb)).To(Succeed())
+	g.Expect(pdb.Spec.MinAvailable.IntValue()).To(Equal(1))
+	g.Expect(pdb.Spec.Selector.MatchLabels).To(Equal(deployment.Spec.Template.Labels))
+
+	// Reconciling an unchanged deployment doesn't update it, changes made
+	// by the API server or others don't trigger a rollout either
+	deployment.Spec.Template.Spec.DNSPolicy = "ClusterFirst"
+	g.Expect(r.Client.Update(context.TODO(), deployment)).To(Succeed())
+	resourceVersion := deployment.ResourceVersion
+	g.Expect(r.createMutatingWebhookDeployment()).To(Succeed())
+	g.Expect(r.Client.Get(context.TODO(), key, deployment)).To(Succeed())
+	g.Expect(deployment.ResourceVersion).To(Equal(resourceVersion))
+
+	// A deployment created by an older operator is brought up to date
+	delete(deployment.Annotations, webhookSpecHashAnnotation)
+	deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}
+	g.Expect(r.Client.Update(context.TODO(), deployment)).To(Succeed())
+	g.Expect(r.createMutatingWebhookDeployment()).To(Succeed())
+	g.Expect(r.Client.Get(context.TODO(), key, deployment)).To(Succeed())
+	g.Expect(deployment.Spec.Strategy.RollingUpdate).NotTo(BeNil())
+
+	g.Expect(r.deleteMutatingWebhookDeployment()).To(Succeed())
+	expectObjectExists(g, r.Client, pdb, false)
+}
+
+func TestPeerPodsWebhookFailurePolicy(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+	getFailurePolicy := func() admissionregistrationv1.FailurePolicyType {
+		mwc := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		g.Expect(r.Client.Get(context.TODO(), client.ObjectKey{Name: webhookConfigName}, mwc)).To(Succeed())
+		return *mwc.Webhooks[0].FailurePolicy
+	}
+
+	g.Expect(r.createMutatingWebhookConfig()).To(Succeed())
+	g.Expect(getFailurePolicy()).To(Equal(admissionregistrationv1.Fail))
+
+	r.kataConfig.Spec.PeerPodsWebhook = &kataconfigurationv1.PeerPodsWebhookSpec{FailurePolicy: "Ignore"}
+	g.Expect(r.createMutatingWebhookConfig()).To(Succeed())
+	g.Expect(getFailurePolicy()).To(Equal(admissionregistrationv1.Ignore))
+}
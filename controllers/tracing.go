@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const (
+	// KataConfig annotation holding the W3C trace context of the rollout
+	// in progress.  The reconciles of an installation, an update or an
+	// uninstallation span dozens of requeues, they are all parented to
+	// the first one so that the rollout shows up as a single trace.
+	traceContextAnnotation = "kataconfiguration.openshift.io/trace-context"
+
+	tracerName = "github.com/openshift/sandboxed-containers-operator/controllers"
+)
+
+func (r *KataConfigOpenShiftReconciler) isTracingEnabled() bool {
+	return r.TracerProvider != nil
+}
+
+// Starts the span of a reconcile.  It continues the trace of the rollout
+// in progress if the KataConfig has one, the returned function ends the
+// span and records the trace on the KataConfig if a rollout started.
+func (r *KataConfigOpenShiftReconciler) startReconcileSpan(ctx context.Context) func(error) {
+	if !r.isTracingEnabled() {
+		return func(error) {}
+	}
+
+	carrier := propagation.MapCarrier{"traceparent": r.kataConfig.Annotations[traceContextAnnotation]}
+	ctx = propagation.TraceContext{}.Extract(ctx, carrier)
+	endSpan := r.startSpan(ctx, "Reconcile",
+		attribute.String("kataconfig.name", r.kataConfig.Name),
+		attribute.Int64("kataconfig.generation", r.kataConfig.Generation),
+		attribute.Bool("kataconfig.deleting", r.kataConfig.GetDeletionTimestamp() != nil),
+	)
+
+	return func(err error) {
+		if cond := r.findInProgressCondition(); cond != nil {
+			trace.SpanFromContext(r.traceCtx).SetAttributes(
+				attribute.String("kataconfig.in_progress.status", string(cond.Status)),
+				attribute.String("kataconfig.in_progress.reason", cond.Reason),
+			)
+		}
+		r.updateTraceContextAnnotation()
+		endSpan(err)
+	}
+}
+
+// Starts a span and makes it the span in progress until the returned
+// function is called
+func (r *KataConfigOpenShiftReconciler) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) func(error) {
+	parent := r.traceCtx
+	ctx, span := r.TracerProvider.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+	r.traceCtx = ctx
+
+	return func(err error) {
+		endSpan(span, err)
+		r.traceCtx = parent
+	}
+}
+
+// Starts the span of a phase of the reconcile, child of the span in
+// progress.  The phases don't take a context, as most of the reconciler.
+func (r *KataConfigOpenShiftReconciler) startPhaseSpan(name string) func(error) {
+	if !r.isTracingEnabled() || r.traceCtx == nil {
+		return func(error) {}
+	}
+	return r.startSpan(r.traceCtx, name)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Keeps the trace context annotation while a rollout is in progress and
+// removes it once it's done, the next rollout starts a new trace
+func (r *KataConfigOpenShiftReconciler) updateTraceContextAnnotation() {
+	inProgress := r.getInProgressConditionValue() == corev1.ConditionTrue || r.kataConfig.GetDeletionTimestamp() != nil
+
+	if _, ok := r.kataConfig.Annotations[traceContextAnnotation]; inProgress == ok {
+		return
+	}
+
+	patch := client.MergeFrom(r.kataConfig.DeepCopyObject().(client.Object))
+	if inProgress {
+		carrier := propagation.MapCarrier{}
+		propagation.TraceContext{}.Inject(r.traceCtx, carrier)
+		if carrier["traceparent"] == "" {
+			return
+		}
+		if r.kataConfig.Annotations == nil {
+			r.kataConfig.Annotations = map[string]string{}
+		}
+		r.kataConfig.Annotations[traceContextAnnotation] = carrier["traceparent"]
+	} else {
+		delete(r.kataConfig.Annotations, traceContextAnnotation)
+	}
+
+	// The KataConfig is gone once the uninstallation removed the finalizer
+	err := r.Client.Patch(context.TODO(), r.kataConfig, patch)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		r.Log.Info("Error updating the trace context of the KataConfig", "err", err)
+	}
+}
+
+// A client recording a span for each API call.  Calls with a context
+// without a span are parented to the span in progress of the reconciler,
+// most of the reconciler code uses context.TODO().
+type tracingClient struct {
+	client.Client
+	reconciler *KataConfigOpenShiftReconciler
+}
+
+func newTracingClient(c client.Client, reconciler *KataConfigOpenShiftReconciler) client.Client {
+	return &tracingClient{Client: c, reconciler: reconciler}
+}
+
+func (c *tracingClient) getKind(obj runtime.Object) string {
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		return gvk.Kind
+	}
+	return obj.GetObjectKind().GroupVersionKind().Kind
+}
+
+// Starts the span of an API call, the returned context is to be passed to
+// the call.  Calls made outside of a reconcile aren't traced.
+func (c *tracingClient) startSpan(ctx context.Context, verb string, kind string, key client.ObjectKey) (context.Context, func(error)) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if c.reconciler.traceCtx == nil {
+			return ctx, func(error) {}
+		}
+		ctx = c.reconciler.traceCtx
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("k8s.kind", kind)}
+	if key.Namespace != "" {
+		attrs = append(attrs, attribute.String("k8s.namespace", key.Namespace))
+	}
+	if key.Name != "" {
+		attrs = append(attrs, attribute.String("k8s.name", key.Name))
+	}
+
+	ctx, span := c.reconciler.TracerProvider.Tracer(tracerName).Start(ctx, verb+" "+kind,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		// Looking up objects that don't exist is business as usual
+		if k8serrors.IsNotFound(err) {
+			span.SetAttributes(attribute.Bool("k8s.not_found", true))
+			err = nil
+		}
+		endSpan(span, err)
+	}
+}
+
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	ctx, end := c.startSpan(ctx, "Get", c.getKind(obj), key)
+	err := c.Client.Get(ctx, key, obj, opts...)
+	end(err)
+	return err
+}
+
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	ctx, end := c.startSpan(ctx, "List", c.getKind(list), client.ObjectKey{})
+	err := c.Client.List(ctx, list, opts...)
+	end(err)
+	return err
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	ctx, end := c.startSpan(ctx, "Create", c.getKind(obj), client.ObjectKeyFromObject(obj))
+	err := c.Client.Create(ctx, obj, opts...)
+	end(err)
+	return err
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	ctx, end := c.startSpan(ctx, "Delete", c.getKind(obj), client.ObjectKeyFromObject(obj))
+	err := c.Client.Delete(ctx, obj, opts...)
+	end(err)
+	return err
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, end := c.startSpan(ctx, "Update", c.getKind(obj), client.ObjectKeyFromObject(obj))
+	err := c.Client.Update(ctx, obj, opts...)
+	end(err)
+	return err
+}
+
+func (c *tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	ctx, end := c.startSpan(ctx, "Patch", c.getKind(obj), client.ObjectKeyFromObject(obj))
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	end(err)
+	return err
+}
+
+func (c *tracingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	ctx, end := c.startSpan(ctx, "DeleteAllOf", c.getKind(obj), client.ObjectKeyFromObject(obj))
+	err := c.Client.DeleteAllOf(ctx, obj, opts...)
+	end(err)
+	return err
+}
+
+func (c *tracingClient) Status() client.SubResourceWriter {
+	return &tracingStatusWriter{SubResourceWriter: c.Client.Status(), client: c}
+}
+
+type tracingStatusWriter struct {
+	client.SubResourceWriter
+	client *tracingClient
+}
+
+func (w *tracingStatusWriter) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	ctx, end := w.client.startSpan(ctx, "Create", w.client.getKind(obj)+"/status", client.ObjectKeyFromObject(obj))
+	err := w.SubResourceWriter.Create(ctx, obj, subResource, opts...)
+	end(err)
+	return err
+}
+
+func (w *tracingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	ctx, end := w.client.startSpan(ctx, "Update", w.client.getKind(obj)+"/status", client.ObjectKeyFromObject(obj))
+	err := w.SubResourceWriter.Update(ctx, obj, opts...)
+	end(err)
+	return err
+}
+
+func (w *tracingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	ctx, end := w.client.startSpan(ctx, "Patch", w.client.getKind(obj)+"/status", client.ObjectKeyFromObject(obj))
+	err := w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+	end(err)
+	return err
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Returns the ended spans by name
+func getSpans(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func TestTracingRollout(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+	exporter := tracetest.NewInMemoryExporter()
+	r.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	r.Client = newTracingClient(r.Client, r)
+
+	key := types.NamespacedName{Name: "example-kataconfig"}
+	g.Expect(r.Client.Create(context.TODO(), &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+	})).To(Succeed())
+	// Calls made outside of a reconcile aren't traced
+	g.Expect(exporter.GetSpans()).To(BeEmpty())
+
+	reconcile := func(inProgress corev1.ConditionStatus, phaseErr error) {
+		r.kataConfig = &kataconfigurationv1.KataConfig{}
+		g.Expect(r.Client.Get(context.TODO(), key, r.kataConfig)).To(Succeed())
+
+		endReconcileSpan := r.startReconcileSpan(context.TODO())
+		endSpan := r.startPhaseSpan("updateStatus")
+		_ = r.Client.Get(context.TODO(), types.NamespacedName{Name: "missing"}, &corev1.ConfigMap{})
+		endSpan(phaseErr)
+		r.retrieveInProgressConditionForChange(inProgress)
+		endReconcileSpan(nil)
+	}
+
+	// The first reconcile of the installation starts the trace
+	reconcile(corev1.ConditionTrue, errors.New("nodes unavailable"))
+	spans := getSpans(exporter)
+	g.Expect(spans).To(HaveKey("Reconcile"))
+	root := spans["Reconcile"].SpanContext
+	g.Expect(spans["Reconcile"].Parent.IsValid()).To(BeFalse())
+	g.Expect(spans["updateStatus"].Parent.SpanID()).To(Equal(root.SpanID()))
+	g.Expect(spans["updateStatus"].Status.Code).To(Equal(codes.Error))
+	g.Expect(spans["Get ConfigMap"].Parent.SpanID()).To(Equal(spans["updateStatus"].SpanContext.SpanID()))
+	// Missing objects aren't errors
+	g.Expect(spans["Get ConfigMap"].Status.Code).To(Equal(codes.Unset))
+	g.Expect(spans).To(HaveKey("Patch KataConfig"))
+
+	kataConfig := &kataconfigurationv1.KataConfig{}
+	g.Expect(r.Client.Get(context.TODO(), key, kataConfig)).To(Succeed())
+	g.Expect(kataConfig.Annotations[traceContextAnnotation]).To(ContainSubstring(root.TraceID().String()))
+
+	// Later reconciles of the rollout join its trace
+	exporter.Reset()
+	reconcile(corev1.ConditionFalse, nil)
+	spans = getSpans(exporter)
+	g.Expect(spans["Reconcile"].SpanContext.TraceID()).To(Equal(root.TraceID()))
+	g.Expect(spans["Reconcile"].Parent.SpanID()).To(Equal(root.SpanID()))
+
+	// The rollout is done, the next one starts a new trace
+	g.Expect(r.Client.Get(context.TODO(), key, kataConfig)).To(Succeed())
+	g.Expect(kataConfig.Annotations).NotTo(HaveKey(traceContextAnnotation))
+	g.Expect(r.traceCtx).To(BeNil())
+}
@@ -3,7 +3,6 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -15,10 +14,9 @@ import (
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
@@ -74,17 +72,6 @@ func parseJobYAML(yamlData []byte) (*batchv1.Job, error) {
 	return job, nil
 }
 
-// Method to read yaml file.
-// The full path of the yaml file is passed as an argument
-// Returns the yaml data and an error
-func readYamlFile(yamlFile string) ([]byte, error) {
-	yamlData, err := os.ReadFile(yamlFile)
-	if err != nil {
-		return nil, err
-	}
-	return yamlData, nil
-}
-
 func parseMachineConfigYAML(yamlData []byte) (*mcfgv1.MachineConfig, error) {
 	machineConfig := &mcfgv1.MachineConfig{}
 	err := yaml.Unmarshal(yamlData, machineConfig)
@@ -120,7 +107,7 @@ func parseConfigMapYAML(yamlData []byte) (*corev1.ConfigMap, error) {
 // The cache-key is used to avoid emitting frequent events for the same object
 // Returns an error
 
-func createKubernetesEvent(clientset *kubernetes.Clientset, event *corev1.Event, cacheKey string, createOptions metav1.CreateOptions) error {
+func createKubernetesEvent(c client.Client, event *corev1.Event, cacheKey string) error {
 	// Define the suppression duration for the event
 	suppressionDuration := 2 * time.Minute
 
@@ -139,7 +126,7 @@ func createKubernetesEvent(clientset *kubernetes.Clientset, event *corev1.Event,
 	eventCache[cacheKey] = eventInfo{timestamp: time.Now(), key: event.Reason}
 	mutex.Unlock()
 
-	_, err := clientset.CoreV1().Events(event.InvolvedObject.Namespace).Create(context.TODO(), event, createOptions)
+	err := c.Create(context.TODO(), event)
 	if err != nil {
 		return err
 	}
@@ -227,3 +214,17 @@ func updateConfigMap(client client.Client, logger logr.Logger, cmName string, na
 		return nil
 	}
 }
+
+// Returns the result requeueing the soonest of the given results
+func earliestResult(results ...ctrl.Result) ctrl.Result {
+	earliest := ctrl.Result{}
+	for _, result := range results {
+		if !result.Requeue {
+			continue
+		}
+		if !earliest.Requeue || result.RequeueAfter < earliest.RequeueAfter {
+			earliest = result
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// WebhookEventHandler triggers a reconcile when the CA bundle of the
+// KataConfig validating webhook changes so that the in-process peer pods
+// webhook, which copies it, follows certificate rotations.
+type WebhookEventHandler struct {
+	reconciler *KataConfigOpenShiftReconciler
+}
+
+func (wh *WebhookEventHandler) isInProcessPeerPodsWebhook() bool {
+	kataConfig := wh.reconciler.kataConfig
+	return kataConfig != nil && kataConfig.Spec.EnablePeerPods && wh.reconciler.inProcessPeerPodsWebhook
+}
+
+// Returns the CA bundle of the KataConfig validating webhook, if the
+// configuration holds it
+func getKataConfigWebhookCABundle(obj client.Object) ([]byte, bool) {
+	webhookConfig, ok := obj.(*admissionregistrationv1.ValidatingWebhookConfiguration)
+	if !ok {
+		return nil, false
+	}
+	for _, webhook := range webhookConfig.Webhooks {
+		if webhook.Name == kataConfigWebhookName {
+			return webhook.ClientConfig.CABundle, true
+		}
+	}
+	return nil, false
+}
+
+func (wh *WebhookEventHandler) Create(ctx context.Context, event event.CreateEvent, queue workqueue.RateLimitingInterface) {
+
+	if !wh.isInProcessPeerPodsWebhook() {
+		return
+	}
+	if _, ok := getKataConfigWebhookCABundle(event.Object); !ok {
+		return
+	}
+
+	log := wh.reconciler.Log.WithName("WebhookCreate").WithValues("webhook", event.Object.GetName())
+	log.Info("KataConfig validating webhook created")
+
+	queue.Add(wh.reconciler.makeReconcileRequest())
+}
+
+func (wh *WebhookEventHandler) Update(ctx context.Context, event event.UpdateEvent, queue workqueue.RateLimitingInterface) {
+
+	if !wh.isInProcessPeerPodsWebhook() {
+		return
+	}
+
+	caBundle, ok := getKataConfigWebhookCABundle(event.ObjectNew)
+	if !ok {
+		return
+	}
+	caBundleOld, _ := getKataConfigWebhookCABundle(event.ObjectOld)
+	if bytes.Equal(caBundleOld, caBundle) {
+		return
+	}
+
+	log := wh.reconciler.Log.WithName("WebhookUpdate").WithValues("webhook", event.ObjectNew.GetName())
+	log.Info("KataConfig validating webhook CA bundle changed")
+
+	queue.Add(wh.reconciler.makeReconcileRequest())
+}
+
+func (wh *WebhookEventHandler) Delete(ctx context.Context, event event.DeleteEvent, queue workqueue.RateLimitingInterface) {
+}
+
+func (wh *WebhookEventHandler) Generic(ctx context.Context, event event.GenericEvent, queue workqueue.RateLimitingInterface) {
+}
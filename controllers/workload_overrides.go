@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Returns the overrides of the kata-monitor DaemonSet, nil if there are none
+func (r *KataConfigOpenShiftReconciler) getKataMonitorOverrides() *kataconfigurationv1.WorkloadOverrides {
+	if r.kataConfig == nil || r.kataConfig.Spec.Workloads == nil {
+		return nil
+	}
+	return r.kataConfig.Spec.Workloads.KataMonitor
+}
+
+// Returns the overrides of the peer-pods-webhook Deployment, nil if there
+// are none
+func (r *KataConfigOpenShiftReconciler) getPeerPodsWebhookOverrides() *kataconfigurationv1.WorkloadOverrides {
+	if r.kataConfig == nil || r.kataConfig.Spec.Workloads == nil {
+		return nil
+	}
+	return r.kataConfig.Spec.Workloads.PeerPodsWebhook
+}
+
+// Returns the overrides of the pod VM image Jobs, nil if there are none
+func (r *KataConfigOpenShiftReconciler) getPodVMImageJobsOverrides() *kataconfigurationv1.WorkloadOverrides {
+	if r.kataConfig == nil || r.kataConfig.Spec.Workloads == nil {
+		return nil
+	}
+	return r.kataConfig.Spec.Workloads.PodVMImageJobs
+}
+
+// Applies the KataConfig's overrides to the pod spec of an operator managed
+// workload.  The first container is the workload's main container, extra
+// args go to its args, or to its command if it's run without args.
+func applyWorkloadOverrides(podSpec *corev1.PodSpec, overrides *kataconfigurationv1.WorkloadOverrides) {
+	if overrides == nil {
+		return
+	}
+
+	if len(overrides.NodeSelector) > 0 {
+		nodeSelector := map[string]string{}
+		for k, v := range overrides.NodeSelector {
+			nodeSelector[k] = v
+		}
+		// The operator's own node selector takes precedence
+		for k, v := range podSpec.NodeSelector {
+			nodeSelector[k] = v
+		}
+		podSpec.NodeSelector = nodeSelector
+	}
+
+	if overrides.Tolerations != nil {
+		podSpec.Tolerations = append([]corev1.Toleration{}, overrides.Tolerations...)
+	}
+
+	if overrides.PriorityClassName != "" {
+		podSpec.PriorityClassName = overrides.PriorityClassName
+	}
+
+	if overrides.ImagePullPolicy != "" {
+		for idx := range podSpec.InitContainers {
+			podSpec.InitContainers[idx].ImagePullPolicy = overrides.ImagePullPolicy
+		}
+		for idx := range podSpec.Containers {
+			podSpec.Containers[idx].ImagePullPolicy = overrides.ImagePullPolicy
+		}
+	}
+
+	if len(podSpec.Containers) == 0 {
+		return
+	}
+	container := &podSpec.Containers[0]
+
+	if overrides.Resources != nil {
+		container.Resources = *overrides.Resources.DeepCopy()
+	}
+
+	if len(overrides.ExtraArgs) > 0 {
+		if len(container.Args) > 0 || len(container.Command) == 0 {
+			container.Args = append(container.Args, overrides.ExtraArgs...)
+		} else {
+			container.Command = append(container.Command, overrides.ExtraArgs...)
+		}
+	}
+}
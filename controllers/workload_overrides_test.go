@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestWorkloadOverridesKataMonitor(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+
+	// Without overrides the DaemonSet keeps the operator's defaults
+	podSpec := r.processDaemonsetForMonitor().Spec.Template.Spec
+	g.Expect(podSpec.Tolerations).To(Equal([]corev1.Toleration{{Operator: corev1.TolerationOpExists}}))
+	g.Expect(podSpec.Containers[0].ImagePullPolicy).To(Equal(corev1.PullAlways))
+	defaultCommand := podSpec.Containers[0].Command
+
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("32Mi")},
+	}
+	tolerations := []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "kata"}}
+	r.kataConfig.Spec.Workloads = &kataconfigurationv1.WorkloadsSpec{
+		KataMonitor: &kataconfigurationv1.WorkloadOverrides{
+			Resources:         &resources,
+			NodeSelector:      map[string]string{"node-role.kubernetes.io/kata-oc": "other", "zone": "a"},
+			Tolerations:       tolerations,
+			PriorityClassName: "system-node-critical",
+			ImagePullPolicy:   corev1.PullIfNotPresent,
+			ExtraArgs:         []string{"--log-level=info"},
+		},
+	}
+
+	podSpec = r.processDaemonsetForMonitor().Spec.Template.Spec
+	g.Expect(podSpec.Tolerations).To(Equal(tolerations))
+	g.Expect(podSpec.PriorityClassName).To(Equal("system-node-critical"))
+	// The DaemonSet stays on the kata nodes
+	g.Expect(podSpec.NodeSelector).To(Equal(map[string]string{"node-role.kubernetes.io/kata-oc": "", "zone": "a"}))
+	container := podSpec.Containers[0]
+	g.Expect(container.Resources).To(Equal(resources))
+	g.Expect(container.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+	g.Expect(container.Command).To(Equal(append(defaultCommand, "--log-level=info")))
+}
+
+func TestWorkloadOverridesPeerPodsWebhook(t *testing.T) {
+	g := NewWithT(t)
+	r := newTestReconciler(t, nil, nil)
+
+	g.Expect(r.createMutatingWebhookDeployment()).To(Succeed())
+
+	deployment := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: webhookDeploymentName, Namespace: OperatorNamespace}
+	g.Expect(r.Client.Get(context.TODO(), key, deployment)).To(Succeed())
+	defaultArgs := deployment.Spec.Template.Spec.Containers[0].Args
+	specHash := deployment.Annotations[webhookSpecHashAnnotation]
+
+	// Changing the overrides rolls out the deployment
+	r.kataConfig.Spec.Workloads = &kataconfigurationv1.WorkloadsSpec{
+		PeerPodsWebhook: &kataconfigurationv1.WorkloadOverrides{
+			NodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+			ExtraArgs:    []string{"--zap-log-level=debug"},
+		},
+	}
+	g.Expect(r.createMutatingWebhookDeployment()).To(Succeed())
+	g.Expect(r.Client.Get(context.TODO(), key, deployment)).To(Succeed())
+	g.Expect(deployment.Annotations[webhookSpecHashAnnotation]).NotTo(Equal(specHash))
+	podSpec := deployment.Spec.Template.Spec
+	g.Expect(podSpec.NodeSelector).To(Equal(map[string]string{"node-role.kubernetes.io/infra": ""}))
+	g.Expect(podSpec.Containers[0].Args).To(Equal(append(defaultArgs, "--zap-log-level=debug")))
+	// Unset overrides keep the defaults
+	g.Expect(podSpec.Containers[0].Resources.Limits).NotTo(BeEmpty())
+}
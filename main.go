@@ -26,21 +26,32 @@ import (
 	configv1 "github.com/openshift/api/config/v1"
 	secv1 "github.com/openshift/api/security/v1"
 	mcfgapi "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
 	"go.uber.org/zap/zapcore"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	nodeapi "k8s.io/api/node/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	// These imports are unused but required in go.mod
 	// for caching during manifest generation by controller-gen
@@ -61,6 +72,14 @@ import (
 
 const (
 	OperatorNamespace = "openshift-sandboxed-containers-operator"
+
+	// Environment variable holding the default of the --tracing-endpoint
+	// flag, it can be set through the Subscription
+	TracingEndpointEnv = "TRACING_ENDPOINT"
+
+	// Tracing endpoint printing the traces on the standard output instead
+	// of sending them to a collector
+	tracingEndpointStdout = "stdout"
 )
 
 var (
@@ -86,6 +105,8 @@ func init() {
 	utilruntime.Must(configv1.AddToScheme(scheme))
 
 	utilruntime.Must(ccov1.AddToScheme(scheme))
+
+	utilruntime.Must(monitoringv1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -98,17 +119,44 @@ func SetTimeEncoderToRfc3339() zap.Opts {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var tracingEndpoint string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&tracingEndpoint, "tracing-endpoint", os.Getenv(TracingEndpointEnv),
+		"The OTLP/HTTP endpoint URL the KataConfig reconcile traces are sent to, "+
+			"\"stdout\" to print them instead. Tracing is disabled if empty.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true), SetTimeEncoderToRfc3339()))
 
+	tracerProvider, err := newTracerProvider(context.TODO(), tracingEndpoint)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing", "endpoint", tracingEndpoint)
+		os.Exit(1)
+	}
+
+	// Fail early on broken peer-pods manifests or overrides rather than
+	// when a reconcile needs them
+	manifestsOverrideDir := os.Getenv(controllers.PeerPodsManifestsOverrideDirEnv)
+	peerPodsManifests := controllers.NewPeerPodsManifests(manifestsOverrideDir)
+	if err := controllers.ValidatePeerPodsManifests(peerPodsManifests, manifestsOverrideDir); err != nil {
+		setupLog.Error(err, "invalid peer-pods manifests", "overrideDir", manifestsOverrideDir)
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:  scheme,
 		Metrics: metricsserver.Options{BindAddress: metricsAddr},
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				// The operator only watches its own pod VM image jobs
+				&batchv1.Job{}: {
+					Label: labels.SelectorFromSet(labels.Set{controllers.PodVMImageJobLabel: "true"}),
+				},
+			},
+		},
 		WebhookServer: &webhook.DefaultServer{
 			Options: webhook.Options{
 				Port: 9443,
@@ -144,11 +192,25 @@ func main() {
 
 		setupLog.Info("added labels")
 
-		if err = (&controllers.KataConfigOpenShiftReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("KataConfig"),
-			Scheme: mgr.GetScheme(),
-		}).SetupWithManager(mgr); err != nil {
+		podLogClient, err := corev1client.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			setupLog.Error(err, "unable to create pod log client")
+			os.Exit(1)
+		}
+
+		kataConfigReconciler := &controllers.KataConfigOpenShiftReconciler{
+			Client:       mgr.GetClient(),
+			Log:          ctrl.Log.WithName("controllers").WithName("KataConfig"),
+			Scheme:       mgr.GetScheme(),
+			PodLogClient: podLogClient,
+			Manifests:    peerPodsManifests,
+		}
+		// Leave the interface nil rather than holding a nil pointer when
+		// tracing is disabled
+		if tracerProvider != nil {
+			kataConfigReconciler.TracerProvider = tracerProvider
+		}
+		if err = kataConfigReconciler.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create KataConfig controller for OpenShift cluster", "controller", "KataConfig")
 			os.Exit(1)
 		}
@@ -180,10 +242,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Only called if the inProcessPeerPodsWebhook feature gate points the
+	// peer pods mutating webhook to the operator
+	mgr.GetWebhookServer().Register(controllers.PeerPodsPodMutatePath, &webhook.Admission{
+		Handler: &controllers.PeerPodsPodMutator{Decoder: admission.NewDecoder(mgr.GetScheme())},
+	})
+
 	if err = (&controllers.SecretReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Credentials"),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Log:       ctrl.Log.WithName("controllers").WithName("Credentials"),
+		Manifests: peerPodsManifests,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Credentials")
 		os.Exit(1)
@@ -195,6 +264,39 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	// Flush the spans of the last reconciles
+	if tracerProvider != nil {
+		if err := tracerProvider.Shutdown(context.TODO()); err != nil {
+			setupLog.Error(err, "problem shutting down tracing")
+		}
+	}
+}
+
+// Returns a tracer provider exporting the spans to the OTLP/HTTP endpoint
+// URL, e.g. http://otel-collector:4318, or to the standard output.  Tracing
+// is disabled, and nil is returned, if the endpoint is empty.
+func newTracerProvider(ctx context.Context, endpoint string) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch endpoint {
+	case "":
+		return nil, nil
+	case tracingEndpointStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("sandboxed-containers-operator"),
+		)),
+	), nil
 }
 
 func fixScc(ctx context.Context, mgr manager.Manager) error {